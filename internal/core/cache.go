@@ -0,0 +1,155 @@
+package core
+
+// Computed value caching.
+//
+// Layout results are stored in a node's ComputedValues keyed by the node
+// version, the widget revision (see Revisioned) and the constraints they
+// were computed for, so a clean node whose widget is unchanged and that is
+// laid out under the same constraints can reuse them. Caches are weak by
+// default; ApplyCachePolicy retains them strongly for nodes inside the
+// viewport so the visible part of the tree never has to be recomputed after
+// a GC.
+
+// LookupLayout returns the cached values if they are valid for the node's
+// current version, its widget's revision and the given constraints. Hits and
+// misses are counted in the owning tree's stats.
+func (n *Node) LookupLayout(constraints Constraints) (*ComputedValues, bool) {
+	cached := n.GetCachedValues()
+	hit := cached != nil &&
+		cached.Version == n.GetVersion() &&
+		cached.WidgetRevision == n.widgetRevision() &&
+		cached.Constraints == constraints
+
+	if t := n.owner.Load(); t != nil {
+		if hit {
+			t.cacheHits.Add(1)
+		} else {
+			t.cacheMisses.Add(1)
+		}
+	}
+
+	if !hit {
+		return nil, false
+	}
+	return cached, true
+}
+
+// StoreLayout caches a layout result for the node's current version, its
+// widget's revision and the given constraints, carrying over paint data and
+// hit region from the previous entry, and returns the new entry
+func (n *Node) StoreLayout(constraints Constraints, layout LayoutData) *ComputedValues {
+	values := &ComputedValues{
+		Layout:         layout,
+		Version:        n.GetVersion(),
+		WidgetRevision: n.widgetRevision(),
+		Constraints:    constraints,
+	}
+	if previous := n.GetCachedValues(); previous != nil {
+		values.Paint = previous.Paint
+		values.HitRegion = previous.HitRegion
+	}
+
+	n.SetCachedValues(values)
+	return values
+}
+
+// widgetRevision returns the revision of a Revisioned widget, zero otherwise
+func (n *Node) widgetRevision() uint64 {
+	if w, ok := n.Widget.(Revisioned); ok {
+		return w.Revision()
+	}
+	return 0
+}
+
+// RetainCachedValues switches the node's cache between strong (retain) and
+// weak references
+func (n *Node) RetainCachedValues(retain bool) {
+	if !retain {
+		n.strongCache.Store(nil)
+		return
+	}
+	if values := n.GetCachedValues(); values != nil {
+		n.strongCache.Store(values)
+	}
+}
+
+// IsCacheRetained reports whether the node's cache is strongly held
+func (n *Node) IsCacheRetained() bool {
+	return n.strongCache.Load() != nil
+}
+
+// ApplyCachePolicy updates paint data and hit regions for every cached node
+// and retains caches strongly for nodes whose transformed bounds intersect
+// the viewport, demoting the rest to weak references. It returns the number
+// of retained caches.
+func (t *Tree) ApplyCachePolicy(viewport Bounds) int {
+	t.mu.RLock()
+	root := t.root
+	t.mu.RUnlock()
+
+	if root == nil {
+		t.retainedCaches.Store(0)
+		return 0
+	}
+
+	retained := 0
+
+	var visit func(*Node, Transform)
+	visit = func(node *Node, parentWorld Transform) {
+		world := parentWorld.Multiply(node.LocalTransform())
+
+		local := Bounds{Width: node.Bounds.Width, Height: node.Bounds.Height}
+		worldBounds := world.ApplyBounds(local)
+		visible := intersects(worldBounds, viewport)
+
+		if cached := node.GetCachedValues(); cached != nil {
+			cached.Paint.Visibility = visible
+			if cached.Paint.Opacity == 0 {
+				cached.Paint.Opacity = 1
+			}
+			cached.HitRegion = &HitRegion{
+				Bounds: worldBounds,
+				Path: []Offset{
+					world.Apply(Offset{X: 0, Y: 0}),
+					world.Apply(Offset{X: local.Width, Y: 0}),
+					world.Apply(Offset{X: local.Width, Y: local.Height}),
+					world.Apply(Offset{X: 0, Y: local.Height}),
+				},
+			}
+
+			node.RetainCachedValues(visible)
+			if visible {
+				retained++
+			}
+		}
+
+		for _, child := range node.Children {
+			visit(child, world)
+		}
+	}
+	visit(root, IdentityTransform())
+
+	t.retainedCaches.Store(int64(retained))
+	return retained
+}
+
+// ResetCacheStats zeroes the cache hit and miss counters
+func (t *Tree) ResetCacheStats() {
+	t.cacheHits.Store(0)
+	t.cacheMisses.Store(0)
+}
+
+// CacheHitRate returns the fraction of layout lookups served from cache
+func (s TreeStats) CacheHitRate() float64 {
+	total := s.CacheHits + s.CacheMisses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(total)
+}
+
+// intersects reports whether two bounds overlap
+func intersects(a, b Bounds) bool {
+	return a.X <= b.X+b.Width && b.X <= a.X+a.Width &&
+		a.Y <= b.Y+b.Height && b.Y <= a.Y+a.Height
+}
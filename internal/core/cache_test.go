@@ -0,0 +1,176 @@
+package core
+
+import (
+	"runtime"
+	"testing"
+)
+
+// TestNode_LayoutCache tests version, revision and constraint keyed layout
+// caching
+func TestNode_LayoutCache(t *testing.T) {
+	constraints := Constraints{MaxWidth: 800, MaxHeight: 600}
+
+	t.Run("hit_and_miss", func(t *testing.T) {
+		tree := NewTree()
+		node := NewNode("node", &mockWidget{})
+		tree.SetRoot(node)
+
+		if _, ok := node.LookupLayout(constraints); ok {
+			t.Error("Empty cache should miss")
+		}
+
+		layout := LayoutData{}
+		layout.Size.Width = 40
+		stored := node.StoreLayout(constraints, layout)
+		node.RetainCachedValues(true)
+
+		cached, ok := node.LookupLayout(constraints)
+		if !ok || cached != stored || cached.Layout.Size.Width != 40 {
+			t.Error("Same version and constraints should hit")
+		}
+
+		if _, ok := node.LookupLayout(Constraints{MaxWidth: 100}); ok {
+			t.Error("Different constraints should miss")
+		}
+
+		node.MarkDirty(LayoutDirty)
+		if _, ok := node.LookupLayout(constraints); ok {
+			t.Error("New node version should miss")
+		}
+
+		stats := tree.GetStats()
+		if stats.CacheHits != 1 || stats.CacheMisses != 3 {
+			t.Errorf("Expected 1 hit and 3 misses, got %d and %d", stats.CacheHits, stats.CacheMisses)
+		}
+		if rate := stats.CacheHitRate(); rate != 0.25 {
+			t.Errorf("Expected hit rate 0.25, got %f", rate)
+		}
+
+		tree.ResetCacheStats()
+		if stats := tree.GetStats(); stats.CacheHits != 0 || stats.CacheMisses != 0 {
+			t.Error("ResetCacheStats should zero counters")
+		}
+	})
+
+	t.Run("store_keeps_paint_data", func(t *testing.T) {
+		node := NewNode("node", &mockWidget{})
+		previous := &ComputedValues{Paint: PaintData{Opacity: 0.5}}
+		node.SetCachedValues(previous)
+		node.RetainCachedValues(true)
+		runtime.KeepAlive(previous)
+
+		values := node.StoreLayout(constraints, LayoutData{})
+		if values.Paint.Opacity != 0.5 {
+			t.Error("StoreLayout should carry over paint data")
+		}
+		if node.GetCachedValues() != values {
+			t.Error("Retained cache should follow the new entry")
+		}
+	})
+
+	t.Run("widget_revision", func(t *testing.T) {
+		widget := &revisionedWidget{}
+		node := NewNode("node", widget)
+		values := node.StoreLayout(constraints, LayoutData{})
+		node.RetainCachedValues(true)
+		runtime.KeepAlive(values)
+
+		if _, ok := node.LookupLayout(constraints); !ok {
+			t.Fatal("Unchanged widget should hit")
+		}
+
+		// Widget state changed without the node being marked dirty
+		widget.revision++
+		if _, ok := node.LookupLayout(constraints); ok {
+			t.Error("New widget revision should miss")
+		}
+
+		node.StoreLayout(constraints, LayoutData{})
+		if _, ok := node.LookupLayout(constraints); !ok {
+			t.Error("Layout stored for the new revision should hit")
+		}
+	})
+
+	t.Run("retained_cache_survives_gc", func(t *testing.T) {
+		node := NewNode("node", &mockWidget{})
+		values := node.StoreLayout(constraints, LayoutData{})
+		node.RetainCachedValues(true)
+		runtime.KeepAlive(values)
+
+		runtime.GC()
+		runtime.GC()
+
+		if node.GetCachedValues() == nil {
+			t.Error("Retained cache should survive garbage collection")
+		}
+
+		node.RetainCachedValues(false)
+		if node.IsCacheRetained() {
+			t.Error("Cache should no longer be retained")
+		}
+	})
+}
+
+// TestTree_ApplyCachePolicy tests strong retention for visible nodes only
+func TestTree_ApplyCachePolicy(t *testing.T) {
+	tree := NewTree()
+	root := NewNode("root", &mockWidget{})
+	visible := NewNode("visible", &mockWidget{})
+	offscreen := NewNode("offscreen", &mockWidget{})
+	root.AddChild(visible)
+	root.AddChild(offscreen)
+	tree.SetRoot(root)
+
+	root.Bounds = Bounds{Width: 100, Height: 100}
+	visible.Bounds = Bounds{X: 10, Y: 10, Width: 20, Height: 20}
+	offscreen.Bounds = Bounds{X: 1000, Y: 1000, Width: 20, Height: 20}
+
+	for _, node := range []*Node{root, visible, offscreen} {
+		values := node.StoreLayout(Constraints{}, LayoutData{})
+		node.RetainCachedValues(true)
+		runtime.KeepAlive(values)
+	}
+	// Keep the offscreen entry reachable once it is demoted to weak
+	offscreenValues := offscreen.GetCachedValues()
+
+	retained := tree.ApplyCachePolicy(Bounds{Width: 200, Height: 200})
+
+	if retained != 2 {
+		t.Errorf("Expected 2 retained caches, got %d", retained)
+	}
+	if !visible.IsCacheRetained() || !root.IsCacheRetained() {
+		t.Error("Visible nodes should be retained")
+	}
+	if offscreen.IsCacheRetained() {
+		t.Error("Offscreen node should be demoted to weak")
+	}
+	if tree.GetStats().RetainedCaches != 2 {
+		t.Error("Stats should report retained caches")
+	}
+
+	values := visible.GetCachedValues()
+	if !values.Paint.Visibility || values.HitRegion == nil {
+		t.Fatal("Visible node should have visibility and hit region")
+	}
+	if values.HitRegion.Bounds.X != 10 || values.HitRegion.Bounds.Width != 20 {
+		t.Errorf("Unexpected hit region %+v", values.HitRegion.Bounds)
+	}
+
+	// Moving a node into view via a transform retains it
+	offscreen.SetTransform(TranslateTransform(-950, -950))
+	tree.ApplyCachePolicy(Bounds{Width: 200, Height: 200})
+	if !offscreen.IsCacheRetained() {
+		t.Error("Transformed node moved into view should be retained")
+	}
+	runtime.KeepAlive(offscreenValues)
+}
+
+// revisionedWidget is a mock widget whose layout state has a revision
+type revisionedWidget struct {
+	mockWidget
+	revision uint64
+}
+
+func (w *revisionedWidget) Revision() uint64 {
+	return w.revision
+}
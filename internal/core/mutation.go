@@ -0,0 +1,177 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/maya-framework/maya/internal/observer"
+)
+
+// MutationType identifies the kind of change recorded in a Mutation
+type MutationType int
+
+const (
+	MutationInsert MutationType = iota // Node inserted (or set as root)
+	MutationRemove                     // Node removed (or replaced as root)
+	MutationMove                       // Node moved to another parent or index
+	MutationDirty                      // Node gained dirty flags
+	MutationBounds                     // Node bounds changed
+)
+
+// String returns a readable name for the mutation type
+func (t MutationType) String() string {
+	switch t {
+	case MutationInsert:
+		return "insert"
+	case MutationRemove:
+		return "remove"
+	case MutationMove:
+		return "move"
+	case MutationDirty:
+		return "dirty"
+	case MutationBounds:
+		return "bounds"
+	default:
+		return "unknown"
+	}
+}
+
+// Mutation is a single change record, similar to a DOM MutationRecord.
+// Parent is empty when the target is (or was) the root.
+type Mutation struct {
+	Type   MutationType
+	Target NodeID
+
+	// Structural changes
+	Parent    NodeID
+	OldParent NodeID // Moves only
+	Index     int    // Child index for inserts and moves
+
+	// Dirty changes: the flags that were newly set
+	Flags DirtyFlags
+
+	// Bounds changes
+	OldBounds Bounds
+	NewBounds Bounds
+
+	// Tree version when the mutation was recorded
+	Version uint64
+}
+
+// mutationLog queues mutations between flushes and tracks observers
+type mutationLog struct {
+	observers observer.List[Mutation]
+	pending   []Mutation
+	mu        sync.Mutex
+}
+
+// Observe registers a callback for tree mutations and returns a function
+// that unsubscribes it. Mutations are batched: they are queued as they happen
+// and delivered in order on the next FlushMutations (once per frame when
+// driven by the render pipeline).
+func (t *Tree) Observe(callback func(Mutation)) (unsubscribe func()) {
+	log := &t.mutations
+	remove := log.observers.Add(callback)
+
+	return func() {
+		remove()
+
+		// Nobody is left to deliver the queue to
+		log.mu.Lock()
+		defer log.mu.Unlock()
+		if log.observers.Len() == 0 {
+			log.pending = nil
+		}
+	}
+}
+
+// FlushMutations delivers all queued mutations to the current observers
+// and returns the number of mutations delivered
+func (t *Tree) FlushMutations() int {
+	log := &t.mutations
+	log.mu.Lock()
+	batch := log.pending
+	log.pending = nil
+	log.mu.Unlock()
+
+	// Deliver outside the lock so observers may read or mutate the tree
+	observers := log.observers.Snapshot()
+	for _, m := range batch {
+		for _, callback := range observers {
+			callback(m)
+		}
+	}
+
+	return len(batch)
+}
+
+// PendingMutations returns the number of mutations waiting to be flushed
+func (t *Tree) PendingMutations() int {
+	t.mutations.mu.Lock()
+	defer t.mutations.mu.Unlock()
+	return len(t.mutations.pending)
+}
+
+// record queues a mutation if anybody is observing
+func (t *Tree) record(m Mutation) {
+	if t.mutations.observers.Len() == 0 {
+		return
+	}
+
+	m.Version = t.version.Load()
+
+	t.mutations.mu.Lock()
+	t.mutations.pending = append(t.mutations.pending, m)
+	t.mutations.mu.Unlock()
+}
+
+// attach marks a subtree as owned by the tree so node-level changes are recorded
+func (t *Tree) attach(node *Node) {
+	t.visitAll(node, func(n *Node) {
+		n.owner.Store(t)
+	})
+}
+
+// detach stops recording node-level changes for a subtree
+func (t *Tree) detach(node *Node) {
+	t.visitAll(node, func(n *Node) {
+		n.owner.CompareAndSwap(t, nil)
+	})
+}
+
+// adopt brings a subtree added with AddChild under a tree node into the
+// tree, indexing and attaching it and recording the insertion
+func (t *Tree) adopt(parent, child *Node, index int) {
+	t.mu.Lock()
+	t.indexSubtree(child)
+	t.version.Add(1)
+	t.mu.Unlock()
+
+	t.attach(child)
+	t.record(Mutation{Type: MutationInsert, Target: child.ID, Parent: parent.ID, Index: index})
+}
+
+// disown takes a subtree removed with RemoveChild out of the tree
+func (t *Tree) disown(parent, child *Node) {
+	t.mu.Lock()
+	t.removeFromIndex(child)
+	t.version.Add(1)
+	t.mu.Unlock()
+
+	t.detach(child)
+	t.record(Mutation{Type: MutationRemove, Target: child.ID, Parent: parent.ID})
+}
+
+// notify records a mutation on the tree owning the node, if any
+func (n *Node) notify(m Mutation) {
+	if t := n.owner.Load(); t != nil {
+		t.record(m)
+	}
+}
+
+// parentID returns the ID of the node's parent, or "" for roots
+func (n *Node) parentID() NodeID {
+	if parent := n.GetParent(); parent != nil {
+		return parent.ID
+	}
+	return ""
+}
@@ -0,0 +1,246 @@
+package core
+
+import (
+	"testing"
+)
+
+// collectMutations subscribes to a tree and returns the recorded slice
+func collectMutations(tree *Tree) (*[]Mutation, func()) {
+	var got []Mutation
+	unsubscribe := tree.Observe(func(m Mutation) {
+		got = append(got, m)
+	})
+	return &got, unsubscribe
+}
+
+// TestTree_Observe tests mutation records and observer lifecycle
+func TestTree_Observe(t *testing.T) {
+	t.Run("set_root_and_insert", func(t *testing.T) {
+		tree := NewTree()
+		got, unsubscribe := collectMutations(tree)
+		defer unsubscribe()
+
+		root := NewNode("root", &mockWidget{})
+		tree.SetRoot(root)
+		child := NewNode("child", &mockWidget{})
+		tree.InsertNode(root, child, 0)
+
+		if len(*got) != 0 {
+			t.Fatal("Mutations should be batched until flush")
+		}
+		if tree.PendingMutations() == 0 {
+			t.Fatal("Mutations should be pending")
+		}
+
+		tree.FlushMutations()
+
+		if len(*got) < 2 {
+			t.Fatalf("Expected at least 2 mutations, got %d", len(*got))
+		}
+		if m := (*got)[0]; m.Type != MutationInsert || m.Target != "root" || m.Parent != "" {
+			t.Errorf("Expected root insert, got %+v", m)
+		}
+		if m := (*got)[1]; m.Type != MutationInsert || m.Target != "child" || m.Parent != "root" || m.Index != 0 {
+			t.Errorf("Expected child insert, got %+v", m)
+		}
+		if tree.PendingMutations() != 0 {
+			t.Error("Flush should empty the queue")
+		}
+	})
+
+	t.Run("dirty_and_bounds", func(t *testing.T) {
+		tree := NewTree()
+		root := NewNode("root", &mockWidget{})
+		child := NewNode("child", &mockWidget{})
+		root.AddChild(child)
+		tree.SetRoot(root)
+		root.ClearDirty()
+
+		got, unsubscribe := collectMutations(tree)
+		defer unsubscribe()
+
+		child.MarkDirty(LayoutDirty)
+		child.MarkDirty(LayoutDirty) // No change, no record
+		child.SetBounds(Bounds{Width: 10, Height: 5})
+		child.SetBounds(Bounds{Width: 10, Height: 5}) // No change, no record
+		tree.FlushMutations()
+
+		var dirty, bounds []Mutation
+		for _, m := range *got {
+			switch m.Type {
+			case MutationDirty:
+				dirty = append(dirty, m)
+			case MutationBounds:
+				bounds = append(bounds, m)
+			}
+		}
+
+		if len(dirty) != 2 {
+			t.Fatalf("Expected dirty records for child and root, got %d", len(dirty))
+		}
+		if dirty[0].Target != "child" || dirty[0].Flags != LayoutDirty || dirty[0].Parent != "root" {
+			t.Errorf("Unexpected child dirty record %+v", dirty[0])
+		}
+		if dirty[1].Target != "root" || dirty[1].Flags != ChildrenDirty {
+			t.Errorf("Unexpected root dirty record %+v", dirty[1])
+		}
+
+		if len(bounds) != 1 {
+			t.Fatalf("Expected 1 bounds record, got %d", len(bounds))
+		}
+		if bounds[0].NewBounds.Width != 10 || bounds[0].OldBounds.Width != 0 {
+			t.Errorf("Unexpected bounds record %+v", bounds[0])
+		}
+	})
+
+	t.Run("remove_and_move", func(t *testing.T) {
+		tree := NewTree()
+		root := NewNode("root", &mockWidget{})
+		a := NewNode("a", &mockWidget{})
+		b := NewNode("b", &mockWidget{})
+		leaf := NewNode("leaf", &mockWidget{})
+		root.AddChild(a)
+		root.AddChild(b)
+		a.AddChild(leaf)
+		tree.SetRoot(root)
+
+		got, unsubscribe := collectMutations(tree)
+		defer unsubscribe()
+
+		if !tree.MoveNode(leaf, b, 0) {
+			t.Fatal("MoveNode should succeed")
+		}
+		if tree.MoveNode(a, a, 0) {
+			t.Error("Moving a node under itself should fail")
+		}
+		if !tree.RemoveNode(b) {
+			t.Fatal("RemoveNode should succeed")
+		}
+
+		// Removed subtree no longer reports
+		leaf.MarkDirty(PaintDirty)
+		tree.FlushMutations()
+
+		var structural []Mutation
+		for _, m := range *got {
+			if m.Type == MutationMove || m.Type == MutationRemove {
+				structural = append(structural, m)
+			}
+			if m.Type == MutationDirty && m.Target == "leaf" && m.Flags == PaintDirty {
+				t.Error("Detached node should not record mutations")
+			}
+		}
+
+		if len(structural) != 2 {
+			t.Fatalf("Expected move and remove, got %+v", structural)
+		}
+		if m := structural[0]; m.Type != MutationMove || m.OldParent != "a" || m.Parent != "b" {
+			t.Errorf("Unexpected move record %+v", m)
+		}
+		if m := structural[1]; m.Type != MutationRemove || m.Target != "b" || m.Parent != "root" {
+			t.Errorf("Unexpected remove record %+v", m)
+		}
+	})
+
+	t.Run("add_and_remove_child", func(t *testing.T) {
+		tree := NewTree()
+		root := NewNode("root", &mockWidget{})
+		tree.SetRoot(root)
+
+		got, unsubscribe := collectMutations(tree)
+		defer unsubscribe()
+
+		// A subtree built before it is added joins the tree as a whole
+		panel := NewNode("panel", &mockWidget{})
+		label := NewNode("label", &mockWidget{})
+		panel.AddChild(label)
+		root.AddChild(panel)
+
+		if tree.FindNodeByID("label") != label || tree.NodeCount() != 3 {
+			t.Errorf("Added subtree should be indexed, found %d nodes", tree.NodeCount())
+		}
+		label.ClearDirty()
+		label.MarkDirty(PaintDirty)
+		label.SetBounds(Bounds{Width: 10})
+		tree.FlushMutations()
+
+		var types []MutationType
+		for _, m := range *got {
+			if m.Target == "panel" && m.Type == MutationInsert && (m.Parent != "root" || m.Index != 0) {
+				t.Errorf("Unexpected insert record %+v", m)
+			}
+			if m.Target == "panel" || m.Target == "label" {
+				types = append(types, m.Type)
+			}
+		}
+		want := []MutationType{MutationInsert, MutationDirty, MutationBounds}
+		if len(types) != len(want) || types[0] != want[0] || types[1] != want[1] || types[2] != want[2] {
+			t.Errorf("Expected insert, dirty and bounds records, got %v", types)
+		}
+
+		*got = nil
+		if !root.RemoveChild(panel) {
+			t.Fatal("RemoveChild should succeed")
+		}
+		label.MarkDirty(LayoutDirty)
+		tree.FlushMutations()
+		if len(*got) == 0 || (*got)[0].Type != MutationRemove || (*got)[0].Target != "panel" {
+			t.Fatalf("Expected a remove record, got %+v", *got)
+		}
+		for _, m := range *got {
+			if m.Target == "label" {
+				t.Error("Removed subtree should no longer record mutations")
+			}
+		}
+		if tree.FindNodeByID("label") != nil || tree.NodeCount() != 1 {
+			t.Error("Removed subtree should leave the index")
+		}
+
+		// Nodes of another tree cannot be moved into this one
+		other := NewTree()
+		stranger := NewNode("stranger", &mockWidget{})
+		otherRoot := NewNode("other", &mockWidget{})
+		otherRoot.AddChild(stranger)
+		other.SetRoot(otherRoot)
+		if tree.MoveNode(stranger, root, 0) || tree.MoveNode(panel, root, 0) {
+			t.Error("MoveNode should reject nodes outside the tree")
+		}
+		if other.MoveNode(stranger, root, 0) {
+			t.Error("MoveNode should reject parents outside the tree")
+		}
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		tree := NewTree()
+		root := NewNode("root", &mockWidget{})
+		tree.SetRoot(root)
+
+		first, unsubscribeFirst := collectMutations(tree)
+		second, unsubscribeSecond := collectMutations(tree)
+		defer unsubscribeSecond()
+
+		unsubscribeFirst()
+		unsubscribeFirst() // Idempotent
+
+		root.MarkDirty(PaintDirty)
+		tree.FlushMutations()
+
+		if len(*first) != 0 {
+			t.Error("Unsubscribed observer should not receive mutations")
+		}
+		if len(*second) != 1 {
+			t.Errorf("Expected 1 mutation, got %d", len(*second))
+		}
+	})
+
+	t.Run("no_observers_no_queue", func(t *testing.T) {
+		tree := NewTree()
+		root := NewNode("root", &mockWidget{})
+		tree.SetRoot(root)
+		root.MarkDirty(LayoutDirty)
+
+		if tree.PendingMutations() != 0 {
+			t.Error("Mutations should not be queued without observers")
+		}
+	})
+}
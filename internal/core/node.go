@@ -21,7 +21,7 @@ const (
 	TransformDirty
 )
 
-// Transform represents 2D transformation matrix
+// Transform represents 2D transformation matrix (see transform.go)
 type Transform struct {
 	Matrix [6]float64 // 2x3 affine transformation matrix
 }
@@ -73,6 +73,12 @@ type Node struct {
 	
 	// Weak cache for computed values (Go 1.24)
 	weakCache *weak.Pointer[ComputedValues]
+	
+	// Strong reference keeping the cache alive while retained (see cache.go)
+	strongCache atomic.Pointer[ComputedValues]
+	
+	// Tree that records mutations for this node (nil when detached)
+	owner atomic.Pointer[Tree]
 }
 
 // ComputedValues holds cached computed values for a node
@@ -80,6 +86,12 @@ type ComputedValues struct {
 	Layout     LayoutData
 	Paint      PaintData
 	HitRegion  *HitRegion
+	
+	// Cache key: node version, widget revision and constraints the
+	// layout was computed for
+	Version        uint64
+	WidgetRevision uint64
+	Constraints    Constraints
 }
 
 // LayoutData contains computed layout information
@@ -132,6 +144,9 @@ func NewNode(id string, widget Widget) *Node {
 		cd := &cleanupData{widget: widget}
 		runtime.AddCleanup(node, cleanup, cd)
 	}
+	if attachable, ok := widget.(Attachable); ok {
+		attachable.AttachNode(node)
+	}
 	
 	return node
 }
@@ -157,15 +172,31 @@ func (n *Node) SetParent(parent *Node) {
 	}
 }
 
-// AddChild adds a child node
+// AddChild adds a child node. If the node belongs to a tree, the child's
+// subtree joins it and the insertion is recorded.
 func (n *Node) AddChild(child *Node) {
 	n.Children = append(n.Children, child)
 	child.SetParent(n)
+	if t := n.owner.Load(); t != nil {
+		t.adopt(n, child, len(n.Children)-1)
+	}
 	n.markDirty(ChildrenDirty)
 }
 
-// RemoveChild removes a child node
+// RemoveChild removes a child node. If the node belongs to a tree, the
+// child's subtree leaves it and the removal is recorded.
 func (n *Node) RemoveChild(child *Node) bool {
+	if !n.removeChild(child) {
+		return false
+	}
+	if t := n.owner.Load(); t != nil {
+		t.disown(n, child)
+	}
+	return true
+}
+
+// removeChild unlinks a child without updating the owning tree
+func (n *Node) removeChild(child *Node) bool {
 	for i, c := range n.Children {
 		if c == child {
 			// Remove without preserving order for performance
@@ -195,6 +226,13 @@ func (n *Node) markDirty(flags DirtyFlags) {
 		n.isDirty.Store(true)
 		n.version.Add(1)
 		
+		n.notify(Mutation{
+			Type:   MutationDirty,
+			Target: n.ID,
+			Parent: n.parentID(),
+			Flags:  newFlags &^ oldFlags,
+		})
+		
 		// Propagate to parent if needed
 		if parent := n.GetParent(); parent != nil {
 			parent.markDirty(ChildrenDirty)
@@ -223,8 +261,52 @@ func (n *Node) GetVersion() uint64 {
 	return n.version.Load()
 }
 
+// SetBounds updates the node's bounds, recording a mutation if they changed
+func (n *Node) SetBounds(bounds Bounds) {
+	if n.Bounds == bounds {
+		return
+	}
+	old := n.Bounds
+	n.Bounds = bounds
+	
+	n.notify(Mutation{
+		Type:      MutationBounds,
+		Target:    n.ID,
+		Parent:    n.parentID(),
+		OldBounds: old,
+		NewBounds: bounds,
+	})
+}
+
+// SetTransform replaces the node's local transform.
+// Transforms do not affect layout, so only paint is invalidated.
+func (n *Node) SetTransform(transform Transform) {
+	if n.Transform == transform {
+		return
+	}
+	n.Transform = transform
+	n.markDirty(TransformDirty | PaintDirty)
+}
+
+// LocalTransform maps node-local coordinates into the parent's coordinate space
+func (n *Node) LocalTransform() Transform {
+	return TranslateTransform(n.Bounds.X, n.Bounds.Y).Multiply(n.Transform)
+}
+
+// WorldTransform maps node-local coordinates into root coordinates
+func (n *Node) WorldTransform() Transform {
+	world := n.LocalTransform()
+	for ancestor := range n.Ancestors() {
+		world = ancestor.LocalTransform().Multiply(world)
+	}
+	return world
+}
+
 // GetCachedValues retrieves cached computed values if available
 func (n *Node) GetCachedValues() *ComputedValues {
+	if strong := n.strongCache.Load(); strong != nil {
+		return strong
+	}
 	if n.weakCache == nil {
 		return nil
 	}
@@ -234,10 +316,15 @@ func (n *Node) GetCachedValues() *ComputedValues {
 	return nil
 }
 
-// SetCachedValues stores computed values in weak cache
+// SetCachedValues stores computed values in weak cache.
+// If the cache is currently retained, the new values are retained too.
 func (n *Node) SetCachedValues(values *ComputedValues) {
 	wc := weak.Make(values)
 	n.weakCache = &wc
+	
+	if n.strongCache.Load() != nil {
+		n.strongCache.Store(values)
+	}
 }
 
 // Widget interface that all UI widgets must implement
@@ -258,6 +345,20 @@ type Widget interface {
 	GetIntrinsicHeight(width float64) float64
 }
 
+// Revisioned is implemented by widgets whose size depends on state that
+// can change without the node being marked dirty, such as a text's content.
+// Revision must change whenever that state does.
+type Revisioned interface {
+	Revision() uint64
+}
+
+// Attachable is implemented by widgets that mark their node dirty
+// themselves when their state changes, so the render pipeline finds them
+// without scanning the tree. NewNode attaches the widget to its node.
+type Attachable interface {
+	AttachNode(node *Node)
+}
+
 // Disposable interface for widgets that need cleanup
 type Disposable interface {
 	Dispose()
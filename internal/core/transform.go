@@ -0,0 +1,121 @@
+package core
+
+import "math"
+
+// Matrix layout follows the CSS/Canvas convention matrix(a, b, c, d, e, f):
+//
+//	| a c e |
+//	| b d f |
+//	| 0 0 1 |
+//
+// so a point is mapped as x' = a*x + c*y + e, y' = b*x + d*y + f.
+// The zero value of Transform is treated as the identity so that nodes
+// created without an explicit transform are untransformed. As a consequence
+// a bare ScaleTransform(0, 0) also reads as identity; collapse a node along a
+// single axis (or hide it) instead.
+
+// IdentityTransform returns the identity transform
+func IdentityTransform() Transform {
+	return Transform{Matrix: [6]float64{1, 0, 0, 1, 0, 0}}
+}
+
+// TranslateTransform returns a translation by (tx, ty)
+func TranslateTransform(tx, ty float64) Transform {
+	return Transform{Matrix: [6]float64{1, 0, 0, 1, tx, ty}}
+}
+
+// ScaleTransform returns a scale by (sx, sy)
+func ScaleTransform(sx, sy float64) Transform {
+	return Transform{Matrix: [6]float64{sx, 0, 0, sy, 0, 0}}
+}
+
+// RotateTransform returns a clockwise rotation (in screen space) by radians
+func RotateTransform(radians float64) Transform {
+	sin, cos := math.Sincos(radians)
+	return Transform{Matrix: [6]float64{cos, sin, -sin, cos, 0, 0}}
+}
+
+// SkewTransform returns a skew by ax radians along X and ay radians along Y
+func SkewTransform(ax, ay float64) Transform {
+	return Transform{Matrix: [6]float64{1, math.Tan(ay), math.Tan(ax), 1, 0, 0}}
+}
+
+// matrix returns the effective matrix, mapping the zero value to identity
+func (t Transform) matrix() [6]float64 {
+	if t.Matrix == ([6]float64{}) {
+		return IdentityTransform().Matrix
+	}
+	return t.Matrix
+}
+
+// IsIdentity reports whether the transform leaves points unchanged
+func (t Transform) IsIdentity() bool {
+	return t.matrix() == IdentityTransform().Matrix
+}
+
+// Multiply returns t × other, i.e. other is applied first and t second
+func (t Transform) Multiply(other Transform) Transform {
+	m := t.matrix()
+	o := other.matrix()
+	return Transform{Matrix: [6]float64{
+		m[0]*o[0] + m[2]*o[1],
+		m[1]*o[0] + m[3]*o[1],
+		m[0]*o[2] + m[2]*o[3],
+		m[1]*o[2] + m[3]*o[3],
+		m[0]*o[4] + m[2]*o[5] + m[4],
+		m[1]*o[4] + m[3]*o[5] + m[5],
+	}}
+}
+
+// WithOrigin returns the transform applied around (x, y) instead of (0, 0)
+func (t Transform) WithOrigin(x, y float64) Transform {
+	return TranslateTransform(x, y).Multiply(t).Multiply(TranslateTransform(-x, -y))
+}
+
+// Invert returns the inverse transform; ok is false if t is not invertible
+func (t Transform) Invert() (inverse Transform, ok bool) {
+	m := t.matrix()
+	det := m[0]*m[3] - m[1]*m[2]
+	if det == 0 || math.IsNaN(det) || math.IsInf(det, 0) {
+		return Transform{}, false
+	}
+
+	return Transform{Matrix: [6]float64{
+		m[3] / det,
+		-m[1] / det,
+		-m[2] / det,
+		m[0] / det,
+		(m[2]*m[5] - m[3]*m[4]) / det,
+		(m[1]*m[4] - m[0]*m[5]) / det,
+	}}, true
+}
+
+// Apply maps a point through the transform
+func (t Transform) Apply(p Offset) Offset {
+	m := t.matrix()
+	return Offset{
+		X: m[0]*p.X + m[2]*p.Y + m[4],
+		Y: m[1]*p.X + m[3]*p.Y + m[5],
+	}
+}
+
+// ApplyBounds returns the axis-aligned bounding box of the transformed bounds
+func (t Transform) ApplyBounds(b Bounds) Bounds {
+	corners := [4]Offset{
+		t.Apply(Offset{X: b.X, Y: b.Y}),
+		t.Apply(Offset{X: b.X + b.Width, Y: b.Y}),
+		t.Apply(Offset{X: b.X, Y: b.Y + b.Height}),
+		t.Apply(Offset{X: b.X + b.Width, Y: b.Y + b.Height}),
+	}
+
+	minX, minY := corners[0].X, corners[0].Y
+	maxX, maxY := minX, minY
+	for _, c := range corners[1:] {
+		minX = math.Min(minX, c.X)
+		minY = math.Min(minY, c.Y)
+		maxX = math.Max(maxX, c.X)
+		maxY = math.Max(maxY, c.Y)
+	}
+
+	return Bounds{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func offsetsClose(a, b Offset) bool {
+	const epsilon = 1e-9
+	return math.Abs(a.X-b.X) < epsilon && math.Abs(a.Y-b.Y) < epsilon
+}
+
+// TestTransform_Math tests matrix construction, composition and inversion
+func TestTransform_Math(t *testing.T) {
+	t.Run("zero_value_is_identity", func(t *testing.T) {
+		var tr Transform
+		if !tr.IsIdentity() {
+			t.Error("Zero value transform should be identity")
+		}
+
+		p := Offset{X: 3, Y: 4}
+		if got := tr.Apply(p); got != p {
+			t.Errorf("Zero value transform moved point to %v", got)
+		}
+	})
+
+	t.Run("translate_scale_compose", func(t *testing.T) {
+		// Scale first, then translate
+		tr := TranslateTransform(10, 20).Multiply(ScaleTransform(2, 3))
+
+		got := tr.Apply(Offset{X: 1, Y: 1})
+		if !offsetsClose(got, Offset{X: 12, Y: 23}) {
+			t.Errorf("Expected (12, 23), got %v", got)
+		}
+	})
+
+	t.Run("rotate_with_origin", func(t *testing.T) {
+		// Rotate 90 degrees around the center of a 10x10 box
+		tr := RotateTransform(math.Pi/2).WithOrigin(5, 5)
+
+		if got := tr.Apply(Offset{X: 5, Y: 5}); !offsetsClose(got, Offset{X: 5, Y: 5}) {
+			t.Errorf("Origin should be fixed, got %v", got)
+		}
+
+		if got := tr.Apply(Offset{X: 10, Y: 5}); !offsetsClose(got, Offset{X: 5, Y: 10}) {
+			t.Errorf("Expected (5, 10), got %v", got)
+		}
+	})
+
+	t.Run("skew", func(t *testing.T) {
+		tr := SkewTransform(math.Pi/4, 0)
+
+		if got := tr.Apply(Offset{X: 0, Y: 10}); !offsetsClose(got, Offset{X: 10, Y: 10}) {
+			t.Errorf("Expected (10, 10), got %v", got)
+		}
+	})
+
+	t.Run("invert_round_trip", func(t *testing.T) {
+		tr := TranslateTransform(7, -3).
+			Multiply(RotateTransform(0.7)).
+			Multiply(SkewTransform(0.2, 0.1)).
+			Multiply(ScaleTransform(2, 0.5))
+
+		inverse, ok := tr.Invert()
+		if !ok {
+			t.Fatal("Transform should be invertible")
+		}
+
+		p := Offset{X: 13, Y: -8}
+		if got := inverse.Apply(tr.Apply(p)); !offsetsClose(got, p) {
+			t.Errorf("Round trip moved point from %v to %v", p, got)
+		}
+	})
+
+	t.Run("singular_not_invertible", func(t *testing.T) {
+		if _, ok := ScaleTransform(0, 1).Invert(); ok {
+			t.Error("Zero scale should not be invertible")
+		}
+	})
+
+	t.Run("apply_bounds", func(t *testing.T) {
+		b := RotateTransform(math.Pi / 2).ApplyBounds(Bounds{Width: 20, Height: 10})
+
+		if math.Abs(b.X+10) > 1e-9 || math.Abs(b.Width-10) > 1e-9 || math.Abs(b.Height-20) > 1e-9 {
+			t.Errorf("Unexpected rotated bounds %+v", b)
+		}
+	})
+}
+
+// TestNode_Transform tests transform invalidation and composition on nodes
+func TestNode_Transform(t *testing.T) {
+	t.Run("set_transform_invalidates_paint_only", func(t *testing.T) {
+		parent := NewNode("parent", &mockWidget{})
+		node := NewNode("node", &mockWidget{})
+		parent.AddChild(node)
+		parent.ClearDirty()
+
+		version := node.GetVersion()
+		node.SetTransform(ScaleTransform(2, 2))
+
+		flags := node.GetDirtyFlags()
+		if flags&TransformDirty == 0 || flags&PaintDirty == 0 {
+			t.Error("SetTransform should set TransformDirty and PaintDirty")
+		}
+		if flags&LayoutDirty != 0 {
+			t.Error("SetTransform should not set LayoutDirty")
+		}
+		if node.GetVersion() <= version {
+			t.Error("SetTransform should bump version")
+		}
+
+		node.ClearDirty()
+		node.SetTransform(ScaleTransform(2, 2))
+		if node.IsDirty() {
+			t.Error("Setting the same transform should not dirty the node")
+		}
+	})
+
+	t.Run("world_transform_composes_ancestors", func(t *testing.T) {
+		root := NewNode("root", &mockWidget{})
+		child := NewNode("child", &mockWidget{})
+		root.AddChild(child)
+
+		root.Bounds = Bounds{X: 0, Y: 0, Width: 100, Height: 100}
+		root.SetTransform(ScaleTransform(2, 2))
+		child.Bounds = Bounds{X: 10, Y: 5, Width: 10, Height: 10}
+
+		got := child.WorldTransform().Apply(Offset{X: 1, Y: 1})
+		if !offsetsClose(got, Offset{X: 22, Y: 12}) {
+			t.Errorf("Expected (22, 12), got %v", got)
+		}
+	})
+}
+
+// TestTree_HitTest tests pointer hit testing through transforms
+func TestTree_HitTest(t *testing.T) {
+	tree := NewTree()
+	root := NewNode("root", &mockWidget{})
+	child := NewNode("child", &mockWidget{})
+	root.AddChild(child)
+	tree.SetRoot(root)
+
+	root.Bounds = Bounds{Width: 200, Height: 200}
+	child.Bounds = Bounds{X: 50, Y: 50, Width: 40, Height: 20}
+
+	t.Run("untransformed", func(t *testing.T) {
+		if hit := tree.HitTest(Offset{X: 60, Y: 60}); hit != child {
+			t.Errorf("Expected child, got %v", hit)
+		}
+		if hit := tree.HitTest(Offset{X: 10, Y: 10}); hit != root {
+			t.Errorf("Expected root, got %v", hit)
+		}
+		if hit := tree.HitTest(Offset{X: 500, Y: 500}); hit != nil {
+			t.Errorf("Expected no hit, got %v", hit.ID)
+		}
+	})
+
+	t.Run("rotated_child", func(t *testing.T) {
+		// Rotate 90 degrees around the child's center (20, 10): it now
+		// covers x in [60, 80] and y in [40, 80] in root coordinates
+		child.SetTransform(RotateTransform(math.Pi/2).WithOrigin(20, 10))
+		defer child.SetTransform(Transform{})
+
+		if hit := tree.HitTest(Offset{X: 70, Y: 45}); hit != child {
+			t.Error("Point inside rotated child should hit child")
+		}
+		if hit := tree.HitTest(Offset{X: 55, Y: 65}); hit != root {
+			t.Error("Point outside rotated child should hit root")
+		}
+	})
+
+	t.Run("singular_transform_not_hit", func(t *testing.T) {
+		child.SetTransform(ScaleTransform(0, 1))
+		defer child.SetTransform(Transform{})
+
+		if hit := tree.HitTest(Offset{X: 50, Y: 50}); hit != root {
+			t.Error("Collapsed child should not be hit")
+		}
+	})
+}
@@ -1,7 +1,11 @@
 package core
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"iter"
+	"runtime"
 	"sync"
 	"sync/atomic"
 )
@@ -18,6 +22,14 @@ type Tree struct {
 	
 	// Stats for monitoring
 	stats TreeStats
+	
+	// Queued mutations and their observers
+	mutations mutationLog
+	
+	// Computed value cache counters (see cache.go)
+	cacheHits      atomic.Int64
+	cacheMisses    atomic.Int64
+	retainedCaches atomic.Int64
 }
 
 // TreeStats contains performance statistics
@@ -27,6 +39,11 @@ type TreeStats struct {
 	LastTraversalMs int64
 	LastLayoutMs    int64
 	LastPaintMs     int64
+	
+	// Computed value cache
+	CacheHits      int64
+	CacheMisses    int64
+	RetainedCaches int64
 }
 
 // NewTree creates a new UI tree
@@ -41,9 +58,22 @@ func (t *Tree) SetRoot(root *Node) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	
+	old := t.root
+	if old != nil && old != root {
+		t.detach(old)
+	}
+	
 	t.root = root
 	t.version.Add(1)
 	t.rebuildIndex()
+	
+	if old != nil && old != root {
+		t.record(Mutation{Type: MutationRemove, Target: old.ID})
+	}
+	if root != nil {
+		t.attach(root)
+		t.record(Mutation{Type: MutationInsert, Target: root.ID})
+	}
 }
 
 // GetRoot returns the root node
@@ -289,8 +319,53 @@ func (n *Node) Siblings() iter.Seq[*Node] {
 // Parallel Traversal
 // =============================================================================
 
+// ParallelOptions configures ParallelSubtreesContext
+type ParallelOptions struct {
+	// MaxConcurrency bounds the number of subtrees processed at once.
+	// Zero or negative means runtime.GOMAXPROCS(0).
+	MaxConcurrency int
+	
+	// MinSubtreeSize is the node count below which a subtree is processed
+	// inline on the calling goroutine instead of being spawned.
+	MinSubtreeSize int
+}
+
+// DefaultParallelOptions returns options suitable for layout-sized work
+func DefaultParallelOptions() ParallelOptions {
+	return ParallelOptions{
+		MaxConcurrency: runtime.GOMAXPROCS(0),
+		MinSubtreeSize: 32,
+	}
+}
+
+// SubtreeError reports the failure of a single root subtree
+type SubtreeError struct {
+	Root NodeID
+	Err  error
+}
+
+func (e *SubtreeError) Error() string {
+	return fmt.Sprintf("subtree %s: %v", e.Root, e.Err)
+}
+
+func (e *SubtreeError) Unwrap() error {
+	return e.Err
+}
+
 // ParallelSubtrees processes subtrees in parallel
 func (t *Tree) ParallelSubtrees(processor func(*Node) error) error {
+	return t.ParallelSubtreesContext(context.Background(), DefaultParallelOptions(),
+		func(_ context.Context, node *Node) error {
+			return processor(node)
+		})
+}
+
+// ParallelSubtreesContext processes each of the root's child subtrees in
+// post-order, running up to opts.MaxConcurrency subtrees at once, and then
+// processes the root. Every failing subtree is reported as a *SubtreeError,
+// joined in child order so the result is deterministic; the root is only
+// processed when all subtrees succeed.
+func (t *Tree) ParallelSubtreesContext(ctx context.Context, opts ParallelOptions, processor func(context.Context, *Node) error) error {
 	t.mu.RLock()
 	root := t.root
 	t.mu.RUnlock()
@@ -299,44 +374,138 @@ func (t *Tree) ParallelSubtrees(processor func(*Node) error) error {
 		return nil
 	}
 	
-	// Process root's children in parallel
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	
+	limit := opts.MaxConcurrency
+	if limit <= 0 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+	semaphore := make(chan struct{}, limit)
+	
+	children := root.Children
+	errs := make([]error, len(children))
 	var wg sync.WaitGroup
-	errChan := make(chan error, len(root.Children))
 	
-	for _, child := range root.Children {
+	for i, child := range children {
+		// Tiny subtrees aren't worth a goroutine
+		if opts.MinSubtreeSize > 0 && subtreeSize(child, opts.MinSubtreeSize) < opts.MinSubtreeSize {
+			errs[i] = t.processSubtreeContext(ctx, child, processor)
+			continue
+		}
+		
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+		
 		wg.Add(1)
-		go func(node *Node) {
+		go func(i int, node *Node) {
 			defer wg.Done()
-			if err := t.processSubtree(node, processor); err != nil {
-				errChan <- err
-			}
-		}(child)
+			defer func() { <-semaphore }()
+			errs[i] = t.processSubtreeContext(ctx, node, processor)
+		}(i, child)
 	}
 	
 	wg.Wait()
-	close(errChan)
 	
-	// Check for errors
-	for err := range errChan {
+	// Collect errors in child order
+	var failures []error
+	for i, err := range errs {
 		if err != nil {
-			return err
+			failures = append(failures, &SubtreeError{Root: children[i].ID, Err: err})
 		}
 	}
+	if len(failures) > 0 {
+		return errors.Join(failures...)
+	}
+	
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	
 	// Process root node
-	return processor(root)
+	return processor(ctx, root)
 }
 
 // processSubtree processes a subtree recursively
 func (t *Tree) processSubtree(node *Node, processor func(*Node) error) error {
+	return t.processSubtreeContext(context.Background(), node, func(_ context.Context, n *Node) error {
+		return processor(n)
+	})
+}
+
+// processSubtreeContext processes a subtree recursively, stopping on cancellation
+func (t *Tree) processSubtreeContext(ctx context.Context, node *Node, processor func(context.Context, *Node) error) error {
 	// Process children first (post-order)
 	for _, child := range node.Children {
-		if err := t.processSubtree(child, processor); err != nil {
+		if err := t.processSubtreeContext(ctx, child, processor); err != nil {
 			return err
 		}
 	}
 	
-	return processor(node)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	
+	return processor(ctx, node)
+}
+
+// subtreeSize counts nodes in a subtree, stopping once limit is reached
+func subtreeSize(node *Node, limit int) int {
+	count := 1
+	for _, child := range node.Children {
+		if count >= limit {
+			break
+		}
+		count += subtreeSize(child, limit-count)
+	}
+	return count
+}
+
+// =============================================================================
+// Hit Testing
+// =============================================================================
+
+// HitTest returns the top-most node containing the point (in root coordinates).
+// The point is mapped through the inverse of each node's transform, so rotated,
+// scaled or skewed nodes are hit where they are drawn.
+func (t *Tree) HitTest(point Offset) *Node {
+	t.mu.RLock()
+	root := t.root
+	t.mu.RUnlock()
+	
+	if root == nil {
+		return nil
+	}
+	
+	return hitTestNode(root, point)
+}
+
+// hitTestNode tests a node given a point in its parent's coordinate space
+func hitTestNode(node *Node, point Offset) *Node {
+	inverse, ok := node.LocalTransform().Invert()
+	if !ok {
+		return nil
+	}
+	local := inverse.Apply(point)
+	
+	// Later children paint on top, so test them first
+	for i := len(node.Children) - 1; i >= 0; i-- {
+		if hit := hitTestNode(node.Children[i], local); hit != nil {
+			return hit
+		}
+	}
+	
+	if local.X >= 0 && local.X <= node.Bounds.Width &&
+		local.Y >= 0 && local.Y <= node.Bounds.Height {
+		return node
+	}
+	
+	return nil
 }
 
 // =============================================================================
@@ -367,14 +536,86 @@ func (t *Tree) InsertNode(parent *Node, child *Node, index int) {
 	child.SetParent(parent)
 	
 	// Update index
-	t.nodeIndex[child.ID] = child
-	t.nodeCount.Add(1)
+	t.indexSubtree(child)
 	t.version.Add(1)
 	
+	t.attach(child)
+	t.record(Mutation{Type: MutationInsert, Target: child.ID, Parent: parent.ID, Index: index})
+	
 	// Mark parent as dirty
 	parent.MarkDirty(ChildrenDirty)
 }
 
+// MoveNode moves a node (with its subtree) under a new parent at a position
+func (t *Tree) MoveNode(node *Node, newParent *Node, index int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	
+	if node == nil || newParent == nil || node == t.root {
+		return false
+	}
+	
+	// Both ends must belong to this tree
+	if node.owner.Load() != t || newParent.owner.Load() != t {
+		return false
+	}
+	
+	// Refuse to move a node into its own subtree
+	for ancestor := range newParent.Ancestors() {
+		if ancestor == node {
+			return false
+		}
+	}
+	if newParent == node {
+		return false
+	}
+	
+	oldParent := node.GetParent()
+	if oldParent == nil {
+		return false
+	}
+	
+	// Detach from the old parent, preserving sibling order
+	found := false
+	for i, c := range oldParent.Children {
+		if c == node {
+			oldParent.Children = append(oldParent.Children[:i], oldParent.Children[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	
+	if index < 0 {
+		index = 0
+	}
+	if index > len(newParent.Children) {
+		index = len(newParent.Children)
+	}
+	
+	newParent.Children = append(newParent.Children, nil)
+	copy(newParent.Children[index+1:], newParent.Children[index:])
+	newParent.Children[index] = node
+	node.SetParent(newParent)
+	t.version.Add(1)
+	
+	t.record(Mutation{
+		Type:      MutationMove,
+		Target:    node.ID,
+		Parent:    newParent.ID,
+		OldParent: oldParent.ID,
+		Index:     index,
+	})
+	
+	oldParent.MarkDirty(ChildrenDirty)
+	newParent.MarkDirty(ChildrenDirty)
+	node.MarkDirty(LayoutDirty)
+	
+	return true
+}
+
 // RemoveNode removes a node from the tree
 func (t *Tree) RemoveNode(node *Node) bool {
 	t.mu.Lock()
@@ -390,16 +631,27 @@ func (t *Tree) RemoveNode(node *Node) bool {
 	}
 	
 	// Remove from parent
-	if parent.RemoveChild(node) {
+	if parent.removeChild(node) {
 		// Remove from index
 		t.removeFromIndex(node)
 		t.version.Add(1)
+		
+		t.detach(node)
+		t.record(Mutation{Type: MutationRemove, Target: node.ID, Parent: parent.ID})
 		return true
 	}
 	
 	return false
 }
 
+// indexSubtree adds a node and its descendants to the index
+func (t *Tree) indexSubtree(node *Node) {
+	t.visitAll(node, func(n *Node) {
+		t.nodeIndex[n.ID] = n
+		t.nodeCount.Add(1)
+	})
+}
+
 // removeFromIndex removes a node and its descendants from the index
 func (t *Tree) removeFromIndex(node *Node) {
 	delete(t.nodeIndex, node.ID)
@@ -417,6 +669,9 @@ func (t *Tree) GetStats() TreeStats {
 	
 	stats := t.stats
 	stats.TotalNodes = t.nodeCount.Load()
+	stats.CacheHits = t.cacheHits.Load()
+	stats.CacheMisses = t.cacheMisses.Load()
+	stats.RetainedCaches = t.retainedCaches.Load()
 	
 	// Count dirty nodes
 	dirtyCount := int64(0)
@@ -1,10 +1,13 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // TestTree_Creation tests tree creation and initialization
@@ -593,6 +596,122 @@ func TestTree_ParallelProcessing(t *testing.T) {
 			t.Error("ParallelSubtrees should not error on empty tree")
 		}
 	})
+
+	t.Run("context_bounded_concurrency", func(t *testing.T) {
+		tree := buildBenchmarkTree(500)
+
+		var active, peak atomic.Int32
+		err := tree.ParallelSubtreesContext(context.Background(),
+			ParallelOptions{MaxConcurrency: 2},
+			func(ctx context.Context, node *Node) error {
+				n := active.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Microsecond)
+				active.Add(-1)
+				return nil
+			})
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if peak.Load() > 2 {
+			t.Errorf("Expected at most 2 concurrent subtrees, saw %d", peak.Load())
+		}
+	})
+
+	t.Run("context_joined_errors", func(t *testing.T) {
+		tree := NewTree()
+		root := NewNode("root", &mockWidget{})
+		for _, id := range []string{"a", "b", "c", "d"} {
+			root.AddChild(NewNode(id, &mockWidget{}))
+		}
+		tree.SetRoot(root)
+
+		sentinel := errors.New("boom")
+		rootProcessed := false
+		err := tree.ParallelSubtreesContext(context.Background(), ParallelOptions{},
+			func(ctx context.Context, node *Node) error {
+				switch node.ID {
+				case "b", "d":
+					return sentinel
+				case "root":
+					rootProcessed = true
+				}
+				return nil
+			})
+
+		if !errors.Is(err, sentinel) {
+			t.Fatalf("Expected joined error wrapping sentinel, got %v", err)
+		}
+		if rootProcessed {
+			t.Error("Root should not be processed when a subtree fails")
+		}
+
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !ok {
+			t.Fatalf("Expected joined error, got %T", err)
+		}
+		var failed []string
+		for _, e := range joined.Unwrap() {
+			var subtreeErr *SubtreeError
+			if !errors.As(e, &subtreeErr) {
+				t.Fatalf("Expected *SubtreeError, got %T", e)
+			}
+			failed = append(failed, string(subtreeErr.Root))
+		}
+		if !sliceEqual(failed, []string{"b", "d"}) {
+			t.Errorf("Expected failures [b d] in order, got %v", failed)
+		}
+	})
+
+	t.Run("context_cancelled", func(t *testing.T) {
+		tree := buildBenchmarkTree(100)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		called := false
+		err := tree.ParallelSubtreesContext(ctx, ParallelOptions{}, func(ctx context.Context, node *Node) error {
+			called = true
+			return nil
+		})
+
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		if called {
+			t.Error("Processor should not run after cancellation")
+		}
+	})
+
+	t.Run("context_small_subtrees_inline", func(t *testing.T) {
+		tree := NewTree()
+		root := NewNode("root", &mockWidget{})
+		for i := 0; i < 4; i++ {
+			root.AddChild(NewNode(strconv.Itoa(i), &mockWidget{}))
+		}
+		tree.SetRoot(root)
+
+		// Inline processing is sequential, so order is preserved
+		var order []string
+		err := tree.ParallelSubtreesContext(context.Background(),
+			ParallelOptions{MinSubtreeSize: 10},
+			func(ctx context.Context, node *Node) error {
+				order = append(order, string(node.ID))
+				return nil
+			})
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !sliceEqual(order, []string{"0", "1", "2", "3", "root"}) {
+			t.Errorf("Expected inline post-order processing, got %v", order)
+		}
+	})
 }
 
 // TestTree_Stats tests statistics gathering
@@ -744,6 +863,43 @@ func BenchmarkTree_InsertNode(b *testing.B) {
 	}
 }
 
+// simulateLayout burns a little CPU per node, roughly like measuring a widget
+func simulateLayout(node *Node) {
+	sum := 0.0
+	for i := 0; i < 200; i++ {
+		sum += float64(i) * 0.5
+	}
+	node.intrinsicWidth = sum
+}
+
+func BenchmarkTree_LayoutSequential(b *testing.B) {
+	tree := buildWideTree(16, 625)
+
+	b.ResetTimer()
+	for b.Loop() {
+		for node := range tree.PostOrderDFS() {
+			simulateLayout(node)
+		}
+	}
+}
+
+func BenchmarkTree_LayoutParallelSubtrees(b *testing.B) {
+	tree := buildWideTree(16, 625)
+	ctx := context.Background()
+	opts := DefaultParallelOptions()
+
+	b.ResetTimer()
+	for b.Loop() {
+		err := tree.ParallelSubtreesContext(ctx, opts, func(ctx context.Context, node *Node) error {
+			simulateLayout(node)
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 // Helper functions
 func buildBenchmarkTree(nodeCount int) *Tree {
 	tree := NewTree()
@@ -765,6 +921,27 @@ func buildBenchmarkTree(nodeCount int) *Tree {
 	return tree
 }
 
+// buildWideTree builds a root with independent subtrees of a fixed size
+func buildWideTree(subtrees, subtreeSize int) *Tree {
+	tree := NewTree()
+	root := NewNode("root", &mockWidget{})
+
+	for i := 0; i < subtrees; i++ {
+		prefix := "s" + strconv.Itoa(i) + "-"
+		subtreeRoot := NewNode(prefix+"0", &mockWidget{})
+		nodes := []*Node{subtreeRoot}
+		for j := 1; j < subtreeSize; j++ {
+			node := NewNode(prefix+strconv.Itoa(j), &mockWidget{})
+			nodes[(j-1)/4].AddChild(node)
+			nodes = append(nodes, node)
+		}
+		root.AddChild(subtreeRoot)
+	}
+
+	tree.SetRoot(root)
+	return tree
+}
+
 func sliceEqual(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -0,0 +1,215 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CycleError reports a cycle in the graph.
+// Path lists the nodes along the cycle and ends where it starts,
+// e.g. [A B C A] for A -> B -> C -> A.
+type CycleError[K comparable] struct {
+	Path []K
+
+	// Names optionally labels the nodes of Path for display; set by callers
+	// whose keys are not meaningful to users
+	Names []string
+
+	// Rejected is set when the cycle was found while adding an edge,
+	// which was not added
+	Rejected bool
+}
+
+// Error formats the cycle as a path
+func (e *CycleError[K]) Error() string {
+	parts := e.Names
+	if len(parts) != len(e.Path) {
+		parts = make([]string, len(e.Path))
+		for i, id := range e.Path {
+			parts[i] = fmt.Sprintf("%v", id)
+		}
+	}
+
+	msg := "graph contains a cycle"
+	if e.Rejected {
+		msg = "adding edge would create a cycle"
+	}
+	return msg + ": " + strings.Join(parts, " -> ")
+}
+
+// edgeCycleError describes the cycle a rejected edge from -> to would close
+// (caller holds g.mu)
+func (g *TypedGraph[K, V, E]) edgeCycleError(from, to K) *CycleError[K] {
+	path := []K{from}
+	if from != to {
+		path = append(path, g.findPath(to, from, nil)...)
+	} else {
+		path = append(path, to)
+	}
+	return &CycleError[K]{Path: path, Rejected: true}
+}
+
+// findPath returns a shortest path from start to target following out-edges,
+// restricted to nodes accepted by within (nil accepts all). Both ends are
+// included; nil is returned if target is unreachable (caller holds g.mu).
+func (g *TypedGraph[K, V, E]) findPath(start, target K, within func(K) bool) []K {
+	parent := map[K]K{}
+	visited := map[K]bool{start: true}
+	queue := []K{start}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		for _, edgeID := range g.nodes[id].OutEdges {
+			edge, ok := g.edges[edgeID]
+			if !ok {
+				continue
+			}
+			next := edge.To
+			if next == target {
+				path := []K{target, id}
+				for id != start {
+					id = parent[id]
+					path = append(path, id)
+				}
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path
+			}
+			if !visited[next] && (within == nil || within(next)) {
+				visited[next] = true
+				parent[next] = id
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	return nil
+}
+
+// nodeIDs lists nodes in a stable order: the maintained order first, then
+// any nodes it does not cover (caller holds g.mu)
+func (g *TypedGraph[K, V, E]) nodeIDs() []K {
+	ids := make([]K, 0, len(g.nodes))
+	for id := range g.order.all() {
+		if _, ok := g.nodes[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	for id := range g.nodes {
+		if _, ok := g.order.index[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// StronglyConnectedComponents partitions the graph into strongly connected
+// components using Tarjan's algorithm. Components are returned in reverse
+// topological order; in a DAG every component is a single node.
+func (g *TypedGraph[K, V, E]) StronglyConnectedComponents() [][]K {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.tarjan()
+}
+
+// tarjan computes the strongly connected components (caller holds g.mu)
+func (g *TypedGraph[K, V, E]) tarjan() [][]K {
+	index := make(map[K]int)
+	lowLink := make(map[K]int)
+	onStack := make(map[K]bool)
+	var stack []K
+	var components [][]K
+	next := 0
+
+	var strongConnect func(K)
+	strongConnect = func(nodeID K) {
+		index[nodeID] = next
+		lowLink[nodeID] = next
+		next++
+		stack = append(stack, nodeID)
+		onStack[nodeID] = true
+
+		for _, edgeID := range g.nodes[nodeID].OutEdges {
+			edge, ok := g.edges[edgeID]
+			if !ok {
+				continue
+			}
+			if _, seen := index[edge.To]; !seen {
+				strongConnect(edge.To)
+				lowLink[nodeID] = min(lowLink[nodeID], lowLink[edge.To])
+			} else if onStack[edge.To] {
+				lowLink[nodeID] = min(lowLink[nodeID], index[edge.To])
+			}
+		}
+
+		// Root of a component, pop it off the stack
+		if lowLink[nodeID] == index[nodeID] {
+			var component []K
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == nodeID {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, nodeID := range g.nodeIDs() {
+		if _, seen := index[nodeID]; !seen {
+			strongConnect(nodeID)
+		}
+	}
+
+	return components
+}
+
+// FindCycles returns one cycle for every strongly connected component that
+// contains a cycle, so an empty result means the graph is a DAG. Every node
+// on a cycle belongs to exactly one of these components; use
+// StronglyConnectedComponents for their full membership.
+func (g *TypedGraph[K, V, E]) FindCycles() []*CycleError[K] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.findCycles()
+}
+
+// findCycles extracts a cycle from each cyclic component (caller holds g.mu)
+func (g *TypedGraph[K, V, E]) findCycles() []*CycleError[K] {
+	var cycles []*CycleError[K]
+
+	for _, component := range g.tarjan() {
+		members := make(map[K]bool, len(component))
+		for _, id := range component {
+			members[id] = true
+		}
+
+		// Start from the earliest discovered node for stable output
+		start := component[len(component)-1]
+		path := g.findPath(start, start, func(id K) bool { return members[id] })
+		if path == nil {
+			// Single node without a self-loop
+			continue
+		}
+		cycles = append(cycles, &CycleError[K]{Path: path})
+	}
+
+	return cycles
+}
+
+// cycleError returns an error describing one cycle of the graph
+// (caller holds g.mu)
+func (g *TypedGraph[K, V, E]) cycleError() error {
+	if cycles := g.findCycles(); len(cycles) > 0 {
+		return cycles[0]
+	}
+	return fmt.Errorf("graph contains a cycle")
+}
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"errors"
+	"testing"
+)
+
+// addRawEdge inserts an edge bypassing cycle detection
+func addRawEdge(g *Graph, from, to NodeID) {
+	id := EdgeID(from + "->" + to)
+	g.edges[id] = &Edge{ID: id, From: from, To: to}
+	g.nodes[from].OutEdges = append(g.nodes[from].OutEdges, id)
+	g.nodes[to].InEdges = append(g.nodes[to].InEdges, id)
+}
+
+func equalPath(a, b []NodeID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAddEdgeCycleError(t *testing.T) {
+	g := NewGraph()
+	for _, id := range []NodeID{"A", "B", "C", "D"} {
+		g.AddNode(id, nil)
+	}
+	g.AddEdge("A", "B", 1.0)
+	g.AddEdge("B", "C", 1.0)
+	g.AddEdge("C", "D", 1.0)
+	g.AddEdge("A", "D", 1.0)
+
+	_, err := g.AddEdge("D", "A", 1.0)
+
+	var cycle *CycleError[NodeID]
+	if !errors.As(err, &cycle) {
+		t.Fatalf("Expected *CycleError, got %v", err)
+	}
+	if !cycle.Rejected {
+		t.Error("Cycle from AddEdge should be marked rejected")
+	}
+	if !equalPath(cycle.Path, []NodeID{"D", "A", "D"}) {
+		t.Errorf("Expected shortest cycle D -> A -> D, got %v", cycle.Path)
+	}
+	if err.Error() != "adding edge would create a cycle: D -> A -> D" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+
+	_, err = g.AddEdge("B", "B", 1.0)
+	if !errors.As(err, &cycle) || !equalPath(cycle.Path, []NodeID{"B", "B"}) {
+		t.Errorf("Expected self-loop cycle, got %v", err)
+	}
+
+	cycle.Names = []string{"parse", "parse"}
+	if cycle.Error() != "adding edge would create a cycle: parse -> parse" {
+		t.Errorf("Names should replace IDs, got %q", cycle.Error())
+	}
+}
+
+func TestTopologicalSortCycleError(t *testing.T) {
+	g := NewGraph()
+	for _, id := range []NodeID{"A", "B", "C"} {
+		g.AddNode(id, nil)
+	}
+	addRawEdge(g, "A", "B")
+	addRawEdge(g, "B", "C")
+	addRawEdge(g, "C", "A")
+
+	_, err := g.TopologicalSort()
+
+	var cycle *CycleError[NodeID]
+	if !errors.As(err, &cycle) {
+		t.Fatalf("Expected *CycleError, got %v", err)
+	}
+	if cycle.Rejected || len(cycle.Path) != 4 || cycle.Path[0] != cycle.Path[3] {
+		t.Errorf("Expected closed three-node cycle, got %v", cycle.Path)
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	g := NewGraph()
+	for _, id := range []NodeID{"A", "B", "C", "D", "E", "F"} {
+		g.AddNode(id, nil)
+	}
+
+	if cycles := g.FindCycles(); len(cycles) != 0 {
+		t.Errorf("Expected no cycles, got %v", cycles)
+	}
+
+	// Two separate cycles joined by an acyclic edge, plus a self-loop
+	addRawEdge(g, "A", "B")
+	addRawEdge(g, "B", "A")
+	addRawEdge(g, "B", "C")
+	addRawEdge(g, "C", "D")
+	addRawEdge(g, "D", "E")
+	addRawEdge(g, "E", "C")
+	addRawEdge(g, "F", "F")
+
+	cycles := g.FindCycles()
+	if len(cycles) != 3 {
+		t.Fatalf("Expected 3 cycles, got %v", cycles)
+	}
+	for _, cycle := range cycles {
+		path := cycle.Path
+		if path[0] != path[len(path)-1] {
+			t.Errorf("Cycle %v should be closed", path)
+		}
+		for i := 0; i+1 < len(path); i++ {
+			if _, ok := g.GetEdge(EdgeID(path[i] + "->" + path[i+1])); !ok {
+				t.Errorf("Cycle %v uses missing edge %s->%s", path, path[i], path[i+1])
+			}
+		}
+	}
+
+	sizes := map[int]int{}
+	for _, component := range g.StronglyConnectedComponents() {
+		sizes[len(component)]++
+	}
+	if sizes[1] != 1 || sizes[2] != 1 || sizes[3] != 1 {
+		t.Errorf("Unexpected component sizes %v", sizes)
+	}
+}
@@ -0,0 +1,403 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Codec converts node data or edge payloads to and from JSON
+type Codec[T any] interface {
+	Encode(T) (json.RawMessage, error)
+	Decode(json.RawMessage) (T, error)
+}
+
+// JSONCodec encodes values with encoding/json; it is the default codec
+type JSONCodec[T any] struct{}
+
+// Encode marshals the value
+func (JSONCodec[T]) Encode(value T) (json.RawMessage, error) {
+	return json.Marshal(value)
+}
+
+// Decode unmarshals the value
+func (JSONCodec[T]) Decode(raw json.RawMessage) (T, error) {
+	var value T
+	if len(raw) == 0 {
+		return value, nil
+	}
+	err := json.Unmarshal(raw, &value)
+	return value, err
+}
+
+// graphJSON is the serialised form of a graph
+type graphJSON struct {
+	Nodes []nodeJSON `json:"nodes"`
+	Edges []edgeJSON `json:"edges"`
+}
+
+type nodeJSON struct {
+	ID       json.RawMessage        `json:"id"`
+	Data     json.RawMessage        `json:"data,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type edgeJSON struct {
+	From     json.RawMessage        `json:"from"`
+	To       json.RawMessage        `json:"to"`
+	Weight   float64                `json:"weight"`
+	Payload  json.RawMessage        `json:"payload,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// MarshalJSON encodes the graph with the default codecs
+func (g *TypedGraph[K, V, E]) MarshalJSON() ([]byte, error) {
+	return g.EncodeJSON(nil, nil)
+}
+
+// UnmarshalJSON replaces the graph contents using the default codecs
+func (g *TypedGraph[K, V, E]) UnmarshalJSON(b []byte) error {
+	return g.DecodeJSON(b, nil, nil)
+}
+
+// EncodeJSON encodes nodes in topological order, followed by their edges.
+// Node IDs are encoded with encoding/json; data and payloads use the given
+// codecs, or JSONCodec when nil. Metadata values must be JSON-encodable and
+// come back with encoding/json's generic types.
+func (g *TypedGraph[K, V, E]) EncodeJSON(data Codec[V], payload Codec[E]) ([]byte, error) {
+	if data == nil {
+		data = JSONCodec[V]{}
+	}
+	if payload == nil {
+		payload = JSONCodec[E]{}
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var out graphJSON
+	out.Nodes = make([]nodeJSON, 0, len(g.nodes))
+	out.Edges = make([]edgeJSON, 0, len(g.edges))
+
+	for _, nodeID := range g.nodeIDs() {
+		node := g.nodes[nodeID]
+
+		id, err := json.Marshal(nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("encoding node %v: %w", nodeID, err)
+		}
+		raw, err := data.Encode(node.Data)
+		if err != nil {
+			return nil, fmt.Errorf("encoding data of node %v: %w", nodeID, err)
+		}
+		out.Nodes = append(out.Nodes, nodeJSON{ID: id, Data: raw, Metadata: node.Metadata})
+
+		for _, edgeID := range node.OutEdges {
+			edge, ok := g.edges[edgeID]
+			if !ok {
+				continue
+			}
+			to, err := json.Marshal(edge.To)
+			if err != nil {
+				return nil, fmt.Errorf("encoding edge %s: %w", edgeID, err)
+			}
+			raw, err := payload.Encode(edge.Payload)
+			if err != nil {
+				return nil, fmt.Errorf("encoding payload of edge %s: %w", edgeID, err)
+			}
+			out.Edges = append(out.Edges, edgeJSON{
+				From:     id,
+				To:       to,
+				Weight:   edge.Weight,
+				Payload:  raw,
+				Metadata: edge.Metadata,
+			})
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// DecodeJSON replaces the graph contents with a graph produced by
+// EncodeJSON. On error the graph is left empty.
+func (g *TypedGraph[K, V, E]) DecodeJSON(b []byte, data Codec[V], payload Codec[E]) error {
+	if data == nil {
+		data = JSONCodec[V]{}
+	}
+	if payload == nil {
+		payload = JSONCodec[E]{}
+	}
+
+	var in graphJSON
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	g.Clear()
+
+	err := g.decodeJSON(in, data, payload)
+	if err != nil {
+		g.Clear()
+	}
+	return err
+}
+
+// decodeJSON adds the decoded nodes and edges to an empty graph
+func (g *TypedGraph[K, V, E]) decodeJSON(in graphJSON, data Codec[V], payload Codec[E]) error {
+	for i, n := range in.Nodes {
+		var id K
+		if err := json.Unmarshal(n.ID, &id); err != nil {
+			return fmt.Errorf("nodes[%d]: decoding id: %w", i, err)
+		}
+		value, err := data.Decode(n.Data)
+		if err != nil {
+			return fmt.Errorf("nodes[%d]: decoding data: %w", i, err)
+		}
+		if err := g.AddNode(id, value); err != nil {
+			return fmt.Errorf("nodes[%d]: %w", i, err)
+		}
+
+		node, _ := g.GetNode(id)
+		for k, v := range n.Metadata {
+			node.Metadata[k] = v
+		}
+	}
+
+	for i, e := range in.Edges {
+		var from, to K
+		if err := json.Unmarshal(e.From, &from); err != nil {
+			return fmt.Errorf("edges[%d]: decoding from: %w", i, err)
+		}
+		if err := json.Unmarshal(e.To, &to); err != nil {
+			return fmt.Errorf("edges[%d]: decoding to: %w", i, err)
+		}
+		value, err := payload.Decode(e.Payload)
+		if err != nil {
+			return fmt.Errorf("edges[%d]: decoding payload: %w", i, err)
+		}
+		edgeID, err := g.AddEdgeWithPayload(from, to, e.Weight, value)
+		if err != nil {
+			return fmt.Errorf("edges[%d]: %w", i, err)
+		}
+
+		edge, _ := g.GetEdge(edgeID)
+		for k, v := range e.Metadata {
+			edge.Metadata[k] = v
+		}
+	}
+
+	return nil
+}
+
+// ExportFormat selects the text format produced by Export
+type ExportFormat int
+
+const (
+	// FormatDOT produces a Graphviz digraph
+	FormatDOT ExportFormat = iota
+
+	// FormatMermaid produces a Mermaid flowchart
+	FormatMermaid
+)
+
+// ExportOptions controls how a graph is rendered by Export
+type ExportOptions[K comparable, V, E any] struct {
+	// Name of the graph, used as the DOT graph ID or Mermaid title
+	Name string
+
+	// ClusterByLevel groups nodes that can run in parallel, as scheduled by
+	// ParallelProcess. Ignored if the graph contains a cycle.
+	ClusterByLevel bool
+
+	// NodeLabel labels a node; defaults to its ID. Newlines start a new
+	// line in the rendered label.
+	NodeLabel func(*TypedNode[K, V]) string
+
+	// EdgeLabel labels an edge; edges are unlabelled by default
+	EdgeLabel func(*TypedEdge[K, E]) string
+}
+
+// exportNode is a node snapshot taken for rendering
+type exportNode struct {
+	key   string
+	label string
+}
+
+// exportEdge is an edge snapshot taken for rendering
+type exportEdge struct {
+	from, to int
+	label    string
+}
+
+// Export renders the graph as DOT or Mermaid text
+func (g *TypedGraph[K, V, E]) Export(format ExportFormat, opts ExportOptions[K, V, E]) string {
+	nodes, edges, levels := g.exportSnapshot(opts)
+
+	switch format {
+	case FormatMermaid:
+		return renderMermaid(opts.Name, nodes, edges, levels)
+	default:
+		return renderDOT(opts.Name, nodes, edges, levels)
+	}
+}
+
+// exportSnapshot labels nodes and edges in a stable order, together with
+// the node indices of each level when clustering
+func (g *TypedGraph[K, V, E]) exportSnapshot(opts ExportOptions[K, V, E]) ([]exportNode, []exportEdge, [][]int) {
+	g.mu.RLock()
+	order, err := g.sortedOrder()
+	if err != nil {
+		order = g.nodeIDs()
+	} else {
+		order = append([]K(nil), order...)
+	}
+	g.mu.RUnlock()
+
+	var levelIDs [][]K
+	if opts.ClusterByLevel && err == nil {
+		levelIDs = g.groupByLevel(order)
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	index := make(map[K]int, len(order))
+	nodes := make([]exportNode, 0, len(order))
+	for _, nodeID := range order {
+		node, ok := g.nodes[nodeID]
+		if !ok {
+			continue
+		}
+		label := fmt.Sprintf("%v", nodeID)
+		if opts.NodeLabel != nil {
+			label = opts.NodeLabel(node)
+		}
+		index[nodeID] = len(nodes)
+		nodes = append(nodes, exportNode{key: fmt.Sprintf("%v", nodeID), label: label})
+	}
+
+	var edges []exportEdge
+	for _, nodeID := range order {
+		node, ok := g.nodes[nodeID]
+		if !ok {
+			continue
+		}
+		for _, edgeID := range node.OutEdges {
+			edge, ok := g.edges[edgeID]
+			if !ok {
+				continue
+			}
+			from, okFrom := index[edge.From]
+			to, okTo := index[edge.To]
+			if !okFrom || !okTo {
+				continue
+			}
+			var label string
+			if opts.EdgeLabel != nil {
+				label = opts.EdgeLabel(edge)
+			}
+			edges = append(edges, exportEdge{from: from, to: to, label: label})
+		}
+	}
+
+	var levels [][]int
+	for _, level := range levelIDs {
+		var members []int
+		for _, nodeID := range level {
+			if i, ok := index[nodeID]; ok {
+				members = append(members, i)
+			}
+		}
+		levels = append(levels, members)
+	}
+
+	return nodes, edges, levels
+}
+
+// renderDOT writes a Graphviz digraph
+func renderDOT(name string, nodes []exportNode, edges []exportEdge, levels [][]int) string {
+	var b strings.Builder
+
+	if name == "" {
+		name = "G"
+	}
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(name))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+
+	writeNode := func(indent string, i int) {
+		fmt.Fprintf(&b, "%s%s [label=%s];\n", indent, strconv.Quote(nodes[i].key), strconv.Quote(nodes[i].label))
+	}
+
+	if len(levels) > 0 {
+		for l, members := range levels {
+			fmt.Fprintf(&b, "  subgraph cluster_%d {\n", l)
+			fmt.Fprintf(&b, "    label=%s;\n", strconv.Quote(fmt.Sprintf("level %d", l)))
+			for _, i := range members {
+				writeNode("    ", i)
+			}
+			b.WriteString("  }\n")
+		}
+	} else {
+		for i := range nodes {
+			writeNode("  ", i)
+		}
+	}
+
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "  %s -> %s", strconv.Quote(nodes[edge.from].key), strconv.Quote(nodes[edge.to].key))
+		if edge.label != "" {
+			fmt.Fprintf(&b, " [label=%s]", strconv.Quote(edge.label))
+		}
+		b.WriteString(";\n")
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// mermaidText escapes a label for a quoted Mermaid string
+func mermaidText(label string) string {
+	label = strings.ReplaceAll(label, `"`, "#quot;")
+	return strings.ReplaceAll(label, "\n", "<br/>")
+}
+
+// renderMermaid writes a Mermaid flowchart. Node IDs are replaced by
+// generated identifiers since Mermaid only accepts simple names.
+func renderMermaid(name string, nodes []exportNode, edges []exportEdge, levels [][]int) string {
+	var b strings.Builder
+
+	if name != "" {
+		fmt.Fprintf(&b, "---\ntitle: %s\n---\n", name)
+	}
+	b.WriteString("flowchart LR\n")
+
+	writeNode := func(indent string, i int) {
+		fmt.Fprintf(&b, "%sn%d[\"%s\"]\n", indent, i, mermaidText(nodes[i].label))
+	}
+
+	if len(levels) > 0 {
+		for l, members := range levels {
+			fmt.Fprintf(&b, "  subgraph level%d [\"level %d\"]\n", l, l)
+			for _, i := range members {
+				writeNode("    ", i)
+			}
+			b.WriteString("  end\n")
+		}
+	} else {
+		for i := range nodes {
+			writeNode("  ", i)
+		}
+	}
+
+	for _, edge := range edges {
+		if edge.label != "" {
+			fmt.Fprintf(&b, "  n%d -->|\"%s\"| n%d\n", edge.from, mermaidText(edge.label), edge.to)
+		} else {
+			fmt.Fprintf(&b, "  n%d --> n%d\n", edge.from, edge.to)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pointCodec stores points as "x,y" strings
+type pointCodec struct{}
+
+type point struct{ X, Y int }
+
+func (pointCodec) Encode(p point) (json.RawMessage, error) {
+	return json.Marshal(fmt.Sprintf("%d,%d", p.X, p.Y))
+}
+
+func (pointCodec) Decode(raw json.RawMessage) (point, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return point{}, err
+	}
+	var p point
+	_, err := fmt.Sscanf(s, "%d,%d", &p.X, &p.Y)
+	return p, err
+}
+
+func TestGraphJSONRoundTrip(t *testing.T) {
+	g := buildDiamond()
+	node, _ := g.GetNode("B")
+	node.Data = "payload"
+	node.Metadata["owner"] = "layout"
+	edge, _ := g.GetEdge("C->D")
+	edge.Metadata["note"] = "slow"
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var decoded Graph
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if decoded.NodeCount() != 4 || decoded.EdgeCount() != 4 {
+		t.Fatalf("Expected 4 nodes and 4 edges, got %d and %d", decoded.NodeCount(), decoded.EdgeCount())
+	}
+	node, _ = decoded.GetNode("B")
+	if node.Data != "payload" || node.Metadata["owner"] != "layout" {
+		t.Errorf("Node data or metadata lost: %+v", node)
+	}
+	edge, ok := decoded.GetEdge("C->D")
+	if !ok || edge.Weight != 4 || edge.Metadata["note"] != "slow" {
+		t.Errorf("Edge weight or metadata lost: %+v", edge)
+	}
+
+	longest, _ := decoded.LongestPath("A", "D")
+	if longest.Cost != 7 {
+		t.Errorf("Expected weights to survive, got cost %v", longest.Cost)
+	}
+}
+
+func TestGraphJSONCodecs(t *testing.T) {
+	g := NewTypedGraph[int, point, point]()
+	g.AddNode(1, point{1, 2})
+	g.AddNode(2, point{3, 4})
+	g.AddEdgeWithPayload(1, 2, 0.5, point{5, 6})
+
+	data, err := g.EncodeJSON(pointCodec{}, pointCodec{})
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"1,2"`) {
+		t.Errorf("Expected codec output in %s", data)
+	}
+
+	decoded := NewTypedGraph[int, point, point]()
+	if err := decoded.DecodeJSON(data, pointCodec{}, pointCodec{}); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	node, _ := decoded.GetNode(2)
+	edge, _ := decoded.GetEdge("1->2")
+	if node.Data != (point{3, 4}) || edge == nil || edge.Payload != (point{5, 6}) {
+		t.Errorf("Codec round-trip failed: %+v %+v", node, edge)
+	}
+
+	// Cycles in the input are rejected and leave the graph empty
+	bad := `{"nodes":[{"id":1},{"id":2}],"edges":[{"from":1,"to":2},{"from":2,"to":1}]}`
+	if err := decoded.DecodeJSON([]byte(bad), pointCodec{}, pointCodec{}); err == nil {
+		t.Error("Expected cycle error")
+	}
+	if decoded.NodeCount() != 0 {
+		t.Error("Failed decode should leave the graph empty")
+	}
+}
+
+func TestGraphExportDOT(t *testing.T) {
+	g := buildDiamond()
+
+	out := g.Export(FormatDOT, ExportOptions[NodeID, interface{}, interface{}]{
+		Name:           "diamond",
+		ClusterByLevel: true,
+		NodeLabel: func(node *Node) string {
+			return "stage " + string(node.ID) + "\n" + `"quoted"`
+		},
+		EdgeLabel: func(edge *Edge) string {
+			return strconv.FormatFloat(edge.Weight, 'g', -1, 64)
+		},
+	})
+
+	for _, want := range []string{
+		`digraph "diamond" {`,
+		`subgraph cluster_0 {`,
+		`subgraph cluster_2 {`,
+		`"A" [label="stage A\n\"quoted\""];`,
+		`"C" -> "D" [label="4"];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "cluster_3") {
+		t.Errorf("Diamond has only three levels:\n%s", out)
+	}
+}
+
+func TestGraphExportMermaid(t *testing.T) {
+	g := buildDiamond()
+
+	out := g.Export(FormatMermaid, ExportOptions[NodeID, interface{}, interface{}]{
+		NodeLabel: func(node *Node) string {
+			return string(node.ID) + "\n" + `"x"`
+		},
+	})
+
+	if !strings.HasPrefix(out, "flowchart LR\n") {
+		t.Errorf("Expected flowchart header:\n%s", out)
+	}
+	if !strings.Contains(out, `n0["A<br/>#quot;x#quot;"]`) {
+		t.Errorf("Expected escaped label:\n%s", out)
+	}
+	if strings.Count(out, "-->") != 4 {
+		t.Errorf("Expected 4 edges:\n%s", out)
+	}
+	if strings.Contains(out, "subgraph") {
+		t.Errorf("Clustering should be off by default:\n%s", out)
+	}
+}
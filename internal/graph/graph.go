@@ -15,10 +15,26 @@ type NodeID string
 // EdgeID uniquely identifies an edge in the graph
 type EdgeID string
 
-// Node represents a node in the dependency graph
-type Node struct {
-	ID       NodeID
-	Data     interface{}
+// Graph is the untyped graph: string IDs with interface{} node data and
+// edge payloads. It is an instantiation of TypedGraph, so the two share
+// one implementation.
+type Graph = TypedGraph[NodeID, interface{}, interface{}]
+
+// Node is a node of the untyped Graph
+type Node = TypedNode[NodeID, interface{}]
+
+// Edge is an edge of the untyped Graph
+type Edge = TypedEdge[NodeID, interface{}]
+
+// NewGraph creates a new graph instance
+func NewGraph() *Graph {
+	return NewTypedGraph[NodeID, interface{}, interface{}]()
+}
+
+// TypedNode represents a node in the dependency graph
+type TypedNode[K comparable, V any] struct {
+	ID       K
+	Data     V
 	Metadata map[string]interface{}
 	
 	// Dependencies
@@ -30,72 +46,75 @@ type Node struct {
 	processing atomic.Bool
 	processed atomic.Bool
 	
-	// Weak reference to graph
-	graph *Graph
-	
 	mu sync.RWMutex
 }
 
-// Edge represents a directed edge between nodes
-type Edge struct {
+// TypedEdge represents a directed edge between nodes carrying a typed payload
+type TypedEdge[K comparable, E any] struct {
 	ID       EdgeID
-	From     NodeID
-	To       NodeID
+	From     K
+	To       K
 	Weight   float64
+	Payload  E
 	Metadata map[string]interface{}
 }
 
-// Graph represents a directed acyclic graph for dependency management
-type Graph struct {
-	nodes map[NodeID]*Node
-	edges map[EdgeID]*Edge
+// TypedGraph represents a directed acyclic graph for dependency management.
+// K identifies nodes, V is the node data and E the edge payload.
+type TypedGraph[K comparable, V, E any] struct {
+	nodes map[K]*TypedNode[K, V]
+	edges map[EdgeID]*TypedEdge[K, E]
 	
 	// Index for fast lookups
-	fromIndex map[NodeID][]EdgeID // Edges from node
-	toIndex   map[NodeID][]EdgeID // Edges to node
+	fromIndex map[K][]EdgeID // Edges from node
+	toIndex   map[K][]EdgeID // Edges to node
 	
 	// Version for change tracking
 	version atomic.Uint64
+	changes changeLog[K]
+	
+	// Incrementally maintained topological order (see order.go)
+	order topoOrder[K]
 	
 	mu sync.RWMutex
 }
 
-// NewGraph creates a new graph instance
-func NewGraph() *Graph {
-	return &Graph{
-		nodes:     make(map[NodeID]*Node),
-		edges:     make(map[EdgeID]*Edge),
-		fromIndex: make(map[NodeID][]EdgeID),
-		toIndex:   make(map[NodeID][]EdgeID),
+// NewTypedGraph creates a new typed graph instance
+func NewTypedGraph[K comparable, V, E any]() *TypedGraph[K, V, E] {
+	return &TypedGraph[K, V, E]{
+		nodes:     make(map[K]*TypedNode[K, V]),
+		edges:     make(map[EdgeID]*TypedEdge[K, E]),
+		fromIndex: make(map[K][]EdgeID),
+		toIndex:   make(map[K][]EdgeID),
 	}
 }
 
 // AddNode adds a node to the graph
-func (g *Graph) AddNode(id NodeID, data interface{}) error {
+func (g *TypedGraph[K, V, E]) AddNode(id K, data V) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
 	if _, exists := g.nodes[id]; exists {
-		return fmt.Errorf("node %s already exists", id)
+		return fmt.Errorf("node %v already exists", id)
 	}
 	
-	node := &Node{
+	node := &TypedNode[K, V]{
 		ID:       id,
 		Data:     data,
 		Metadata: make(map[string]interface{}),
 		InEdges:  []EdgeID{},
 		OutEdges: []EdgeID{},
-		graph:    g,
 	}
 	
 	g.nodes[id] = node
-	g.version.Add(1)
+	g.order.add(id)
+	g.recordChange(Change[K]{Kind: NodeAdded, Node: id})
 	
 	return nil
 }
 
 // GetNode retrieves a node by ID
-func (g *Graph) GetNode(id NodeID) (*Node, bool) {
+func (g *TypedGraph[K, V, E]) GetNode(id K) (*TypedNode[K, V], bool) {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	
@@ -104,21 +123,33 @@ func (g *Graph) GetNode(id NodeID) (*Node, bool) {
 }
 
 // RemoveNode removes a node and all its edges
-func (g *Graph) RemoveNode(id NodeID) error {
+func (g *TypedGraph[K, V, E]) RemoveNode(id K) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
 	node, exists := g.nodes[id]
 	if !exists {
-		return fmt.Errorf("node %s not found", id)
+		return fmt.Errorf("node %v not found", id)
 	}
 	
-	// Remove all edges connected to this node
-	for _, edgeID := range node.InEdges {
-		delete(g.edges, edgeID)
-	}
-	for _, edgeID := range node.OutEdges {
+	// Remove all edges connected to this node, including the references
+	// held by the nodes on the other end
+	for _, edgeID := range append(append([]EdgeID{}, node.InEdges...), node.OutEdges...) {
+		edge, ok := g.edges[edgeID]
+		if !ok {
+			continue
+		}
+		if from, ok := g.nodes[edge.From]; ok && edge.From != id {
+			from.OutEdges = removeEdgeID(from.OutEdges, edgeID)
+			g.fromIndex[edge.From] = removeEdgeID(g.fromIndex[edge.From], edgeID)
+		}
+		if to, ok := g.nodes[edge.To]; ok && edge.To != id {
+			to.InEdges = removeEdgeID(to.InEdges, edgeID)
+			g.toIndex[edge.To] = removeEdgeID(g.toIndex[edge.To], edgeID)
+		}
 		delete(g.edges, edgeID)
+		g.order.edges--
+		g.recordChange(Change[K]{Kind: EdgeRemoved, Edge: edgeID, From: edge.From, To: edge.To})
 	}
 	
 	// Update indices
@@ -127,41 +158,58 @@ func (g *Graph) RemoveNode(id NodeID) error {
 	
 	// Remove node
 	delete(g.nodes, id)
-	g.version.Add(1)
+	g.order.remove(id)
+	g.recordChange(Change[K]{Kind: NodeRemoved, Node: id})
 	
 	return nil
 }
 
-// AddEdge creates a directed edge from one node to another
-func (g *Graph) AddEdge(from, to NodeID, weight float64) (EdgeID, error) {
+// AddEdge creates a directed edge from one node to another.
+// An edge that would close a cycle is rejected with a *CycleError.
+func (g *TypedGraph[K, V, E]) AddEdge(from, to K, weight float64) (EdgeID, error) {
+	var payload E
+	return g.AddEdgeWithPayload(from, to, weight, payload)
+}
+
+// AddEdgeWithPayload creates a directed edge carrying a typed payload
+func (g *TypedGraph[K, V, E]) AddEdgeWithPayload(from, to K, weight float64, payload E) (EdgeID, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
 	// Verify nodes exist
 	fromNode, fromExists := g.nodes[from]
 	if !fromExists {
-		return "", fmt.Errorf("source node %s not found", from)
+		return "", fmt.Errorf("source node %v not found", from)
 	}
 	
 	toNode, toExists := g.nodes[to]
 	if !toExists {
-		return "", fmt.Errorf("target node %s not found", to)
+		return "", fmt.Errorf("target node %v not found", to)
 	}
 	
 	// Generate edge ID
-	edgeID := EdgeID(fmt.Sprintf("%s->%s", from, to))
+	edgeID := EdgeID(fmt.Sprintf("%v->%v", from, to))
 	
 	// Check if edge already exists
 	if _, exists := g.edges[edgeID]; exists {
 		return "", fmt.Errorf("edge %s already exists", edgeID)
 	}
 	
+	// Keep the topological order, rejecting edges that would close a cycle
+	if !g.orderConsistent() && !g.rebuildOrder() {
+		return "", g.cycleError()
+	}
+	if !g.insertEdgeOrder(from, to) {
+		return "", g.edgeCycleError(from, to)
+	}
+	
 	// Create edge
-	edge := &Edge{
+	edge := &TypedEdge[K, E]{
 		ID:       edgeID,
 		From:     from,
 		To:       to,
 		Weight:   weight,
+		Payload:  payload,
 		Metadata: make(map[string]interface{}),
 	}
 	
@@ -173,26 +221,15 @@ func (g *Graph) AddEdge(from, to NodeID, weight float64) (EdgeID, error) {
 	// Update indices
 	g.fromIndex[from] = append(g.fromIndex[from], edgeID)
 	g.toIndex[to] = append(g.toIndex[to], edgeID)
+	g.order.edges++
 	
-	g.version.Add(1)
-	
-	// Check for cycles
-	if g.hasCycle() {
-		// Rollback
-		delete(g.edges, edgeID)
-		fromNode.OutEdges = fromNode.OutEdges[:len(fromNode.OutEdges)-1]
-		toNode.InEdges = toNode.InEdges[:len(toNode.InEdges)-1]
-		g.fromIndex[from] = g.fromIndex[from][:len(g.fromIndex[from])-1]
-		g.toIndex[to] = g.toIndex[to][:len(g.toIndex[to])-1]
-		
-		return "", fmt.Errorf("adding edge would create a cycle")
-	}
+	g.recordChange(Change[K]{Kind: EdgeAdded, Edge: edgeID, From: from, To: to})
 	
 	return edgeID, nil
 }
 
 // RemoveEdge removes an edge from the graph
-func (g *Graph) RemoveEdge(edgeID EdgeID) error {
+func (g *TypedGraph[K, V, E]) RemoveEdge(edgeID EdgeID) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
@@ -215,13 +252,14 @@ func (g *Graph) RemoveEdge(edgeID EdgeID) error {
 	
 	// Remove edge
 	delete(g.edges, edgeID)
-	g.version.Add(1)
+	g.order.edges--
+	g.recordChange(Change[K]{Kind: EdgeRemoved, Edge: edgeID, From: edge.From, To: edge.To})
 	
 	return nil
 }
 
 // GetDependencies returns nodes that the given node depends on
-func (g *Graph) GetDependencies(nodeID NodeID) []NodeID {
+func (g *TypedGraph[K, V, E]) GetDependencies(nodeID K) []K {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	
@@ -230,7 +268,7 @@ func (g *Graph) GetDependencies(nodeID NodeID) []NodeID {
 		return nil
 	}
 	
-	dependencies := make([]NodeID, 0, len(node.InEdges))
+	dependencies := make([]K, 0, len(node.InEdges))
 	for _, edgeID := range node.InEdges {
 		if edge, ok := g.edges[edgeID]; ok {
 			dependencies = append(dependencies, edge.From)
@@ -241,7 +279,7 @@ func (g *Graph) GetDependencies(nodeID NodeID) []NodeID {
 }
 
 // GetDependents returns nodes that depend on the given node
-func (g *Graph) GetDependents(nodeID NodeID) []NodeID {
+func (g *TypedGraph[K, V, E]) GetDependents(nodeID K) []K {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	
@@ -250,7 +288,7 @@ func (g *Graph) GetDependents(nodeID NodeID) []NodeID {
 		return nil
 	}
 	
-	dependents := make([]NodeID, 0, len(node.OutEdges))
+	dependents := make([]K, 0, len(node.OutEdges))
 	for _, edgeID := range node.OutEdges {
 		if edge, ok := g.edges[edgeID]; ok {
 			dependents = append(dependents, edge.To)
@@ -260,26 +298,73 @@ func (g *Graph) GetDependents(nodeID NodeID) []NodeID {
 	return dependents
 }
 
-// TopologicalSort returns nodes in topological order
-func (g *Graph) TopologicalSort() ([]NodeID, error) {
+// TopologicalSort returns nodes in topological order.
+// The order is maintained as edges are added, so this only copies it; use
+// TopologicalOrder to walk it without copying.
+func (g *TypedGraph[K, V, E]) TopologicalSort() ([]K, error) {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	
+	if g.orderConsistent() {
+		return append([]K(nil), g.order.slice()...), nil
+	}
+	
+	result, ok := g.kahnOrder()
+	if !ok {
+		return nil, g.cycleError()
+	}
+	
+	return result, nil
+}
+
+// TopologicalOrder yields nodes in topological order without copying the
+// maintained order. The graph is read-locked while iterating, so the loop
+// body must not modify it. Nothing is yielded if the graph has a cycle;
+// TopologicalSort reports it.
+func (g *TypedGraph[K, V, E]) TopologicalOrder() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		g.mu.RLock()
+		defer g.mu.RUnlock()
+		
+		if g.orderConsistent() {
+			for id := range g.order.all() {
+				if !yield(id) {
+					return
+				}
+			}
+			return
+		}
+		
+		order, ok := g.kahnOrder()
+		if !ok {
+			return
+		}
+		for _, id := range order {
+			if !yield(id) {
+				return
+			}
+		}
+	}
+}
+
+// kahnOrder computes a topological order from scratch, returning false if
+// the graph contains a cycle (caller holds g.mu)
+func (g *TypedGraph[K, V, E]) kahnOrder() ([]K, bool) {
 	// Count in-degrees
-	inDegrees := make(map[NodeID]int)
+	inDegrees := make(map[K]int)
 	for nodeID, node := range g.nodes {
 		inDegrees[nodeID] = len(node.InEdges)
 	}
 	
 	// Find nodes with no dependencies
-	queue := make([]NodeID, 0)
+	queue := make([]K, 0)
 	for nodeID, degree := range inDegrees {
 		if degree == 0 {
 			queue = append(queue, nodeID)
 		}
 	}
 	
-	result := make([]NodeID, 0, len(g.nodes))
+	result := make([]K, 0, len(g.nodes))
 	
 	// Process queue
 	for len(queue) > 0 {
@@ -301,23 +386,35 @@ func (g *Graph) TopologicalSort() ([]NodeID, error) {
 	}
 	
 	// Check if all nodes were processed
-	if len(result) != len(g.nodes) {
-		return nil, fmt.Errorf("graph contains a cycle")
+	return result, len(result) == len(g.nodes)
+}
+
+// rebuildOrder recomputes the maintained order from scratch, returning
+// false if the graph contains a cycle (caller holds g.mu)
+func (g *TypedGraph[K, V, E]) rebuildOrder() bool {
+	result, ok := g.kahnOrder()
+	if !ok {
+		return false
 	}
 	
-	return result, nil
+	g.order.reset()
+	for _, id := range result {
+		g.order.add(id)
+	}
+	g.order.edges = len(g.edges)
+	return true
 }
 
 // DFS performs depth-first search traversal
-func (g *Graph) DFS() iter.Seq[*Node] {
-	return func(yield func(*Node) bool) {
+func (g *TypedGraph[K, V, E]) DFS() iter.Seq[*TypedNode[K, V]] {
+	return func(yield func(*TypedNode[K, V]) bool) {
 		g.mu.RLock()
 		defer g.mu.RUnlock()
 		
-		visited := make(map[NodeID]bool)
+		visited := make(map[K]bool)
 		
-		var dfsRecursive func(NodeID) bool
-		dfsRecursive = func(nodeID NodeID) bool {
+		var dfsRecursive func(K) bool
+		dfsRecursive = func(nodeID K) bool {
 			if visited[nodeID] {
 				return true
 			}
@@ -365,13 +462,13 @@ func (g *Graph) DFS() iter.Seq[*Node] {
 }
 
 // BFS performs breadth-first search traversal
-func (g *Graph) BFS() iter.Seq[*Node] {
-	return func(yield func(*Node) bool) {
+func (g *TypedGraph[K, V, E]) BFS() iter.Seq[*TypedNode[K, V]] {
+	return func(yield func(*TypedNode[K, V]) bool) {
 		g.mu.RLock()
 		defer g.mu.RUnlock()
 		
-		visited := make(map[NodeID]bool)
-		queue := make([]NodeID, 0)
+		visited := make(map[K]bool)
+		queue := make([]K, 0)
 		
 		// Start from nodes with no dependencies
 		for nodeID, node := range g.nodes {
@@ -418,34 +515,21 @@ func (g *Graph) BFS() iter.Seq[*Node] {
 	}
 }
 
-// ParallelProcess processes independent nodes in parallel
-func (g *Graph) ParallelProcess(ctx context.Context, processor func(context.Context, *Node) error) error {
-	// Get topological order
-	order, err := g.TopologicalSort()
-	if err != nil {
-		return err
-	}
-	
-	// Group nodes by level (nodes that can be processed in parallel)
-	levels := g.groupByLevel(order)
-	
-	// Process each level
-	for _, level := range levels {
-		if err := g.processLevel(ctx, level, processor); err != nil {
-			return err
-		}
-	}
-	
-	return nil
+// ParallelProcess processes independent nodes in parallel. Each node
+// starts as soon as its dependencies finish; the first error stops the run.
+// Use Schedule for limits and per-node outcomes.
+func (g *TypedGraph[K, V, E]) ParallelProcess(ctx context.Context, processor func(context.Context, *TypedNode[K, V]) error) error {
+	_, err := g.Schedule(ctx, ScheduleOptions[K, V]{}, processor)
+	return err
 }
 
 // groupByLevel groups nodes that can be processed in parallel
-func (g *Graph) groupByLevel(order []NodeID) [][]NodeID {
+func (g *TypedGraph[K, V, E]) groupByLevel(order []K) [][]K {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	
-	levels := make([][]NodeID, 0)
-	nodeLevel := make(map[NodeID]int)
+	levels := make([][]K, 0)
+	nodeLevel := make(map[K]int)
 	
 	// Calculate level for each node
 	for _, nodeID := range order {
@@ -467,7 +551,7 @@ func (g *Graph) groupByLevel(order []NodeID) [][]NodeID {
 		
 		// Ensure we have enough levels
 		for len(levels) <= level {
-			levels = append(levels, []NodeID{})
+			levels = append(levels, []K{})
 		}
 		
 		levels[level] = append(levels[level], nodeID)
@@ -476,62 +560,13 @@ func (g *Graph) groupByLevel(order []NodeID) [][]NodeID {
 	return levels
 }
 
-// processLevel processes all nodes in a level in parallel
-func (g *Graph) processLevel(ctx context.Context, level []NodeID, processor func(context.Context, *Node) error) error {
-	if len(level) == 0 {
-		return nil
-	}
-	
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(level))
-	
-	for _, nodeID := range level {
-		node, exists := g.nodes[nodeID]
-		if !exists {
-			continue
-		}
-		
-		wg.Add(1)
-		go func(n *Node) {
-			defer wg.Done()
-			
-			if err := processor(ctx, n); err != nil {
-				select {
-				case errChan <- fmt.Errorf("node %s: %w", n.ID, err):
-				case <-ctx.Done():
-				}
-			}
-		}(node)
-	}
-	
-	// Wait for completion
-	doneChan := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(doneChan)
-	}()
-	
-	select {
-	case <-doneChan:
-		close(errChan)
-		for err := range errChan {
-			if err != nil {
-				return err
-			}
-		}
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	}
-}
-
 // hasCycle detects if the graph contains a cycle using DFS
-func (g *Graph) hasCycle() bool {
-	visited := make(map[NodeID]bool)
-	recStack := make(map[NodeID]bool)
+func (g *TypedGraph[K, V, E]) hasCycle() bool {
+	visited := make(map[K]bool)
+	recStack := make(map[K]bool)
 	
-	var hasCycleDFS func(NodeID) bool
-	hasCycleDFS = func(nodeID NodeID) bool {
+	var hasCycleDFS func(K) bool
+	hasCycleDFS = func(nodeID K) bool {
 		visited[nodeID] = true
 		recStack[nodeID] = true
 		
@@ -564,21 +599,20 @@ func (g *Graph) hasCycle() bool {
 }
 
 // Clone creates a deep copy of the graph
-func (g *Graph) Clone() *Graph {
+func (g *TypedGraph[K, V, E]) Clone() *TypedGraph[K, V, E] {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	
-	newGraph := NewGraph()
+	newGraph := NewTypedGraph[K, V, E]()
 	
 	// Clone nodes
 	for nodeID, node := range g.nodes {
-		newNode := &Node{
+		newNode := &TypedNode[K, V]{
 			ID:       node.ID,
 			Data:     node.Data,
 			Metadata: make(map[string]interface{}),
 			InEdges:  make([]EdgeID, len(node.InEdges)),
 			OutEdges: make([]EdgeID, len(node.OutEdges)),
-			graph:    newGraph,
 		}
 		
 		// Copy metadata
@@ -595,11 +629,12 @@ func (g *Graph) Clone() *Graph {
 	
 	// Clone edges
 	for edgeID, edge := range g.edges {
-		newEdge := &Edge{
+		newEdge := &TypedEdge[K, E]{
 			ID:       edge.ID,
 			From:     edge.From,
 			To:       edge.To,
 			Weight:   edge.Weight,
+			Payload:  edge.Payload,
 			Metadata: make(map[string]interface{}),
 		}
 		
@@ -618,39 +653,48 @@ func (g *Graph) Clone() *Graph {
 	for k, v := range g.toIndex {
 		newGraph.toIndex[k] = append([]EdgeID{}, v...)
 	}
+	newGraph.order = g.order.clone()
 	
 	return newGraph
 }
 
 // NodeCount returns the number of nodes in the graph
-func (g *Graph) NodeCount() int {
+func (g *TypedGraph[K, V, E]) NodeCount() int {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	return len(g.nodes)
 }
 
 // EdgeCount returns the number of edges in the graph
-func (g *Graph) EdgeCount() int {
+func (g *TypedGraph[K, V, E]) EdgeCount() int {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	return len(g.edges)
 }
 
 // IsDAG checks if the graph is a directed acyclic graph
-func (g *Graph) IsDAG() bool {
+func (g *TypedGraph[K, V, E]) IsDAG() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	
+	// AddEdge never admits a cycle, so a consistent order implies a DAG
+	if g.orderConsistent() {
+		return true
+	}
 	return !g.hasCycle()
 }
 
 // Clear removes all nodes and edges from the graph
-func (g *Graph) Clear() {
+func (g *TypedGraph[K, V, E]) Clear() {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	
-	g.nodes = make(map[NodeID]*Node)
-	g.edges = make(map[EdgeID]*Edge)
-	g.fromIndex = make(map[NodeID][]EdgeID)
-	g.toIndex = make(map[NodeID][]EdgeID)
-	g.version.Add(1)
+	g.nodes = make(map[K]*TypedNode[K, V])
+	g.edges = make(map[EdgeID]*TypedEdge[K, E])
+	g.fromIndex = make(map[K][]EdgeID)
+	g.toIndex = make(map[K][]EdgeID)
+	g.order.reset()
+	g.recordChange(Change[K]{Kind: GraphCleared})
 }
 
 // Helper function to remove an EdgeID from a slice
@@ -668,6 +712,8 @@ type MultipassProcessor struct {
 	graph     *Graph
 	passes    []PassFunc
 	results   map[NodeID]interface{}
+	options   ScheduleOptions[NodeID, interface{}]
+	outcomes  []map[NodeID]NodeOutcome
 	mu        sync.RWMutex
 }
 
@@ -688,8 +734,18 @@ func (p *MultipassProcessor) AddPass(pass PassFunc) {
 	p.passes = append(p.passes, pass)
 }
 
+// SetScheduleOptions sets the concurrency and resource limits used to
+// schedule every pass
+func (p *MultipassProcessor) SetScheduleOptions(opts ScheduleOptions[NodeID, interface{}]) {
+	p.options = opts
+}
+
 // Execute runs all passes on the graph
 func (p *MultipassProcessor) Execute(ctx context.Context) error {
+	p.mu.Lock()
+	p.outcomes = p.outcomes[:0]
+	p.mu.Unlock()
+	
 	for i, pass := range p.passes {
 		if err := p.executePass(ctx, pass, i); err != nil {
 			return fmt.Errorf("pass %d failed: %w", i, err)
@@ -700,84 +756,49 @@ func (p *MultipassProcessor) Execute(ctx context.Context) error {
 
 // executePass runs a single pass on all nodes
 func (p *MultipassProcessor) executePass(ctx context.Context, pass PassFunc, passIndex int) error {
-	// Get topological order for this pass
-	order, err := p.graph.TopologicalSort()
-	if err != nil {
-		return err
-	}
-	
-	// Group nodes by level for parallel processing
-	levels := p.graph.groupByLevel(order)
-	
-	// Process each level
-	for _, level := range levels {
-		if err := p.processPassLevel(ctx, level, pass); err != nil {
+	outcomes, err := p.graph.Schedule(ctx, p.options, func(ctx context.Context, n *Node) error {
+		// Get current results snapshot
+		p.mu.RLock()
+		resultsCopy := make(map[NodeID]interface{})
+		for k, v := range p.results {
+			resultsCopy[k] = v
+		}
+		p.mu.RUnlock()
+		
+		// Execute pass
+		result, err := pass(ctx, n, resultsCopy)
+		if err != nil {
 			return err
 		}
-	}
+		
+		// Store result
+		p.mu.Lock()
+		p.results[n.ID] = result
+		p.mu.Unlock()
+		return nil
+	})
 	
-	return nil
+	p.mu.Lock()
+	p.outcomes = append(p.outcomes, outcomes)
+	p.mu.Unlock()
+	
+	return err
 }
 
-// processPassLevel processes a level of nodes for a pass
-func (p *MultipassProcessor) processPassLevel(ctx context.Context, level []NodeID, pass PassFunc) error {
-	var wg sync.WaitGroup
-	errChan := make(chan error, len(level))
+// PassOutcomes returns the per-node outcomes of a pass from the last
+// Execute, or nil if the pass did not run
+func (p *MultipassProcessor) PassOutcomes(passIndex int) map[NodeID]NodeOutcome {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	
-	for _, nodeID := range level {
-		node, exists := p.graph.nodes[nodeID]
-		if !exists {
-			continue
-		}
-		
-		wg.Add(1)
-		go func(n *Node) {
-			defer wg.Done()
-			
-			// Get current results snapshot
-			p.mu.RLock()
-			resultsCopy := make(map[NodeID]interface{})
-			for k, v := range p.results {
-				resultsCopy[k] = v
-			}
-			p.mu.RUnlock()
-			
-			// Execute pass
-			result, err := pass(ctx, n, resultsCopy)
-			if err != nil {
-				select {
-				case errChan <- err:
-				case <-ctx.Done():
-				}
-				return
-			}
-			
-			// Store result
-			p.mu.Lock()
-			p.results[n.ID] = result
-			p.mu.Unlock()
-		}(node)
-	}
-	
-	// Wait for completion
-	doneChan := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(doneChan)
-	}()
-	
-	select {
-	case <-doneChan:
-		close(errChan)
-		for err := range errChan {
-			if err != nil {
-				return err
-			}
-		}
+	if passIndex < 0 || passIndex >= len(p.outcomes) {
 		return nil
-	case <-ctx.Done():
-		return ctx.Err()
 	}
+	outcomes := make(map[NodeID]NodeOutcome, len(p.outcomes[passIndex]))
+	for k, v := range p.outcomes[passIndex] {
+		outcomes[k] = v
+	}
+	return outcomes
 }
 
 // GetResult returns the result for a node
@@ -0,0 +1,210 @@
+package graph
+
+import (
+	"iter"
+	"sort"
+)
+
+// Incremental topological order (Pearce–Kelly).
+//
+// The graph keeps every node at a position such that all edges point from a
+// lower to a higher position. Adding an edge that already agrees with the
+// order costs O(1); otherwise only the nodes between the two endpoints are
+// searched and reordered, which also detects cycles without a full DFS.
+// Removing edges never invalidates the order. Removed nodes leave a
+// tombstone behind, and the order is compacted once tombstones make up half
+// of it, so removal is amortized O(1).
+
+// topoOrder is the maintained topological order of a graph
+type topoOrder[K comparable] struct {
+	nodes   []K       // Position -> node, including tombstones
+	index   map[K]int // Live node -> position
+	edges   int       // Number of edges the order accounts for
+	removed int       // Number of tombstones in nodes
+}
+
+// reset empties the order
+func (o *topoOrder[K]) reset() {
+	o.nodes = nil
+	o.index = make(map[K]int)
+	o.edges = 0
+	o.removed = 0
+}
+
+// live reports whether the node at position pos has not been removed. A
+// tombstone's node is either gone from the index or was added again at a
+// later position.
+func (o *topoOrder[K]) live(pos int) bool {
+	at, ok := o.index[o.nodes[pos]]
+	return ok && at == pos
+}
+
+// all yields the live nodes in order
+func (o *topoOrder[K]) all() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for pos, id := range o.nodes {
+			if o.live(pos) && !yield(id) {
+				return
+			}
+		}
+	}
+}
+
+// slice returns the live nodes in order, sharing the backing array when
+// there are no tombstones. Callers must not modify it.
+func (o *topoOrder[K]) slice() []K {
+	if o.removed == 0 {
+		return o.nodes
+	}
+	ids := make([]K, 0, len(o.index))
+	for id := range o.all() {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// add places a new node (with no edges) at the end of the order
+func (o *topoOrder[K]) add(id K) {
+	if o.index == nil {
+		o.index = make(map[K]int)
+	}
+	o.index[id] = len(o.nodes)
+	o.nodes = append(o.nodes, id)
+}
+
+// remove drops a node from the order, leaving a tombstone at its position
+func (o *topoOrder[K]) remove(id K) {
+	if _, ok := o.index[id]; !ok {
+		return
+	}
+	delete(o.index, id)
+	o.removed++
+	if o.removed*2 > len(o.nodes) {
+		o.compact()
+	}
+}
+
+// compact drops the tombstones, renumbering the live nodes
+func (o *topoOrder[K]) compact() {
+	o.nodes = o.slice()
+	for pos, id := range o.nodes {
+		o.index[id] = pos
+	}
+	o.removed = 0
+}
+
+// clone returns an independent, compacted copy of the order
+func (o *topoOrder[K]) clone() topoOrder[K] {
+	c := topoOrder[K]{
+		nodes: append([]K(nil), o.slice()...),
+		index: make(map[K]int, len(o.index)),
+		edges: o.edges,
+	}
+	for pos, id := range c.nodes {
+		c.index[id] = pos
+	}
+	return c
+}
+
+// orderConsistent reports whether the maintained order covers the current
+// node and edge sets. It only stops holding if the maps were modified
+// without going through the graph's methods (caller holds g.mu).
+func (g *TypedGraph[K, V, E]) orderConsistent() bool {
+	return len(g.order.index) == len(g.nodes) && g.order.edges == len(g.edges)
+}
+
+// insertEdgeOrder updates the order for a new edge from -> to before the
+// edge is added. It returns false, leaving the order untouched, if a path
+// already leads from to back to from, i.e. the edge would close a cycle
+// (caller holds g.mu).
+func (g *TypedGraph[K, V, E]) insertEdgeOrder(from, to K) bool {
+	if from == to {
+		return false
+	}
+
+	lower, upper := g.order.index[to], g.order.index[from]
+	if upper < lower {
+		// Already ordered
+		return true
+	}
+
+	// Forward search from `to` within the affected region
+	forward, ok := g.searchAffected(to, from, func(id K) bool {
+		return g.order.index[id] <= upper
+	}, false)
+	if !ok {
+		return false
+	}
+
+	// Backward search from `from` within the affected region
+	backward, _ := g.searchAffected(from, to, func(id K) bool {
+		return g.order.index[id] >= lower
+	}, true)
+
+	g.reorder(backward, forward)
+	return true
+}
+
+// searchAffected performs a DFS from start restricted to nodes accepted by
+// inRegion. It returns the visited nodes, or false if target was reached.
+func (g *TypedGraph[K, V, E]) searchAffected(start, target K, inRegion func(K) bool, backward bool) ([]K, bool) {
+	visited := map[K]bool{start: true}
+	stack := []K{start}
+	var found []K
+
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		found = append(found, id)
+
+		node := g.nodes[id]
+		edgeIDs := node.OutEdges
+		if backward {
+			edgeIDs = node.InEdges
+		}
+
+		for _, edgeID := range edgeIDs {
+			edge, ok := g.edges[edgeID]
+			if !ok {
+				continue
+			}
+			next := edge.To
+			if backward {
+				next = edge.From
+			}
+			if next == target && !backward {
+				return nil, false
+			}
+			if !visited[next] && inRegion(next) {
+				visited[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+
+	return found, true
+}
+
+// reorder moves the backward set before the forward set, reusing the
+// positions they currently occupy
+func (g *TypedGraph[K, V, E]) reorder(backward, forward []K) {
+	byPosition := func(ids []K) {
+		sort.Slice(ids, func(i, j int) bool {
+			return g.order.index[ids[i]] < g.order.index[ids[j]]
+		})
+	}
+	byPosition(backward)
+	byPosition(forward)
+
+	moved := append(backward, forward...)
+	positions := make([]int, len(moved))
+	for i, id := range moved {
+		positions[i] = g.order.index[id]
+	}
+	sort.Ints(positions)
+
+	for i, id := range moved {
+		g.order.nodes[positions[i]] = id
+		g.order.index[id] = positions[i]
+	}
+}
@@ -0,0 +1,227 @@
+package graph
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// assertOrdered checks that every edge points forward in the sorted order
+func assertOrdered[K comparable, V, E any](t *testing.T, g *TypedGraph[K, V, E]) {
+	t.Helper()
+
+	order, err := g.TopologicalSort()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(order) != g.NodeCount() {
+		t.Fatalf("Expected %d nodes in order, got %d", g.NodeCount(), len(order))
+	}
+
+	position := make(map[K]int, len(order))
+	for i, id := range order {
+		position[id] = i
+	}
+	for id, edge := range g.Edges() {
+		if position[edge.From] >= position[edge.To] {
+			t.Fatalf("Edge %s points backwards in %v", id, order)
+		}
+	}
+}
+
+func TestIncrementalOrderRandomDAG(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	g := NewTypedGraph[int, struct{}, struct{}]()
+
+	const n = 200
+	for i := 0; i < n; i++ {
+		g.AddNode(i, struct{}{})
+	}
+
+	// Edges respecting a hidden permutation are always acyclic, and
+	// arrive in an order that disagrees with insertion order
+	perm := rng.Perm(n)
+	for i := 0; i < 1000; i++ {
+		a, b := rng.Intn(n), rng.Intn(n)
+		if a == b {
+			continue
+		}
+		if perm[a] > perm[b] {
+			a, b = b, a
+		}
+		if _, exists := g.GetEdge(EdgeID(fmt.Sprintf("%d->%d", a, b))); exists {
+			continue
+		}
+		if _, err := g.AddEdge(a, b, 1.0); err != nil {
+			t.Fatalf("Edge %d->%d rejected: %v", a, b, err)
+		}
+	}
+
+	assertOrdered(t, g)
+	if !g.IsDAG() {
+		t.Error("Graph should be a DAG")
+	}
+}
+
+func TestIncrementalOrderRejectsCycles(t *testing.T) {
+	g := NewTypedGraph[string, struct{}, struct{}]()
+	for _, id := range []string{"A", "B", "C", "D"} {
+		g.AddNode(id, struct{}{})
+	}
+
+	// Added against insertion order to force reordering
+	g.AddEdge("D", "C", 1.0)
+	g.AddEdge("C", "B", 1.0)
+	g.AddEdge("B", "A", 1.0)
+	assertOrdered(t, g)
+
+	version := g.Version()
+	if _, err := g.AddEdge("A", "D", 1.0); err == nil {
+		t.Error("Expected cycle error")
+	}
+	if _, err := g.AddEdge("A", "A", 1.0); err == nil {
+		t.Error("Expected self-loop error")
+	}
+	if g.Version() != version || g.EdgeCount() != 3 {
+		t.Error("Rejected edges should leave the graph unchanged")
+	}
+	assertOrdered(t, g)
+}
+
+func TestIncrementalOrderAfterRemoval(t *testing.T) {
+	g := NewTypedGraph[string, struct{}, struct{}]()
+	for _, id := range []string{"A", "B", "C"} {
+		g.AddNode(id, struct{}{})
+	}
+	edgeID, _ := g.AddEdge("C", "B", 1.0)
+	g.AddEdge("B", "A", 1.0)
+
+	// Removing an edge frees the reverse direction
+	g.RemoveEdge(edgeID)
+	if _, err := g.AddEdge("B", "C", 1.0); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+	assertOrdered(t, g)
+
+	g.RemoveNode("B")
+	g.AddNode("D", struct{}{})
+	if _, err := g.AddEdge("D", "C", 1.0); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+	assertOrdered(t, g)
+
+	clone := g.Clone()
+	if _, err := clone.AddEdge("C", "A", 1.0); err != nil {
+		t.Fatalf("Failed to add edge to clone: %v", err)
+	}
+	assertOrdered(t, clone)
+	assertOrdered(t, g)
+
+	g.Clear()
+	g.AddNode("X", struct{}{})
+	assertOrdered(t, g)
+}
+
+func TestIncrementalOrderTombstones(t *testing.T) {
+	g := NewTypedGraph[int, struct{}, struct{}]()
+	for i := 0; i < 10; i++ {
+		g.AddNode(i, struct{}{})
+	}
+	for i := 9; i > 0; i-- {
+		g.AddEdge(i, i-1, 1.0)
+	}
+
+	// Removing fewer than half the nodes leaves tombstones; re-adding a
+	// node must not list it twice
+	g.RemoveNode(3)
+	g.RemoveNode(5)
+	g.AddNode(3, struct{}{})
+	if _, err := g.AddEdge(4, 3, 1.0); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+	assertOrdered(t, g)
+
+	sorted, _ := g.TopologicalSort()
+	var walked []int
+	for id := range g.TopologicalOrder() {
+		walked = append(walked, id)
+	}
+	if fmt.Sprint(walked) != fmt.Sprint(sorted) {
+		t.Errorf("TopologicalOrder yielded %v, expected %v", walked, sorted)
+	}
+	for range g.TopologicalOrder() {
+		break // Stopping early must not hold the lock
+	}
+
+	// Removing most nodes compacts the order, so tombstones never
+	// outnumber live nodes
+	for _, id := range []int{0, 1, 2, 4, 6, 7} {
+		g.RemoveNode(id)
+	}
+	if len(g.order.nodes) > 2*g.NodeCount() {
+		t.Errorf("Expected a compacted order of %d nodes, got %v", g.NodeCount(), g.order.nodes)
+	}
+	assertOrdered(t, g)
+	assertOrdered(t, g.Clone())
+}
+
+// buildChain adds n nodes connected in a single chain
+func buildChain(b *testing.B, n int) *TypedGraph[int, struct{}, struct{}] {
+	g := NewTypedGraph[int, struct{}, struct{}]()
+	for i := 0; i < n; i++ {
+		g.AddNode(i, struct{}{})
+	}
+	for i := 1; i < n; i++ {
+		if _, err := g.AddEdge(i-1, i, 1.0); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return g
+}
+
+func BenchmarkAddEdge10k(b *testing.B) {
+	const n = 10000
+	rng := rand.New(rand.NewSource(1))
+
+	for i := 0; i < b.N; i++ {
+		g := NewTypedGraph[int, struct{}, struct{}]()
+		for j := 0; j < n; j++ {
+			g.AddNode(j, struct{}{})
+		}
+
+		// Edges follow a hidden permutation, so many of them disagree
+		// with insertion order and force a reorder
+		perm := rng.Perm(n)
+		for j := 0; j < 2*n; j++ {
+			a, c := rng.Intn(n), rng.Intn(n)
+			if perm[a] > perm[c] {
+				a, c = c, a
+			}
+			g.AddEdge(a, c, 1.0)
+		}
+	}
+}
+
+func BenchmarkRemoveNode10k(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		g := buildChain(b, 10000)
+		b.StartTimer()
+
+		// Removing from the front shifted the whole order every time
+		for j := 0; j < 10000; j++ {
+			g.RemoveNode(j)
+		}
+	}
+}
+
+func BenchmarkTopologicalSort10k(b *testing.B) {
+	g := buildChain(b, 10000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := g.TopologicalSort(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
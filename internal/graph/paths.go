@@ -0,0 +1,291 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// ErrNoPath is returned when no path connects the requested nodes
+var ErrNoPath = errors.New("no path between nodes")
+
+// DurationKey is the node metadata key read by CriticalPath when no cost
+// function is given. Values may be a time.Duration (counted in
+// nanoseconds) or a float64.
+const DurationKey = "duration"
+
+// Path is a sequence of nodes together with its total cost
+type Path[K comparable] struct {
+	Nodes []K
+	Cost  float64
+}
+
+// NodeCost assigns a cost to a node for CriticalPath
+type NodeCost[K comparable, V any] func(*TypedNode[K, V]) float64
+
+// MetadataDuration is the default NodeCost, reading DurationKey from the
+// node metadata; nodes without it cost nothing
+func MetadataDuration[K comparable, V any](node *TypedNode[K, V]) float64 {
+	switch d := node.Metadata[DurationKey].(type) {
+	case time.Duration:
+		return float64(d)
+	case float64:
+		return d
+	default:
+		return 0
+	}
+}
+
+// sortedOrder returns a topological order, without copying it unless
+// removed nodes must be skipped, or the cycle that prevents one (caller
+// holds g.mu)
+func (g *TypedGraph[K, V, E]) sortedOrder() ([]K, error) {
+	if g.orderConsistent() {
+		return g.order.slice(), nil
+	}
+	if order, ok := g.kahnOrder(); ok {
+		return order, nil
+	}
+	return nil, g.cycleError()
+}
+
+// ShortestPath finds the path from one node to another with the lowest sum
+// of edge weights. Negative weights are allowed since the graph is acyclic.
+func (g *TypedGraph[K, V, E]) ShortestPath(from, to K) (Path[K], error) {
+	return g.extremePath(from, to, func(candidate, best float64) bool {
+		return candidate < best
+	})
+}
+
+// LongestPath finds the path from one node to another with the highest sum
+// of edge weights
+func (g *TypedGraph[K, V, E]) LongestPath(from, to K) (Path[K], error) {
+	return g.extremePath(from, to, func(candidate, best float64) bool {
+		return candidate > best
+	})
+}
+
+// extremePath relaxes edges in topological order from the source, keeping
+// the distance for which better reports true
+func (g *TypedGraph[K, V, E]) extremePath(from, to K, better func(candidate, best float64) bool) (Path[K], error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if _, ok := g.nodes[from]; !ok {
+		return Path[K]{}, fmt.Errorf("source node %v not found", from)
+	}
+	if _, ok := g.nodes[to]; !ok {
+		return Path[K]{}, fmt.Errorf("target node %v not found", to)
+	}
+
+	order, err := g.sortedOrder()
+	if err != nil {
+		return Path[K]{}, err
+	}
+
+	dist := map[K]float64{from: 0}
+	prev := make(map[K]K)
+
+	for _, nodeID := range order {
+		d, reached := dist[nodeID]
+		if !reached {
+			continue
+		}
+		if nodeID == to {
+			break
+		}
+
+		for _, edgeID := range g.nodes[nodeID].OutEdges {
+			edge, ok := g.edges[edgeID]
+			if !ok {
+				continue
+			}
+			candidate := d + edge.Weight
+			if best, seen := dist[edge.To]; !seen || better(candidate, best) {
+				dist[edge.To] = candidate
+				prev[edge.To] = nodeID
+			}
+		}
+	}
+
+	cost, reached := dist[to]
+	if !reached {
+		return Path[K]{}, fmt.Errorf("%v to %v: %w", from, to, ErrNoPath)
+	}
+
+	return Path[K]{Nodes: tracePath(prev, from, to), Cost: cost}, nil
+}
+
+// tracePath follows predecessor links back from to and returns the path
+// in forward order
+func tracePath[K comparable](prev map[K]K, from, to K) []K {
+	path := []K{to}
+	for id := to; id != from; {
+		id = prev[id]
+		path = append(path, id)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// CriticalPath finds the chain of dependent nodes with the highest total
+// node cost, which bounds the latency of running the graph with unlimited
+// parallelism. Edge weights are ignored. A nil cost uses MetadataDuration.
+// The cost function runs under the graph's read lock and must not modify
+// the graph.
+func (g *TypedGraph[K, V, E]) CriticalPath(cost NodeCost[K, V]) (Path[K], error) {
+	if cost == nil {
+		cost = MetadataDuration[K, V]
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	order, err := g.sortedOrder()
+	if err != nil || len(order) == 0 {
+		return Path[K]{}, err
+	}
+
+	// finish[id] is the highest cost of any chain ending at id
+	finish := make(map[K]float64, len(order))
+	prev := make(map[K]K)
+	var last K
+	best := math.Inf(-1)
+
+	for _, nodeID := range order {
+		node := g.nodes[nodeID]
+
+		start, hasPrev := 0.0, false
+		for _, edgeID := range node.InEdges {
+			edge, ok := g.edges[edgeID]
+			if !ok {
+				continue
+			}
+			if f := finish[edge.From]; !hasPrev || f > start {
+				start, hasPrev = f, true
+				prev[nodeID] = edge.From
+			}
+		}
+
+		finish[nodeID] = start + cost(node)
+		if finish[nodeID] > best {
+			best = finish[nodeID]
+			last = nodeID
+		}
+	}
+
+	// Walk back to a node without dependencies
+	path := []K{last}
+	for id, ok := prev[last]; ok; id, ok = prev[id] {
+		path = append(path, id)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	return Path[K]{Nodes: path, Cost: best}, nil
+}
+
+// reachability computes, for every node, the set of nodes reachable from it
+// as bitsets indexed by topological position. It also reports which edges
+// are implied by other paths (caller holds g.mu).
+func (g *TypedGraph[K, V, E]) reachability() (order []K, reach [][]uint64, redundant []EdgeID, err error) {
+	order, err = g.sortedOrder()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	position := make(map[K]int, len(order))
+	for i, id := range order {
+		position[id] = i
+	}
+
+	words := (len(order) + 63) / 64
+	reach = make([][]uint64, len(order))
+
+	// Visit nodes after everything they reach. Children are merged in
+	// topological order, so a child reachable through an earlier sibling is
+	// already present when it is visited, and its edge is redundant.
+	for i := len(order) - 1; i >= 0; i-- {
+		set := make([]uint64, words)
+		edges := make([]*TypedEdge[K, E], 0, len(g.nodes[order[i]].OutEdges))
+		for _, edgeID := range g.nodes[order[i]].OutEdges {
+			if edge, ok := g.edges[edgeID]; ok {
+				edges = append(edges, edge)
+			}
+		}
+		sort.Slice(edges, func(a, b int) bool {
+			return position[edges[a].To] < position[edges[b].To]
+		})
+
+		for _, edge := range edges {
+			child := position[edge.To]
+			if set[child/64]&(1<<(child%64)) != 0 {
+				redundant = append(redundant, edge.ID)
+				continue
+			}
+			set[child/64] |= 1 << (child % 64)
+			for w, bitsOfChild := range reach[child] {
+				set[w] |= bitsOfChild
+			}
+		}
+		reach[i] = set
+	}
+
+	return order, reach, redundant, nil
+}
+
+// TransitiveReduction returns a copy of the graph without edges implied by
+// longer paths, keeping the same reachability with the fewest edges
+func (g *TypedGraph[K, V, E]) TransitiveReduction() (*TypedGraph[K, V, E], error) {
+	reduced := g.Clone()
+
+	reduced.mu.RLock()
+	_, _, redundant, err := reduced.reachability()
+	reduced.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, edgeID := range redundant {
+		reduced.RemoveEdge(edgeID)
+	}
+	return reduced, nil
+}
+
+// TransitiveClosure returns a copy of the graph with an edge from every node
+// to every node reachable from it. Added edges have weight 1 and a zero
+// payload.
+func (g *TypedGraph[K, V, E]) TransitiveClosure() (*TypedGraph[K, V, E], error) {
+	closure := g.Clone()
+
+	closure.mu.RLock()
+	order, reach, _, err := closure.reachability()
+	closure.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, set := range reach {
+		for w, word := range set {
+			for word != 0 {
+				j := w*64 + bits.TrailingZeros64(word)
+				word &= word - 1
+
+				// Existing edges are kept as they are
+				if _, exists := closure.GetEdge(EdgeID(fmt.Sprintf("%v->%v", order[i], order[j]))); exists {
+					continue
+				}
+				if _, err := closure.AddEdge(order[i], order[j], 1.0); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return closure, nil
+}
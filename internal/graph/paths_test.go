@@ -0,0 +1,139 @@
+package graph
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// buildDiamond creates A -> B -> D and A -> C -> D with distinct weights
+func buildDiamond() *Graph {
+	g := NewGraph()
+	for _, id := range []NodeID{"A", "B", "C", "D"} {
+		g.AddNode(id, nil)
+	}
+	g.AddEdge("A", "B", 1.0)
+	g.AddEdge("B", "D", 1.0)
+	g.AddEdge("A", "C", 3.0)
+	g.AddEdge("C", "D", 4.0)
+	return g
+}
+
+func TestShortestAndLongestPath(t *testing.T) {
+	g := buildDiamond()
+
+	shortest, err := g.ShortestPath("A", "D")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !equalPath(shortest.Nodes, []NodeID{"A", "B", "D"}) || shortest.Cost != 2 {
+		t.Errorf("Unexpected shortest path %+v", shortest)
+	}
+
+	longest, err := g.LongestPath("A", "D")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !equalPath(longest.Nodes, []NodeID{"A", "C", "D"}) || longest.Cost != 7 {
+		t.Errorf("Unexpected longest path %+v", longest)
+	}
+
+	self, err := g.ShortestPath("B", "B")
+	if err != nil || !equalPath(self.Nodes, []NodeID{"B"}) || self.Cost != 0 {
+		t.Errorf("Expected trivial path, got %+v (%v)", self, err)
+	}
+
+	if _, err := g.ShortestPath("D", "A"); !errors.Is(err, ErrNoPath) {
+		t.Errorf("Expected ErrNoPath, got %v", err)
+	}
+	if _, err := g.LongestPath("A", "X"); err == nil {
+		t.Error("Expected error for missing node")
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	g := buildDiamond()
+	g.AddNode("E", nil)
+
+	durations := map[NodeID]time.Duration{
+		"A": 10 * time.Millisecond,
+		"B": 50 * time.Millisecond,
+		"C": 20 * time.Millisecond,
+		"D": 5 * time.Millisecond,
+		"E": 60 * time.Millisecond,
+	}
+	for id, d := range durations {
+		node, _ := g.GetNode(id)
+		node.Metadata[DurationKey] = d
+	}
+
+	// Edge weights favour C, durations favour B
+	path, err := g.CriticalPath(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !equalPath(path.Nodes, []NodeID{"A", "B", "D"}) {
+		t.Errorf("Expected A -> B -> D, got %v", path.Nodes)
+	}
+	if time.Duration(path.Cost) != 65*time.Millisecond {
+		t.Errorf("Expected 65ms, got %v", time.Duration(path.Cost))
+	}
+
+	// A custom cost overrides metadata
+	path, _ = g.CriticalPath(func(node *Node) float64 {
+		if node.ID == "E" {
+			return 100
+		}
+		return 1
+	})
+	if !equalPath(path.Nodes, []NodeID{"E"}) || path.Cost != 100 {
+		t.Errorf("Expected isolated E, got %+v", path)
+	}
+
+	empty, err := NewGraph().CriticalPath(nil)
+	if err != nil || len(empty.Nodes) != 0 {
+		t.Errorf("Expected empty path, got %+v (%v)", empty, err)
+	}
+}
+
+func TestTransitiveReductionAndClosure(t *testing.T) {
+	g := NewGraph()
+	for _, id := range []NodeID{"A", "B", "C", "D"} {
+		g.AddNode(id, nil)
+	}
+	g.AddEdge("A", "B", 1.0)
+	g.AddEdge("B", "C", 1.0)
+	g.AddEdge("C", "D", 1.0)
+	g.AddEdge("A", "C", 1.0) // Implied by A -> B -> C
+	g.AddEdge("A", "D", 1.0) // Implied by A -> B -> C -> D
+
+	reduced, err := g.TransitiveReduction()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reduced.EdgeCount() != 3 {
+		t.Errorf("Expected 3 edges after reduction, got %d", reduced.EdgeCount())
+	}
+	for _, id := range []EdgeID{"A->C", "A->D"} {
+		if _, ok := reduced.GetEdge(id); ok {
+			t.Errorf("Redundant edge %s should be removed", id)
+		}
+	}
+	if g.EdgeCount() != 5 {
+		t.Error("Reduction should not modify the original graph")
+	}
+
+	closure, err := reduced.TransitiveClosure()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if closure.EdgeCount() != 6 {
+		t.Errorf("Expected 6 edges in closure, got %d", closure.EdgeCount())
+	}
+	for _, id := range []EdgeID{"A->C", "A->D", "B->D"} {
+		if _, ok := closure.GetEdge(id); !ok {
+			t.Errorf("Closure should contain %s", id)
+		}
+	}
+	assertOrdered(t, closure)
+}
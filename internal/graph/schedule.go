@@ -0,0 +1,453 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSkipNode is returned by a Schedule processor to skip a node without
+// failing the run; its dependents are skipped as well
+var ErrSkipNode = errors.New("skip node")
+
+// Expansion is returned by a Schedule processor to succeed and add child
+// nodes to the graph at runtime, e.g. one per file the node found. Each
+// child depends on the node, and the node's dependents wait for every
+// child. Expansion modifies the scheduled graph itself: the children and
+// their edges stay in it after the run, so callers that schedule a graph
+// more than once should schedule a Clone. A child that cannot be inserted
+// fails the node.
+type Expansion[K comparable, V any] struct {
+	Children []ExpandedNode[K, V]
+
+	// Inserted, if set, is called once every child is in the graph and
+	// before any of them runs; it is not called if the expansion fails
+	Inserted func()
+}
+
+// ExpandedNode is a node added by an Expansion
+type ExpandedNode[K comparable, V any] struct {
+	ID   K
+	Data V
+}
+
+// Error lets processors return an Expansion in place of an error
+func (e *Expansion[K, V]) Error() string {
+	return fmt.Sprintf("node expanded into %d children", len(e.Children))
+}
+
+// ToleratedError marks a processor error that fails the node without
+// failing the run, see Tolerate
+type ToleratedError struct {
+	Err error
+}
+
+// Tolerate wraps a processor error so the node is recorded as failed while
+// the run carries on as if it had succeeded: its dependents still run and
+// the error is not returned by Schedule
+func Tolerate(err error) error {
+	return &ToleratedError{Err: err}
+}
+
+func (e *ToleratedError) Error() string { return e.Err.Error() }
+func (e *ToleratedError) Unwrap() error { return e.Err }
+
+// ResourceTagsKey is the node metadata key read for resource tags when
+// ScheduleOptions.Tags is nil. The value may be a string or a []string.
+const ResourceTagsKey = "resources"
+
+// ScheduleOptions controls how Schedule runs the nodes of a graph
+type ScheduleOptions[K comparable, V any] struct {
+	// MaxConcurrency bounds the number of nodes running at once;
+	// zero or less means unbounded
+	MaxConcurrency int
+
+	// Resources bounds how many nodes holding each tag may run at once.
+	// Tags without an entry are unbounded.
+	Resources map[string]int
+
+	// Tags returns the resource tags a node holds while it runs;
+	// defaults to reading ResourceTagsKey from the node metadata
+	Tags func(*TypedNode[K, V]) []string
+
+	// ContinueOnError keeps running branches unaffected by a failure.
+	// By default the first failure cancels running nodes and starts no
+	// new ones.
+	ContinueOnError bool
+
+	// StopOnFailure decides per node whether its failure stops the run,
+	// overriding ContinueOnError
+	StopOnFailure func(*TypedNode[K, V]) bool
+
+	// AlwaysRun marks nodes that run once all their dependencies have
+	// finished, whether or not they succeeded and even after the run was
+	// stopped. They get a context that is never cancelled, which suits
+	// cleanup work.
+	AlwaysRun func(*TypedNode[K, V]) bool
+}
+
+// NodeStatus is the final state of a node after Schedule
+type NodeStatus int
+
+const (
+	// NodeSkipped means the node returned ErrSkipNode or a dependency did
+	// not succeed
+	NodeSkipped NodeStatus = iota
+	NodeSucceeded
+	NodeFailed
+
+	// NodeCancelled means the node did not start, or was interrupted,
+	// because the run was stopped by a failure or by the context
+	NodeCancelled
+)
+
+// String returns a readable name for the status
+func (s NodeStatus) String() string {
+	switch s {
+	case NodeSkipped:
+		return "skipped"
+	case NodeSucceeded:
+		return "succeeded"
+	case NodeFailed:
+		return "failed"
+	case NodeCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+// NodeOutcome records how a node fared in a Schedule run
+type NodeOutcome struct {
+	Status NodeStatus
+	Err    error
+	Start  time.Time
+	End    time.Time
+}
+
+// Duration returns how long the node ran
+func (o NodeOutcome) Duration() time.Duration {
+	return o.End.Sub(o.Start)
+}
+
+// metadataTags is the default ScheduleOptions.Tags
+func metadataTags[K comparable, V any](node *TypedNode[K, V]) []string {
+	switch tags := node.Metadata[ResourceTagsKey].(type) {
+	case string:
+		return []string{tags}
+	case []string:
+		return tags
+	default:
+		return nil
+	}
+}
+
+// scheduledNode is the scheduler's bookkeeping for one node
+type scheduledNode[K comparable, V any] struct {
+	node       *TypedNode[K, V]
+	pending    int  // Dependencies not yet finished
+	skipped    bool // A dependency failed or was skipped
+	cancelled  bool // A dependency was cancelled
+	always     bool // Runs regardless of failures
+	dependents []K
+	tags       []string
+}
+
+// nodeResult is sent by a worker when its node finishes
+type nodeResult[K comparable] struct {
+	id         K
+	err        error
+	start, end time.Time
+}
+
+// Schedule runs processor on every node, starting each one as soon as all
+// of its dependencies have succeeded, within the concurrency and resource
+// limits. It returns the outcome of every node and the node errors joined
+// together, plus the context error if the run was cancelled.
+func (g *TypedGraph[K, V, E]) Schedule(ctx context.Context, opts ScheduleOptions[K, V], processor func(context.Context, *TypedNode[K, V]) error) (map[K]NodeOutcome, error) {
+	tagsOf := opts.Tags
+	if tagsOf == nil {
+		tagsOf = metadataTags[K, V]
+	}
+
+	// Snapshot the graph so it is not locked while nodes run
+	g.mu.RLock()
+	order, err := g.sortedOrder()
+	if err != nil {
+		g.mu.RUnlock()
+		return nil, err
+	}
+	state := make(map[K]*scheduledNode[K, V], len(order))
+	for _, nodeID := range order {
+		state[nodeID] = &scheduledNode[K, V]{node: g.nodes[nodeID]}
+	}
+	for _, nodeID := range order {
+		for _, edgeID := range g.nodes[nodeID].OutEdges {
+			if edge, ok := g.edges[edgeID]; ok {
+				state[nodeID].dependents = append(state[nodeID].dependents, edge.To)
+				state[edge.To].pending++
+			}
+		}
+		state[nodeID].tags = tagsOf(g.nodes[nodeID])
+		state[nodeID].always = opts.AlwaysRun != nil && opts.AlwaysRun(g.nodes[nodeID])
+	}
+	g.mu.RUnlock()
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outcomes := make(map[K]NodeOutcome, len(order))
+	results := make(chan nodeResult[K])
+	inUse := make(map[string]int)
+	var ready []K
+	var errs []error
+	running := 0
+	stopped := false
+
+	for _, nodeID := range order {
+		if state[nodeID].pending == 0 {
+			ready = append(ready, nodeID)
+		}
+	}
+
+	// release marks the dependents of a finished node, skipping or
+	// cancelling those that can no longer run and queueing those that
+	// became ready
+	var release func(K, NodeStatus)
+	release = func(id K, status NodeStatus) {
+		for _, dep := range state[id].dependents {
+			s := state[dep]
+			s.pending--
+			switch status {
+			case NodeFailed, NodeSkipped:
+				s.skipped = true
+			case NodeCancelled:
+				s.cancelled = true
+			}
+			if s.pending > 0 {
+				continue
+			}
+
+			if s.always || (!s.skipped && !s.cancelled) {
+				ready = append(ready, dep)
+				continue
+			}
+			// A real failure upstream explains more than a cancellation
+			depStatus := NodeCancelled
+			if s.skipped {
+				depStatus = NodeSkipped
+			}
+			outcomes[dep] = NodeOutcome{Status: depStatus}
+			release(dep, depStatus)
+		}
+	}
+
+	fits := func(s *scheduledNode[K, V]) bool {
+		for _, tag := range s.tags {
+			if limit, ok := opts.Resources[tag]; ok && inUse[tag] >= limit {
+				return false
+			}
+		}
+		return true
+	}
+
+	// expand adds the children of an Expansion between a node and its
+	// dependents and queues them
+	expand := func(id K, expansion *Expansion[K, V]) error {
+		children := expansion.Children
+
+		// Insert every child before touching the scheduler state, so a
+		// rejected node or edge, e.g. a child ID already in the graph,
+		// leaves neither half-expanded
+		parent := state[id]
+		var added []K
+		insert := func(child ExpandedNode[K, V]) error {
+			if err := g.AddNode(child.ID, child.Data); err != nil {
+				return err
+			}
+			added = append(added, child.ID)
+			if _, err := g.AddEdge(id, child.ID, 1.0); err != nil {
+				return err
+			}
+			for _, dep := range parent.dependents {
+				if _, err := g.AddEdge(child.ID, dep, 1.0); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, child := range children {
+			if err := insert(child); err != nil {
+				for _, childID := range added {
+					g.RemoveNode(childID)
+				}
+				return fmt.Errorf("expanding into %v: %w", child.ID, err)
+			}
+		}
+		if expansion.Inserted != nil {
+			expansion.Inserted()
+		}
+
+		for _, child := range children {
+			for _, dep := range parent.dependents {
+				state[dep].pending++
+			}
+
+			node, _ := g.GetNode(child.ID)
+			state[child.ID] = &scheduledNode[K, V]{
+				node:       node,
+				dependents: append([]K(nil), parent.dependents...),
+				tags:       tagsOf(node),
+				always:     opts.AlwaysRun != nil && opts.AlwaysRun(node),
+			}
+			order = append(order, child.ID)
+			ready = append(ready, child.ID)
+		}
+		return nil
+	}
+
+	fail := func(id K, err error, outcome NodeOutcome) {
+		outcome.Status = NodeFailed
+		outcome.Err = err
+		outcomes[id] = outcome
+		errs = append(errs, fmt.Errorf("node %v: %w", id, err))
+		stop := !opts.ContinueOnError
+		if opts.StopOnFailure != nil {
+			stop = opts.StopOnFailure(state[id].node)
+		}
+		if stop {
+			stopped = true
+			cancel()
+		}
+		release(id, NodeFailed)
+	}
+
+	finish := func(r nodeResult[K]) {
+		running--
+		for _, tag := range state[r.id].tags {
+			inUse[tag]--
+		}
+
+		outcome := NodeOutcome{Start: r.start, End: r.end}
+		var expansion *Expansion[K, V]
+		var tolerated *ToleratedError
+		switch {
+		case errors.As(r.err, &expansion):
+			if err := expand(r.id, expansion); err != nil {
+				fail(r.id, err, outcome)
+				return
+			}
+			r.err = nil
+		case errors.Is(r.err, ErrSkipNode):
+			outcome.Status = NodeSkipped
+			outcomes[r.id] = outcome
+			release(r.id, NodeSkipped)
+			return
+		case r.err != nil && stopped && errors.Is(r.err, runCtx.Err()):
+			// Interrupted by the run stopping rather than failing itself
+			outcome.Status = NodeCancelled
+			outcome.Err = r.err
+			outcomes[r.id] = outcome
+			release(r.id, NodeCancelled)
+			return
+		case errors.As(r.err, &tolerated):
+			outcome.Status = NodeFailed
+			outcome.Err = tolerated.Err
+			outcomes[r.id] = outcome
+			release(r.id, NodeSucceeded)
+			return
+		}
+		if r.err != nil {
+			fail(r.id, r.err, outcome)
+			return
+		}
+		outcome.Status = NodeSucceeded
+		outcomes[r.id] = outcome
+		release(r.id, NodeSucceeded)
+	}
+
+	for {
+		if !stopped && ctx.Err() != nil {
+			stopped = true
+		}
+
+		// Once stopped, only always-run nodes may still start; cancelling
+		// the others releases their dependents in turn
+		if stopped {
+			var keep []K
+			for len(ready) > 0 {
+				nodeID := ready[0]
+				ready = ready[1:]
+				if state[nodeID].always {
+					keep = append(keep, nodeID)
+					continue
+				}
+				outcomes[nodeID] = NodeOutcome{Status: NodeCancelled}
+				release(nodeID, NodeCancelled)
+			}
+			ready = keep
+		}
+
+		// Start every ready node that fits, in topological order
+		waiting := ready[:0]
+		for _, nodeID := range ready {
+			s := state[nodeID]
+			if (opts.MaxConcurrency > 0 && running >= opts.MaxConcurrency) || !fits(s) {
+				waiting = append(waiting, nodeID)
+				continue
+			}
+
+			running++
+			for _, tag := range s.tags {
+				inUse[tag]++
+			}
+			nodeCtx := runCtx
+			if s.always {
+				nodeCtx = context.WithoutCancel(ctx)
+			}
+			go func(ctx context.Context, node *TypedNode[K, V]) {
+				start := time.Now()
+				err := processor(ctx, node)
+				results <- nodeResult[K]{id: node.ID, err: err, start: start, end: time.Now()}
+			}(nodeCtx, s.node)
+		}
+		ready = waiting
+
+		if running == 0 {
+			// Nodes left waiting with nothing running can never fit
+			if len(ready) > 0 {
+				unsatisfiable := ready
+				ready = nil
+				for _, nodeID := range unsatisfiable {
+					fail(nodeID, fmt.Errorf("resource limits %v can never be satisfied", state[nodeID].tags), NodeOutcome{})
+				}
+				continue
+			}
+			break
+		}
+
+		select {
+		case r := <-results:
+			finish(r)
+		case <-ctx.Done():
+			if !stopped {
+				stopped = true
+				cancel()
+			}
+			finish(<-results)
+		}
+	}
+
+	// Whatever was never reached did not start because the run stopped
+	for _, nodeID := range order {
+		if _, ok := outcomes[nodeID]; !ok {
+			outcomes[nodeID] = NodeOutcome{Status: NodeCancelled}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return outcomes, errors.Join(errs...)
+}
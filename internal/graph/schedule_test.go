@@ -0,0 +1,337 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduleStreamsReadyNodes(t *testing.T) {
+	g := NewGraph()
+	// Slow -> SlowChild, Fast -> FastChild
+	for _, id := range []NodeID{"Slow", "SlowChild", "Fast", "FastChild"} {
+		g.AddNode(id, nil)
+	}
+	g.AddEdge("Slow", "SlowChild", 1.0)
+	g.AddEdge("Fast", "FastChild", 1.0)
+
+	var mu sync.Mutex
+	finished := map[NodeID]time.Time{}
+
+	outcomes, err := g.Schedule(context.Background(), ScheduleOptions[NodeID, interface{}]{}, func(ctx context.Context, node *Node) error {
+		if node.ID == "Slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		mu.Lock()
+		finished[node.ID] = time.Now()
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+
+	// FastChild must not wait for the unrelated slow node
+	if !finished["FastChild"].Before(finished["Slow"]) {
+		t.Error("FastChild should finish before Slow")
+	}
+	if !finished["Slow"].Before(finished["SlowChild"]) {
+		t.Error("SlowChild should run after Slow")
+	}
+
+	for id, outcome := range outcomes {
+		if outcome.Status != NodeSucceeded || outcome.End.Before(outcome.Start) {
+			t.Errorf("Unexpected outcome for %s: %+v", id, outcome)
+		}
+	}
+	if outcomes["Slow"].Duration() < 50*time.Millisecond {
+		t.Errorf("Slow duration too short: %v", outcomes["Slow"].Duration())
+	}
+}
+
+func TestScheduleLimits(t *testing.T) {
+	g := NewGraph()
+	for i := 0; i < 12; i++ {
+		id := NodeID(string(rune('a' + i)))
+		g.AddNode(id, nil)
+		if i%2 == 0 {
+			node, _ := g.GetNode(id)
+			node.Metadata[ResourceTagsKey] = "gpu"
+		}
+	}
+
+	var running, peak, gpu, gpuPeak atomic.Int32
+	track := func(counter, max *atomic.Int32) func() {
+		n := counter.Add(1)
+		for {
+			old := max.Load()
+			if n <= old || max.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		return func() { counter.Add(-1) }
+	}
+
+	opts := ScheduleOptions[NodeID, interface{}]{
+		MaxConcurrency: 4,
+		Resources:      map[string]int{"gpu": 1},
+	}
+	_, err := g.Schedule(context.Background(), opts, func(ctx context.Context, node *Node) error {
+		defer track(&running, &peak)()
+		if node.Metadata[ResourceTagsKey] == "gpu" {
+			defer track(&gpu, &gpuPeak)()
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Schedule failed: %v", err)
+	}
+
+	if peak.Load() > 4 {
+		t.Errorf("Concurrency limit exceeded: %d", peak.Load())
+	}
+	if gpuPeak.Load() != 1 {
+		t.Errorf("Expected one gpu node at a time, got %d", gpuPeak.Load())
+	}
+}
+
+func TestScheduleFailures(t *testing.T) {
+	build := func() *Graph {
+		g := NewGraph()
+		// Bad -> Child -> Grandchild, Good -> Other
+		for _, id := range []NodeID{"Bad", "Child", "Grandchild", "Good", "Other"} {
+			g.AddNode(id, nil)
+		}
+		g.AddEdge("Bad", "Child", 1.0)
+		g.AddEdge("Child", "Grandchild", 1.0)
+		g.AddEdge("Good", "Other", 1.0)
+		return g
+	}
+
+	boom := errors.New("boom")
+	processor := func(ctx context.Context, node *Node) error {
+		switch node.ID {
+		case "Bad":
+			return boom
+		case "Good":
+			time.Sleep(10 * time.Millisecond)
+		}
+		return ctx.Err()
+	}
+
+	opts := ScheduleOptions[NodeID, interface{}]{ContinueOnError: true}
+	outcomes, err := build().Schedule(context.Background(), opts, processor)
+	if !errors.Is(err, boom) {
+		t.Fatalf("Expected joined node error, got %v", err)
+	}
+	expected := map[NodeID]NodeStatus{
+		"Bad":        NodeFailed,
+		"Child":      NodeSkipped,
+		"Grandchild": NodeSkipped,
+		"Good":       NodeSucceeded,
+		"Other":      NodeSucceeded,
+	}
+	for id, status := range expected {
+		if outcomes[id].Status != status {
+			t.Errorf("%s: expected %s, got %s", id, status, outcomes[id].Status)
+		}
+	}
+
+	// By default the failure cancels the rest of the run
+	outcomes, err = build().Schedule(context.Background(), ScheduleOptions[NodeID, interface{}]{}, processor)
+	if !errors.Is(err, boom) {
+		t.Fatalf("Expected node error, got %v", err)
+	}
+	if outcomes["Other"].Status != NodeCancelled {
+		t.Errorf("Other should be cancelled after fail-fast, got %s", outcomes["Other"].Status)
+	}
+	if outcomes["Child"].Status != NodeSkipped {
+		t.Errorf("Child should be skipped, got %s", outcomes["Child"].Status)
+	}
+
+	// Per-node stop decisions override ContinueOnError
+	opts = ScheduleOptions[NodeID, interface{}]{
+		StopOnFailure: func(node *Node) bool { return node.ID != "Bad" },
+	}
+	outcomes, _ = build().Schedule(context.Background(), opts, processor)
+	if outcomes["Other"].Status != NodeSucceeded {
+		t.Errorf("Bad should not stop the run, got %s for Other", outcomes["Other"].Status)
+	}
+}
+
+func TestScheduleSkipAndAlwaysRun(t *testing.T) {
+	g := NewGraph()
+	// Check -> Work -> Cleanup, Fail -> Cleanup, Idle -> Cleanup
+	for _, id := range []NodeID{"Check", "Work", "Fail", "Idle", "Cleanup"} {
+		g.AddNode(id, nil)
+	}
+	g.AddEdge("Check", "Work", 1.0)
+	g.AddEdge("Work", "Cleanup", 1.0)
+	g.AddEdge("Fail", "Cleanup", 1.0)
+	g.AddEdge("Idle", "Cleanup", 1.0)
+
+	boom := errors.New("boom")
+	opts := ScheduleOptions[NodeID, interface{}]{
+		AlwaysRun: func(node *Node) bool { return node.ID == "Cleanup" },
+	}
+	var cleanupErr error
+	outcomes, err := g.Schedule(context.Background(), opts, func(ctx context.Context, node *Node) error {
+		switch node.ID {
+		case "Check":
+			return ErrSkipNode
+		case "Fail":
+			return boom
+		case "Idle":
+			time.Sleep(10 * time.Millisecond)
+			return ctx.Err()
+		case "Cleanup":
+			cleanupErr = ctx.Err()
+		}
+		return nil
+	})
+
+	if !errors.Is(err, boom) || errors.Is(err, ErrSkipNode) {
+		t.Errorf("Expected only the node failure, got %v", err)
+	}
+	expected := map[NodeID]NodeStatus{
+		"Check":   NodeSkipped,
+		"Work":    NodeSkipped,
+		"Fail":    NodeFailed,
+		"Cleanup": NodeSucceeded,
+	}
+	for id, status := range expected {
+		if outcomes[id].Status != status {
+			t.Errorf("%s: expected %s, got %s", id, status, outcomes[id].Status)
+		}
+	}
+	if cleanupErr != nil {
+		t.Errorf("Cleanup should get an uncancelled context, got %v", cleanupErr)
+	}
+}
+
+func TestScheduleExpansion(t *testing.T) {
+	g := NewGraph()
+	// List -> Join, with List expanding into one node per file
+	g.AddNode("List", nil)
+	g.AddNode("Join", nil)
+	g.AddEdge("List", "Join", 1.0)
+
+	var mu sync.Mutex
+	var ran []NodeID
+	inserted := false
+	outcomes, err := g.Schedule(context.Background(), ScheduleOptions[NodeID, interface{}]{}, func(ctx context.Context, node *Node) error {
+		mu.Lock()
+		ran = append(ran, node.ID)
+		if node.ID != "List" && !inserted {
+			t.Errorf("%s ran before Inserted was called", node.ID)
+		}
+		mu.Unlock()
+
+		switch node.ID {
+		case "List":
+			return &Expansion[NodeID, interface{}]{
+				Children: []ExpandedNode[NodeID, interface{}]{
+					{ID: "a.txt"}, {ID: "b.txt"}, {ID: "c.txt"},
+				},
+				Inserted: func() {
+					mu.Lock()
+					inserted = true
+					mu.Unlock()
+				},
+			}
+		case "b.txt":
+			return Tolerate(errors.New("unreadable"))
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Tolerated failures should not fail the run: %v", err)
+	}
+	if len(ran) != 5 || ran[0] != "List" || ran[4] != "Join" {
+		t.Errorf("Expected List, the children, then Join; got %v", ran)
+	}
+	if outcomes["b.txt"].Status != NodeFailed || outcomes["b.txt"].Err.Error() != "unreadable" {
+		t.Errorf("Expected b.txt to be recorded as failed, got %+v", outcomes["b.txt"])
+	}
+	if outcomes["List"].Status != NodeSucceeded || outcomes["Join"].Status != NodeSucceeded {
+		t.Error("Expanding node and join should succeed")
+	}
+	if deps := g.GetDependencies("Join"); len(deps) != 4 {
+		t.Errorf("Expanded nodes should stay in the graph, Join depends on %v", deps)
+	}
+
+	// Expanding into existing or duplicate nodes fails the node and
+	// leaves the graph as it was
+	for _, children := range [][]ExpandedNode[NodeID, interface{}]{
+		{{ID: "new.txt"}, {ID: "Join"}},
+		{{ID: "new.txt"}, {ID: "new.txt"}},
+	} {
+		outcomes, err = g.Schedule(context.Background(), ScheduleOptions[NodeID, interface{}]{}, func(ctx context.Context, node *Node) error {
+			if node.ID == "List" {
+				return &Expansion[NodeID, interface{}]{Children: children, Inserted: func() {
+					t.Error("Inserted should not be called for a failed expansion")
+				}}
+			}
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("Expected the rejected node in the error, got %v", err)
+		}
+		if outcomes["List"].Status != NodeFailed || outcomes["Join"].Status != NodeSkipped {
+			t.Errorf("Expected a failed expansion, got %+v", outcomes)
+		}
+		if _, ok := g.GetNode("new.txt"); ok {
+			t.Error("Children of a failed expansion should be removed")
+		}
+		if deps := g.GetDependencies("Join"); len(deps) != 4 {
+			t.Errorf("A failed expansion should not add dependencies, Join depends on %v", deps)
+		}
+	}
+}
+
+func TestScheduleUnsatisfiableResource(t *testing.T) {
+	g := NewGraph()
+	g.AddNode("A", nil)
+	node, _ := g.GetNode("A")
+	node.Metadata[ResourceTagsKey] = []string{"disk"}
+
+	opts := ScheduleOptions[NodeID, interface{}]{Resources: map[string]int{"disk": 0}}
+	outcomes, err := g.Schedule(context.Background(), opts, func(ctx context.Context, node *Node) error {
+		t.Error("Node should not run")
+		return nil
+	})
+	if err == nil || outcomes["A"].Status != NodeFailed {
+		t.Errorf("Expected failure for unsatisfiable resource, got %v (%v)", outcomes["A"], err)
+	}
+}
+
+func TestMultipassProcessorOutcomes(t *testing.T) {
+	g := NewGraph()
+	g.AddNode("A", 1)
+	g.AddNode("B", 2)
+	g.AddEdge("A", "B", 1.0)
+
+	mp := NewMultipassProcessor(g)
+	mp.SetScheduleOptions(ScheduleOptions[NodeID, interface{}]{MaxConcurrency: 1})
+	mp.AddPass(func(ctx context.Context, node *Node, deps map[NodeID]interface{}) (interface{}, error) {
+		return node.Data.(int) * 2, nil
+	})
+
+	if err := mp.Execute(context.Background()); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	outcomes := mp.PassOutcomes(0)
+	if len(outcomes) != 2 || outcomes["B"].Status != NodeSucceeded {
+		t.Errorf("Unexpected outcomes %v", outcomes)
+	}
+	if mp.PassOutcomes(1) != nil {
+		t.Error("Expected no outcomes for a pass that does not exist")
+	}
+}
@@ -0,0 +1,197 @@
+package graph
+
+import "iter"
+
+// ChangeKind describes what a Change did to the graph
+type ChangeKind int
+
+const (
+	NodeAdded ChangeKind = iota
+	NodeRemoved
+	EdgeAdded
+	EdgeRemoved
+	GraphCleared
+
+	// ChangesTruncated means older changes were discarded from the log;
+	// consumers behind this point must resynchronise from the whole graph
+	ChangesTruncated
+)
+
+// String returns a readable name for the change kind
+func (k ChangeKind) String() string {
+	switch k {
+	case NodeAdded:
+		return "node-added"
+	case NodeRemoved:
+		return "node-removed"
+	case EdgeAdded:
+		return "edge-added"
+	case EdgeRemoved:
+		return "edge-removed"
+	case GraphCleared:
+		return "graph-cleared"
+	case ChangesTruncated:
+		return "changes-truncated"
+	default:
+		return "unknown"
+	}
+}
+
+// Change records a single mutation of the graph at a version
+type Change[K comparable] struct {
+	Version uint64
+	Kind    ChangeKind
+
+	// Node for NodeAdded/NodeRemoved
+	Node K
+
+	// Edge endpoints for EdgeAdded/EdgeRemoved
+	Edge EdgeID
+	From K
+	To   K
+}
+
+// maxChangeLog bounds the number of changes kept for Changes
+const maxChangeLog = 4096
+
+// changeLog keeps the most recent changes of a graph
+type changeLog[K comparable] struct {
+	entries   []Change[K]
+	truncated uint64 // Version of the newest discarded change
+}
+
+// recordChange bumps the version and appends a change (caller holds g.mu)
+func (g *TypedGraph[K, V, E]) recordChange(change Change[K]) {
+	change.Version = g.version.Add(1)
+
+	log := &g.changes
+	log.entries = append(log.entries, change)
+	if len(log.entries) > maxChangeLog {
+		drop := len(log.entries) - maxChangeLog/2
+		log.truncated = log.entries[drop-1].Version
+		log.entries = append(log.entries[:0:0], log.entries[drop:]...)
+	}
+}
+
+// Version returns the current graph version; it increases on every change
+func (g *TypedGraph[K, V, E]) Version() uint64 {
+	return g.version.Load()
+}
+
+// Changes iterates over changes made after sinceVersion, keyed by version.
+// If some of those changes were already discarded, a ChangesTruncated
+// change is yielded first.
+func (g *TypedGraph[K, V, E]) Changes(sinceVersion uint64) iter.Seq2[uint64, Change[K]] {
+	return func(yield func(uint64, Change[K]) bool) {
+		g.mu.RLock()
+		truncated := g.changes.truncated
+		var pending []Change[K]
+		for _, change := range g.changes.entries {
+			if change.Version > sinceVersion {
+				pending = append(pending, change)
+			}
+		}
+		g.mu.RUnlock()
+
+		if sinceVersion < truncated {
+			if !yield(truncated, Change[K]{Version: truncated, Kind: ChangesTruncated}) {
+				return
+			}
+		}
+
+		for _, change := range pending {
+			if !yield(change.Version, change) {
+				return
+			}
+		}
+	}
+}
+
+// GetEdge retrieves an edge by ID
+func (g *TypedGraph[K, V, E]) GetEdge(id EdgeID) (*TypedEdge[K, E], bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	edge, exists := g.edges[id]
+	return edge, exists
+}
+
+// Nodes iterates over all nodes keyed by ID.
+// The node set is snapshotted, so the graph may be modified while iterating.
+func (g *TypedGraph[K, V, E]) Nodes() iter.Seq2[K, *TypedNode[K, V]] {
+	return func(yield func(K, *TypedNode[K, V]) bool) {
+		g.mu.RLock()
+		nodes := make([]*TypedNode[K, V], 0, len(g.nodes))
+		for _, node := range g.nodes {
+			nodes = append(nodes, node)
+		}
+		g.mu.RUnlock()
+
+		for _, node := range nodes {
+			if !yield(node.ID, node) {
+				return
+			}
+		}
+	}
+}
+
+// Edges iterates over all edges keyed by ID
+func (g *TypedGraph[K, V, E]) Edges() iter.Seq2[EdgeID, *TypedEdge[K, E]] {
+	return func(yield func(EdgeID, *TypedEdge[K, E]) bool) {
+		g.mu.RLock()
+		edges := make([]*TypedEdge[K, E], 0, len(g.edges))
+		for _, edge := range g.edges {
+			edges = append(edges, edge)
+		}
+		g.mu.RUnlock()
+
+		for _, edge := range edges {
+			if !yield(edge.ID, edge) {
+				return
+			}
+		}
+	}
+}
+
+// InNeighbors iterates over the nodes the given node depends on,
+// together with the connecting edge
+func (g *TypedGraph[K, V, E]) InNeighbors(id K) iter.Seq2[K, *TypedEdge[K, E]] {
+	return g.neighbors(id, true)
+}
+
+// OutNeighbors iterates over the nodes depending on the given node,
+// together with the connecting edge
+func (g *TypedGraph[K, V, E]) OutNeighbors(id K) iter.Seq2[K, *TypedEdge[K, E]] {
+	return g.neighbors(id, false)
+}
+
+// neighbors snapshots the in or out edges of a node and yields the other end
+func (g *TypedGraph[K, V, E]) neighbors(id K, incoming bool) iter.Seq2[K, *TypedEdge[K, E]] {
+	return func(yield func(K, *TypedEdge[K, E]) bool) {
+		g.mu.RLock()
+		node, exists := g.nodes[id]
+		var edges []*TypedEdge[K, E]
+		if exists {
+			edgeIDs := node.OutEdges
+			if incoming {
+				edgeIDs = node.InEdges
+			}
+			for _, edgeID := range edgeIDs {
+				if edge, ok := g.edges[edgeID]; ok {
+					edges = append(edges, edge)
+				}
+			}
+		}
+		g.mu.RUnlock()
+
+		for _, edge := range edges {
+			neighbor := edge.To
+			if incoming {
+				neighbor = edge.From
+			}
+			if !yield(neighbor, edge) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package graph
+
+import (
+	"testing"
+)
+
+type testStage struct {
+	Name string
+}
+
+type testDependency struct {
+	Label string
+}
+
+func TestTypedGraph(t *testing.T) {
+	g := NewTypedGraph[int, *testStage, testDependency]()
+
+	g.AddNode(1, &testStage{Name: "fetch"})
+	g.AddNode(2, &testStage{Name: "parse"})
+	g.AddNode(3, &testStage{Name: "render"})
+
+	if _, err := g.AddEdgeWithPayload(1, 2, 1.0, testDependency{Label: "raw"}); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+	if _, err := g.AddEdge(2, 3, 2.0); err != nil {
+		t.Fatalf("Failed to add edge: %v", err)
+	}
+
+	// Node data is typed, no assertion needed
+	node, _ := g.GetNode(2)
+	if node.Data.Name != "parse" {
+		t.Errorf("Expected parse, got %s", node.Data.Name)
+	}
+
+	edge, ok := g.GetEdge("1->2")
+	if !ok || edge.Payload.Label != "raw" {
+		t.Errorf("Expected typed payload, got %+v", edge)
+	}
+
+	order, err := g.TopologicalSort()
+	if err != nil || len(order) != 3 || order[0] != 1 || order[2] != 3 {
+		t.Errorf("Unexpected order %v (%v)", order, err)
+	}
+
+	if _, err := g.AddEdge(3, 1, 1.0); err == nil {
+		t.Error("Expected cycle error")
+	}
+}
+
+func TestTypedGraphIterators(t *testing.T) {
+	g := NewTypedGraph[string, int, string]()
+	g.AddNode("A", 1)
+	g.AddNode("B", 2)
+	g.AddNode("C", 3)
+	g.AddEdgeWithPayload("A", "C", 1.0, "a-c")
+	g.AddEdgeWithPayload("B", "C", 1.0, "b-c")
+
+	sum := 0
+	for id, node := range g.Nodes() {
+		if id != node.ID {
+			t.Errorf("Key %s does not match node %s", id, node.ID)
+		}
+		sum += node.Data
+	}
+	if sum != 6 {
+		t.Errorf("Expected data sum 6, got %d", sum)
+	}
+
+	edges := 0
+	for id, edge := range g.Edges() {
+		if id != edge.ID {
+			t.Errorf("Key %s does not match edge %s", id, edge.ID)
+		}
+		edges++
+	}
+	if edges != 2 {
+		t.Errorf("Expected 2 edges, got %d", edges)
+	}
+
+	in := map[string]string{}
+	for from, edge := range g.InNeighbors("C") {
+		in[from] = edge.Payload
+	}
+	if in["A"] != "a-c" || in["B"] != "b-c" {
+		t.Errorf("Unexpected in-neighbours %v", in)
+	}
+
+	var out []string
+	for to := range g.OutNeighbors("A") {
+		out = append(out, to)
+	}
+	if len(out) != 1 || out[0] != "C" {
+		t.Errorf("Unexpected out-neighbours %v", out)
+	}
+
+	// Early termination
+	count := 0
+	for range g.Nodes() {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Error("Nodes iterator should stop early")
+	}
+}
+
+func TestGraphChanges(t *testing.T) {
+	g := NewGraph()
+	g.AddNode("A", nil)
+	since := g.Version()
+
+	g.AddNode("B", nil)
+	edgeID, _ := g.AddEdge("A", "B", 1.0)
+	g.AddEdge("B", "A", 1.0) // Rejected, not recorded
+	g.RemoveEdge(edgeID)
+
+	var kinds []ChangeKind
+	last := since
+	for version, change := range g.Changes(since) {
+		if version <= last {
+			t.Errorf("Versions should increase, got %d after %d", version, last)
+		}
+		last = version
+		kinds = append(kinds, change.Kind)
+	}
+
+	expected := []ChangeKind{NodeAdded, EdgeAdded, EdgeRemoved}
+	if len(kinds) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, kinds)
+	}
+	for i := range expected {
+		if kinds[i] != expected[i] {
+			t.Errorf("Change %d: expected %s, got %s", i, expected[i], kinds[i])
+		}
+	}
+
+	if last != g.Version() {
+		t.Errorf("Last change version %d should match graph version %d", last, g.Version())
+	}
+
+	for range g.Changes(g.Version()) {
+		t.Error("No changes expected after current version")
+	}
+}
+
+func TestGraphChangesTruncated(t *testing.T) {
+	g := NewTypedGraph[int, struct{}, struct{}]()
+	for i := 0; i < maxChangeLog+10; i++ {
+		g.AddNode(i, struct{}{})
+	}
+
+	first := true
+	for _, change := range g.Changes(0) {
+		if first {
+			if change.Kind != ChangesTruncated {
+				t.Errorf("Expected truncation marker first, got %s", change.Kind)
+			}
+			first = false
+			continue
+		}
+		if change.Kind == ChangesTruncated {
+			t.Error("Truncation marker should only be yielded once")
+		}
+	}
+
+	// Recent history is still available without a marker
+	for _, change := range g.Changes(g.Version() - 1) {
+		if change.Kind != NodeAdded {
+			t.Errorf("Expected node-added, got %s", change.Kind)
+		}
+	}
+}
+
+func TestRemoveNodeCleansNeighbours(t *testing.T) {
+	g := NewGraph()
+	g.AddNode("A", nil)
+	g.AddNode("B", nil)
+	g.AddNode("C", nil)
+	g.AddEdge("A", "B", 1.0)
+	g.AddEdge("B", "C", 1.0)
+
+	if err := g.RemoveNode("B"); err != nil {
+		t.Fatalf("Failed to remove node: %v", err)
+	}
+
+	if g.EdgeCount() != 0 {
+		t.Errorf("Expected 0 edges, got %d", g.EdgeCount())
+	}
+	if deps := g.GetDependencies("C"); len(deps) != 0 {
+		t.Errorf("C should have no dependencies, got %v", deps)
+	}
+
+	order, err := g.TopologicalSort()
+	if err != nil || len(order) != 2 {
+		t.Errorf("Expected 2 sorted nodes, got %v (%v)", order, err)
+	}
+}
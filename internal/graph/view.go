@@ -0,0 +1,398 @@
+package graph
+
+import "iter"
+
+// induced copies the nodes accepted by keep together with the edges between
+// them (caller holds g.mu)
+func (g *TypedGraph[K, V, E]) induced(keep func(K) bool) *TypedGraph[K, V, E] {
+	newGraph := NewTypedGraph[K, V, E]()
+
+	for nodeID, node := range g.nodes {
+		if !keep(nodeID) {
+			continue
+		}
+		newNode := &TypedNode[K, V]{
+			ID:       node.ID,
+			Data:     node.Data,
+			Metadata: make(map[string]interface{}, len(node.Metadata)),
+			InEdges:  []EdgeID{},
+			OutEdges: []EdgeID{},
+		}
+		for k, v := range node.Metadata {
+			newNode.Metadata[k] = v
+		}
+		newGraph.nodes[nodeID] = newNode
+	}
+
+	// Edges are added in the order of the source's out-edges so that
+	// per-node edge order is preserved
+	for _, nodeID := range g.nodeIDs() {
+		newFrom, ok := newGraph.nodes[nodeID]
+		if !ok {
+			continue
+		}
+		for _, edgeID := range g.nodes[nodeID].OutEdges {
+			edge, ok := g.edges[edgeID]
+			if !ok {
+				continue
+			}
+			newTo, ok := newGraph.nodes[edge.To]
+			if !ok {
+				continue
+			}
+
+			newEdge := &TypedEdge[K, E]{
+				ID:       edge.ID,
+				From:     edge.From,
+				To:       edge.To,
+				Weight:   edge.Weight,
+				Payload:  edge.Payload,
+				Metadata: make(map[string]interface{}, len(edge.Metadata)),
+			}
+			for k, v := range edge.Metadata {
+				newEdge.Metadata[k] = v
+			}
+
+			newGraph.edges[edgeID] = newEdge
+			newFrom.OutEdges = append(newFrom.OutEdges, edgeID)
+			newTo.InEdges = append(newTo.InEdges, edgeID)
+			newGraph.fromIndex[edge.From] = append(newGraph.fromIndex[edge.From], edgeID)
+			newGraph.toIndex[edge.To] = append(newGraph.toIndex[edge.To], edgeID)
+		}
+	}
+
+	// An induced subgraph keeps the relative topological order
+	if g.orderConsistent() {
+		for nodeID := range g.order.all() {
+			if _, ok := newGraph.nodes[nodeID]; ok {
+				newGraph.order.add(nodeID)
+			}
+		}
+		newGraph.order.edges = len(newGraph.edges)
+	} else {
+		newGraph.rebuildOrder()
+	}
+
+	return newGraph
+}
+
+// Subgraph returns a copy of the given nodes and the edges between them.
+// Unknown IDs are ignored.
+func (g *TypedGraph[K, V, E]) Subgraph(ids []K) *TypedGraph[K, V, E] {
+	keep := make(map[K]bool, len(ids))
+	for _, id := range ids {
+		keep[id] = true
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.induced(func(id K) bool { return keep[id] })
+}
+
+// Ancestors iterates over every node the given node transitively depends
+// on, nearest first. The graph is read one node at a time, so stopping
+// early avoids visiting the rest.
+func (g *TypedGraph[K, V, E]) Ancestors(id K) iter.Seq[K] {
+	return g.reachable(id, true)
+}
+
+// Descendants iterates over every node transitively depending on the given
+// node, nearest first
+func (g *TypedGraph[K, V, E]) Descendants(id K) iter.Seq[K] {
+	return g.reachable(id, false)
+}
+
+// reachable walks in or out edges breadth-first, excluding the start node
+func (g *TypedGraph[K, V, E]) reachable(id K, incoming bool) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		visited := map[K]bool{id: true}
+		queue := []K{id}
+
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+
+			var next []K
+			g.mu.RLock()
+			if node, ok := g.nodes[current]; ok {
+				edgeIDs := node.OutEdges
+				if incoming {
+					edgeIDs = node.InEdges
+				}
+				for _, edgeID := range edgeIDs {
+					edge, ok := g.edges[edgeID]
+					if !ok {
+						continue
+					}
+					neighbor := edge.To
+					if incoming {
+						neighbor = edge.From
+					}
+					if !visited[neighbor] {
+						visited[neighbor] = true
+						next = append(next, neighbor)
+					}
+				}
+			}
+			g.mu.RUnlock()
+
+			for _, neighbor := range next {
+				if !yield(neighbor) {
+					return
+				}
+			}
+			queue = append(queue, next...)
+		}
+	}
+}
+
+// View is a read-only view of the nodes of a graph accepted by a predicate,
+// together with the edges between them. It reads the underlying graph on
+// every call, so it reflects later changes and costs nothing to create.
+type View[K comparable, V, E any] struct {
+	graph *TypedGraph[K, V, E]
+	pred  func(*TypedNode[K, V]) bool
+}
+
+// Filter returns a view of the nodes accepted by pred, without copying; a
+// nil pred views the whole graph. The predicate runs under the graph's read
+// lock and must not modify the graph.
+func (g *TypedGraph[K, V, E]) Filter(pred func(*TypedNode[K, V]) bool) *View[K, V, E] {
+	if pred == nil {
+		pred = func(*TypedNode[K, V]) bool { return true }
+	}
+	return &View[K, V, E]{graph: g, pred: pred}
+}
+
+// Filter narrows the view further
+func (v *View[K, V, E]) Filter(pred func(*TypedNode[K, V]) bool) *View[K, V, E] {
+	if pred == nil {
+		return v
+	}
+	outer := v.pred
+	return &View[K, V, E]{
+		graph: v.graph,
+		pred: func(node *TypedNode[K, V]) bool {
+			return outer(node) && pred(node)
+		},
+	}
+}
+
+// accepts reports whether a node is in the view (caller holds g.mu)
+func (v *View[K, V, E]) accepts(id K) bool {
+	node, ok := v.graph.nodes[id]
+	return ok && v.pred(node)
+}
+
+// GetNode retrieves a node in the view
+func (v *View[K, V, E]) GetNode(id K) (*TypedNode[K, V], bool) {
+	v.graph.mu.RLock()
+	defer v.graph.mu.RUnlock()
+
+	if !v.accepts(id) {
+		return nil, false
+	}
+	return v.graph.nodes[id], true
+}
+
+// NodeCount returns the number of nodes in the view
+func (v *View[K, V, E]) NodeCount() int {
+	v.graph.mu.RLock()
+	defer v.graph.mu.RUnlock()
+
+	count := 0
+	for _, node := range v.graph.nodes {
+		if v.pred(node) {
+			count++
+		}
+	}
+	return count
+}
+
+// Nodes iterates over the nodes in the view
+func (v *View[K, V, E]) Nodes() iter.Seq2[K, *TypedNode[K, V]] {
+	return func(yield func(K, *TypedNode[K, V]) bool) {
+		v.graph.mu.RLock()
+		var nodes []*TypedNode[K, V]
+		for _, node := range v.graph.nodes {
+			if v.pred(node) {
+				nodes = append(nodes, node)
+			}
+		}
+		v.graph.mu.RUnlock()
+
+		for _, node := range nodes {
+			if !yield(node.ID, node) {
+				return
+			}
+		}
+	}
+}
+
+// Edges iterates over the edges whose ends are both in the view
+func (v *View[K, V, E]) Edges() iter.Seq2[EdgeID, *TypedEdge[K, E]] {
+	return func(yield func(EdgeID, *TypedEdge[K, E]) bool) {
+		v.graph.mu.RLock()
+		var edges []*TypedEdge[K, E]
+		for _, edge := range v.graph.edges {
+			if v.accepts(edge.From) && v.accepts(edge.To) {
+				edges = append(edges, edge)
+			}
+		}
+		v.graph.mu.RUnlock()
+
+		for _, edge := range edges {
+			if !yield(edge.ID, edge) {
+				return
+			}
+		}
+	}
+}
+
+// InNeighbors iterates over the dependencies of a node within the view
+func (v *View[K, V, E]) InNeighbors(id K) iter.Seq2[K, *TypedEdge[K, E]] {
+	return v.neighbors(id, true)
+}
+
+// OutNeighbors iterates over the dependents of a node within the view
+func (v *View[K, V, E]) OutNeighbors(id K) iter.Seq2[K, *TypedEdge[K, E]] {
+	return v.neighbors(id, false)
+}
+
+// neighbors filters the graph's neighbours of a node in the view
+func (v *View[K, V, E]) neighbors(id K, incoming bool) iter.Seq2[K, *TypedEdge[K, E]] {
+	return func(yield func(K, *TypedEdge[K, E]) bool) {
+		v.graph.mu.RLock()
+		inView := v.accepts(id)
+		v.graph.mu.RUnlock()
+		if !inView {
+			return
+		}
+
+		for neighbor, edge := range v.graph.neighbors(id, incoming) {
+			v.graph.mu.RLock()
+			ok := v.accepts(neighbor)
+			v.graph.mu.RUnlock()
+
+			if ok && !yield(neighbor, edge) {
+				return
+			}
+		}
+	}
+}
+
+// TopologicalSort returns the nodes of the view in topological order
+func (v *View[K, V, E]) TopologicalSort() ([]K, error) {
+	v.graph.mu.RLock()
+	defer v.graph.mu.RUnlock()
+
+	order, err := v.graph.sortedOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]K, 0, len(order))
+	for _, id := range order {
+		if v.accepts(id) {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// Clone copies the view into an independent graph
+func (v *View[K, V, E]) Clone() *TypedGraph[K, V, E] {
+	v.graph.mu.RLock()
+	defer v.graph.mu.RUnlock()
+
+	return v.graph.induced(v.accepts)
+}
+
+// WeightChange records an edge whose weight differs between two graphs
+type WeightChange struct {
+	Edge     EdgeID
+	Old, New float64
+}
+
+// GraphDiff lists the differences between two graphs
+type GraphDiff[K comparable] struct {
+	AddedNodes     []K
+	RemovedNodes   []K
+	AddedEdges     []EdgeID
+	RemovedEdges   []EdgeID
+	ChangedWeights []WeightChange
+}
+
+// Empty reports whether the graphs had the same nodes, edges and weights
+func (d GraphDiff[K]) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 &&
+		len(d.ChangedWeights) == 0
+}
+
+// graphShape is a snapshot of node IDs and edge weights in a stable order
+type graphShape[K comparable] struct {
+	nodes   []K
+	edges   []EdgeID
+	hasNode map[K]bool
+	weights map[EdgeID]float64
+}
+
+// shape snapshots the graph for Diff
+func (g *TypedGraph[K, V, E]) shape() graphShape[K] {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	s := graphShape[K]{
+		nodes:   g.nodeIDs(),
+		hasNode: make(map[K]bool, len(g.nodes)),
+		weights: make(map[EdgeID]float64, len(g.edges)),
+	}
+	for _, nodeID := range s.nodes {
+		s.hasNode[nodeID] = true
+		for _, edgeID := range g.nodes[nodeID].OutEdges {
+			if edge, ok := g.edges[edgeID]; ok {
+				s.edges = append(s.edges, edgeID)
+				s.weights[edgeID] = edge.Weight
+			}
+		}
+	}
+	return s
+}
+
+// Diff reports what changed going from g to other: nodes and edges only in
+// other are added, those only in g are removed. Node data, payloads and
+// metadata are not compared.
+func (g *TypedGraph[K, V, E]) Diff(other *TypedGraph[K, V, E]) GraphDiff[K] {
+	// Snapshot separately so the two locks are never held together
+	before, after := g.shape(), other.shape()
+
+	var diff GraphDiff[K]
+	for _, id := range after.nodes {
+		if !before.hasNode[id] {
+			diff.AddedNodes = append(diff.AddedNodes, id)
+		}
+	}
+	for _, id := range before.nodes {
+		if !after.hasNode[id] {
+			diff.RemovedNodes = append(diff.RemovedNodes, id)
+		}
+	}
+
+	for _, id := range after.edges {
+		old, existed := before.weights[id]
+		if !existed {
+			diff.AddedEdges = append(diff.AddedEdges, id)
+		} else if weight := after.weights[id]; weight != old {
+			diff.ChangedWeights = append(diff.ChangedWeights, WeightChange{Edge: id, Old: old, New: weight})
+		}
+	}
+	for _, id := range before.edges {
+		if _, exists := after.weights[id]; !exists {
+			diff.RemovedEdges = append(diff.RemovedEdges, id)
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,150 @@
+package graph
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSubgraph(t *testing.T) {
+	g := buildDiamond()
+	node, _ := g.GetNode("B")
+	node.Metadata["kind"] = "parse"
+
+	sub := g.Subgraph([]NodeID{"A", "B", "D", "X"})
+	if sub.NodeCount() != 3 || sub.EdgeCount() != 2 {
+		t.Fatalf("Expected 3 nodes and 2 edges, got %d and %d", sub.NodeCount(), sub.EdgeCount())
+	}
+	if _, ok := sub.GetEdge("A->C"); ok {
+		t.Error("Edges to excluded nodes should be dropped")
+	}
+
+	node, _ = sub.GetNode("B")
+	node.Metadata["kind"] = "changed"
+	original, _ := g.GetNode("B")
+	if original.Metadata["kind"] != "parse" {
+		t.Error("Subgraph metadata should be independent")
+	}
+
+	assertOrdered(t, sub)
+	if _, err := sub.AddEdge("D", "A", 1.0); err == nil {
+		t.Error("Subgraph should still reject cycles")
+	}
+}
+
+func TestAncestorsAndDescendants(t *testing.T) {
+	g := buildDiamond()
+	g.AddNode("E", nil)
+	g.AddEdge("D", "E", 1.0)
+
+	ancestors := slices.Collect(g.Ancestors("D"))
+	slices.Sort(ancestors)
+	if !equalPath(ancestors, []NodeID{"A", "B", "C"}) {
+		t.Errorf("Unexpected ancestors %v", ancestors)
+	}
+
+	descendants := slices.Collect(g.Descendants("A"))
+	if len(descendants) != 4 || descendants[len(descendants)-1] != "E" {
+		t.Errorf("Expected nearest-first descendants ending in E, got %v", descendants)
+	}
+
+	for range g.Ancestors("A") {
+		t.Error("A has no ancestors")
+	}
+
+	// Early termination
+	count := 0
+	for range g.Descendants("A") {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Error("Descendants iterator should stop early")
+	}
+}
+
+func TestFilterView(t *testing.T) {
+	g := buildDiamond()
+	for _, id := range []NodeID{"A", "B", "D"} {
+		node, _ := g.GetNode(id)
+		node.Metadata["hot"] = true
+	}
+
+	view := g.Filter(func(node *Node) bool { return node.Metadata["hot"] == true })
+	if view.NodeCount() != 3 {
+		t.Errorf("Expected 3 nodes in view, got %d", view.NodeCount())
+	}
+	if _, ok := view.GetNode("C"); ok {
+		t.Error("C should be filtered out")
+	}
+
+	edges := 0
+	for range view.Edges() {
+		edges++
+	}
+	if edges != 2 {
+		t.Errorf("Expected 2 edges in view, got %d", edges)
+	}
+
+	var deps []NodeID
+	for id := range view.InNeighbors("D") {
+		deps = append(deps, id)
+	}
+	if !equalPath(deps, []NodeID{"B"}) {
+		t.Errorf("Expected D to depend on B within the view, got %v", deps)
+	}
+
+	order, err := view.TopologicalSort()
+	if err != nil || !equalPath(order, []NodeID{"A", "B", "D"}) {
+		t.Errorf("Unexpected view order %v (%v)", order, err)
+	}
+
+	// The view reflects later changes without being rebuilt
+	node, _ := g.GetNode("C")
+	node.Metadata["hot"] = true
+	if view.NodeCount() != 4 {
+		t.Error("View should see updated nodes")
+	}
+
+	narrow := view.Filter(func(node *Node) bool { return node.ID != "A" })
+	if narrow.NodeCount() != 3 {
+		t.Errorf("Expected 3 nodes in narrowed view, got %d", narrow.NodeCount())
+	}
+
+	copied := narrow.Clone()
+	if copied.NodeCount() != 3 || copied.EdgeCount() != 2 {
+		t.Errorf("Expected clone with 3 nodes and 2 edges, got %d and %d", copied.NodeCount(), copied.EdgeCount())
+	}
+
+	if g.Filter(nil).NodeCount() != 4 {
+		t.Error("Nil predicate should view the whole graph")
+	}
+}
+
+func TestGraphDiff(t *testing.T) {
+	before := buildDiamond()
+	after := before.Clone()
+
+	if diff := before.Diff(after); !diff.Empty() {
+		t.Errorf("Expected empty diff, got %+v", diff)
+	}
+
+	after.RemoveNode("C")
+	after.AddNode("E", nil)
+	after.AddEdge("D", "E", 1.0)
+	after.RemoveEdge("A->B")
+	after.AddEdge("A", "B", 9.0)
+
+	diff := before.Diff(after)
+	if !equalPath(diff.AddedNodes, []NodeID{"E"}) || !equalPath(diff.RemovedNodes, []NodeID{"C"}) {
+		t.Errorf("Unexpected node changes %+v", diff)
+	}
+	if len(diff.AddedEdges) != 1 || diff.AddedEdges[0] != "D->E" {
+		t.Errorf("Unexpected added edges %v", diff.AddedEdges)
+	}
+	if len(diff.RemovedEdges) != 2 {
+		t.Errorf("Expected A->C and C->D removed, got %v", diff.RemovedEdges)
+	}
+	if len(diff.ChangedWeights) != 1 || diff.ChangedWeights[0] != (WeightChange{Edge: "A->B", Old: 1, New: 9}) {
+		t.Errorf("Unexpected weight changes %v", diff.ChangedWeights)
+	}
+}
@@ -0,0 +1,79 @@
+// Package observer provides the callback registry behind the framework's
+// Observe methods.
+package observer
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// entry is a registered callback
+type entry[T any] struct {
+	id       uint64
+	callback func(T)
+}
+
+// List is a concurrency-safe list of callbacks. The zero value is an empty
+// list ready to use.
+type List[T any] struct {
+	entries []entry[T]
+	nextID  uint64
+	count   atomic.Int32
+	mu      sync.Mutex
+}
+
+// Add registers a callback and returns a function that removes it. The
+// returned function is idempotent.
+func (l *List[T]) Add(callback func(T)) (remove func()) {
+	l.mu.Lock()
+	l.nextID++
+	id := l.nextID
+	l.entries = append(l.entries, entry[T]{id: id, callback: callback})
+	l.count.Add(1)
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			for i, e := range l.entries {
+				if e.id == id {
+					l.entries = append(l.entries[:i:i], l.entries[i+1:]...)
+					break
+				}
+			}
+			l.count.Add(-1)
+		})
+	}
+}
+
+// Len returns the number of registered callbacks without locking, so
+// callers can skip building values nobody receives
+func (l *List[T]) Len() int {
+	return int(l.count.Load())
+}
+
+// Snapshot returns the registered callbacks in registration order
+func (l *List[T]) Snapshot() []func(T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	callbacks := make([]func(T), len(l.entries))
+	for i, e := range l.entries {
+		callbacks[i] = e.callback
+	}
+	return callbacks
+}
+
+// Notify calls every registered callback with value. Callbacks run outside
+// the lock, so they may add or remove callbacks.
+func (l *List[T]) Notify(value T) {
+	if l.Len() == 0 {
+		return
+	}
+	for _, callback := range l.Snapshot() {
+		callback(value)
+	}
+}
@@ -0,0 +1,38 @@
+package observer
+
+import (
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	var list List[int]
+	var got []int
+
+	removeFirst := list.Add(func(v int) { got = append(got, v) })
+	var removeSecond func()
+	removeSecond = list.Add(func(v int) {
+		got = append(got, v*10)
+		removeSecond() // Callbacks may remove themselves while notified
+	})
+	if list.Len() != 2 {
+		t.Fatalf("Expected 2 callbacks, got %d", list.Len())
+	}
+
+	list.Notify(1)
+	list.Notify(2)
+	if len(got) != 3 || got[0] != 1 || got[1] != 10 || got[2] != 2 {
+		t.Errorf("Expected callbacks in registration order, got %v", got)
+	}
+
+	removeFirst()
+	removeFirst() // Idempotent
+	if list.Len() != 0 || len(list.Snapshot()) != 0 {
+		t.Errorf("Expected an empty list, got %d callbacks", list.Len())
+	}
+
+	got = nil
+	list.Notify(3)
+	if len(got) != 0 {
+		t.Errorf("Removed callbacks should not be called, got %v", got)
+	}
+}
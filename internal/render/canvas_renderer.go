@@ -22,6 +22,9 @@ type CanvasRenderer struct {
 type ClickHandler struct {
 	Bounds  core.Bounds
 	Handler func()
+
+	// Inverse maps canvas coordinates back into the space of Bounds
+	Inverse core.Transform
 }
 
 // NewCanvasRenderer creates a new canvas renderer
@@ -74,8 +77,9 @@ func (r *CanvasRenderer) Init(container interface{}) error {
 
 			// Check all click handlers
 			for _, handler := range r.clickHandlers {
-				if x >= handler.Bounds.X && x <= handler.Bounds.X+handler.Bounds.Width &&
-					y >= handler.Bounds.Y && y <= handler.Bounds.Y+handler.Bounds.Height {
+				p := handler.Inverse.Apply(core.Offset{X: x, Y: y})
+				if p.X >= handler.Bounds.X && p.X <= handler.Bounds.X+handler.Bounds.Width &&
+					p.Y >= handler.Bounds.Y && p.Y <= handler.Bounds.Y+handler.Bounds.Height {
 					if handler.Handler != nil {
 						handler.Handler()
 					}
@@ -114,6 +118,12 @@ func (r *CanvasRenderer) Paint(cmd PaintCommand) {
 	// Save context state
 	r.ctx.Call("save")
 
+	// Apply accumulated transform
+	if !cmd.Transform.IsIdentity() {
+		m := cmd.Transform.Matrix
+		r.ctx.Call("transform", m[0], m[1], m[2], m[3], m[4], m[5])
+	}
+
 	// Draw background if specified
 	if cmd.Background.A > 0 || cmd.Type == PaintContainer {
 		if cmd.Background.A > 0 {
@@ -177,12 +187,15 @@ func (r *CanvasRenderer) Paint(cmd PaintCommand) {
 		r.ctx.Set("textAlign", "start")
 		r.ctx.Set("textBaseline", "top")
 
-		// Register click handler
+		// Register click handler (a collapsed transform can't be clicked)
 		if cmd.OnClick != nil {
-			r.clickHandlers = append(r.clickHandlers, ClickHandler{
-				Bounds:  cmd.Bounds,
-				Handler: cmd.OnClick,
-			})
+			if inverse, ok := cmd.Transform.Invert(); ok {
+				r.clickHandlers = append(r.clickHandlers, ClickHandler{
+					Bounds:  cmd.Bounds,
+					Handler: cmd.OnClick,
+					Inverse: inverse,
+				})
+			}
 		}
 	}
 
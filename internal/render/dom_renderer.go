@@ -89,6 +89,9 @@ func (r *DOMRenderer) Paint(cmd PaintCommand) {
 		style.Set("height", fmt.Sprintf("%fpx", cmd.Bounds.Height))
 	}
 
+	// Apply transform (the browser takes care of hit testing)
+	applyCSSTransform(style, cmd)
+
 	// Apply styling
 	if cmd.Background.A > 0 {
 		style.Set("backgroundColor", formatColor(cmd.Background))
@@ -144,8 +147,11 @@ func (r *DOMRenderer) ApplyUpdates(updates []PaintCommand, allCommands []PaintCo
 	// DOM can handle selective updates
 	for _, cmd := range updates {
 		if elem, exists := r.elements[cmd.ID]; exists {
-			if cmd.Type == UpdateText {
+			switch cmd.Type {
+			case UpdateText:
 				elem.Set("textContent", cmd.Text)
+			case UpdateTransform:
+				applyCSSTransform(elem.Get("style"), cmd)
 			}
 			// Add more update types as needed
 		} else {
@@ -162,6 +168,23 @@ func (r *DOMRenderer) Name() string {
 	return "DOM"
 }
 
+// applyCSSTransform sets the CSS transform for a command.
+// Elements are positioned absolutely with their own left/top, so the
+// accumulated transform is rebased onto the element's top-left corner.
+func applyCSSTransform(style js.Value, cmd PaintCommand) {
+	if cmd.Transform.IsIdentity() {
+		style.Set("transform", "")
+		return
+	}
+
+	local := core.TranslateTransform(-cmd.Bounds.X, -cmd.Bounds.Y).
+		Multiply(cmd.Transform).
+		Multiply(core.TranslateTransform(cmd.Bounds.X, cmd.Bounds.Y)).Matrix
+	style.Set("transformOrigin", "0 0")
+	style.Set("transform", fmt.Sprintf("matrix(%f,%f,%f,%f,%f,%f)",
+		local[0], local[1], local[2], local[3], local[4], local[5]))
+}
+
 func formatColor(c core.Color) string {
 	return fmt.Sprintf("rgba(%d,%d,%d,%f)",
 		c.R, c.G, c.B, float64(c.A)/255.0)
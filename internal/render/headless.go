@@ -0,0 +1,161 @@
+package render
+
+import (
+	"slices"
+	"sync"
+)
+
+// Frame is one full paint recorded by a HeadlessRenderer, from BeginFrame
+// to EndFrame
+type Frame struct {
+	Commands []PaintCommand
+}
+
+// HeadlessRenderer renders to memory, so tests and servers can assert what
+// would be drawn without a browser. It records every full frame and every
+// batch of selective updates, and keeps the commands currently on its
+// surface.
+type HeadlessRenderer struct {
+	width  float64
+	height float64
+
+	// Selective reports whether ApplyUpdates accepts updates; when false
+	// the pipeline falls back to full redraws like the canvas renderer
+	selective bool
+
+	frames  []Frame
+	updates [][]PaintCommand
+
+	// Commands painted since BeginFrame and those on the surface
+	painting  []PaintCommand
+	displayed []PaintCommand
+
+	mu sync.Mutex
+}
+
+// NewHeadlessRenderer creates a headless renderer of the given size that
+// accepts selective updates
+func NewHeadlessRenderer(width, height float64) *HeadlessRenderer {
+	return &HeadlessRenderer{
+		width:     width,
+		height:    height,
+		selective: true,
+	}
+}
+
+// SetSelectiveUpdates decides whether ApplyUpdates handles updates or asks
+// for a full redraw
+func (r *HeadlessRenderer) SetSelectiveUpdates(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.selective = enabled
+}
+
+// Init accepts any container, including nil
+func (r *HeadlessRenderer) Init(container interface{}) error {
+	return nil
+}
+
+func (r *HeadlessRenderer) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.painting = nil
+	r.displayed = nil
+}
+
+func (r *HeadlessRenderer) BeginFrame() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.painting = nil
+}
+
+func (r *HeadlessRenderer) Paint(cmd PaintCommand) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.painting = append(r.painting, cmd)
+}
+
+func (r *HeadlessRenderer) EndFrame() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames = append(r.frames, Frame{Commands: r.painting})
+	r.displayed = slices.Clone(r.painting)
+	r.painting = nil
+}
+
+func (r *HeadlessRenderer) ApplyUpdates(updates []PaintCommand, allCommands []PaintCommand) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.selective {
+		return false
+	}
+	r.updates = append(r.updates, slices.Clone(updates))
+	r.displayed = slices.Clone(allCommands)
+	return true
+}
+
+func (r *HeadlessRenderer) Resize(width, height float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.width = width
+	r.height = height
+}
+
+func (r *HeadlessRenderer) Name() string {
+	return "headless"
+}
+
+// Size returns the size of the surface
+func (r *HeadlessRenderer) Size() (width, height float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.width, r.height
+}
+
+// Frames returns the full frames painted so far, oldest first
+func (r *HeadlessRenderer) Frames() []Frame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.frames)
+}
+
+// Updates returns the batches of selective updates applied so far, oldest
+// first
+func (r *HeadlessRenderer) Updates() [][]PaintCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.updates)
+}
+
+// Displayed returns the commands currently on the surface: the last full
+// frame with any later updates applied
+func (r *HeadlessRenderer) Displayed() []PaintCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.displayed)
+}
+
+// Find returns the displayed command with the given ID
+func (r *HeadlessRenderer) Find(id string) (PaintCommand, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cmd := range r.displayed {
+		if cmd.ID == id {
+			return cmd, true
+		}
+	}
+	return PaintCommand{}, false
+}
+
+// Reset forgets the recorded frames and updates and clears the surface
+func (r *HeadlessRenderer) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames = nil
+	r.updates = nil
+	r.painting = nil
+	r.displayed = nil
+}
+
+// Compile-time check that HeadlessRenderer implements Renderer
+var _ Renderer = (*HeadlessRenderer)(nil)
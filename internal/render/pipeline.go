@@ -1,25 +1,58 @@
-//go:build wasm
-// +build wasm
-
 package render
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/maya-framework/maya/internal/core"
-	"github.com/maya-framework/maya/internal/graph"
 	"github.com/maya-framework/maya/internal/widgets"
 	"github.com/maya-framework/maya/internal/workflow"
 )
 
-// Pipeline implements the multipass rendering system using the actual components
+// ErrFrameOverBudget is returned by Execute when a frame is aborted for
+// running over its budget under the BudgetAbort policy
+var ErrFrameOverBudget = errors.New("frame over budget")
+
+// StagePriority decides whether a stage may be left out of a frame that is
+// over budget
+type StagePriority int
+
+const (
+	// Critical stages run in every frame
+	Critical StagePriority = iota
+
+	// Deferrable stages are skipped once the frame budget is spent and
+	// run again in the next frame; their dependents still run
+	Deferrable
+)
+
+// BudgetPolicy decides what happens to a frame that runs over its budget
+type BudgetPolicy int
+
+const (
+	// BudgetDefer skips the deferrable stages that have not started yet
+	BudgetDefer BudgetPolicy = iota
+
+	// BudgetAbort cancels the frame, which returns ErrFrameOverBudget.
+	// Dirty flags are kept, so the next frame redoes the work.
+	BudgetAbort
+)
+
+// Pipeline implements the multipass rendering system using the actual
+// components. Every frame is a run of the "render-pipeline" workflow, so
+// stage timings land in its StageMetrics.
 type Pipeline struct {
-	// Use the REAL workflow engine
+	// Use the REAL workflow engine; stage order comes from its
+	// dependencies
 	engine *workflow.WorkflowEngine
 
-	// Use the REAL graph for dependencies
-	dependencies *graph.Graph
+	// The engine compiled for the frames; reset by changes to the stages
+	// or the budget
+	def *workflow.WorkflowDefinition
 
 	// Reference to the REAL tree
 	tree *core.Tree
@@ -33,6 +66,29 @@ type Pipeline struct {
 	// Track previous commands for selective updates
 	previousCommands []PaintCommand
 	firstRender      bool
+
+	// Parallel layout of independent root subtrees
+	layoutOptions core.ParallelOptions
+
+	// Layout constraints (cache key) and visible area (cache retention)
+	constraints core.Constraints
+	viewport    core.Bounds
+
+	// Frame budget; zero means unlimited
+	budget       time.Duration
+	budgetPolicy BudgetPolicy
+	frameStart   time.Time
+
+	// The last frame and the deferrable stages it skipped
+	last     *workflow.Run
+	deferred []string
+
+	// Stops the telemetry set by SetTelemetry
+	unobserve func()
+
+	// Guards the budget, the current frame and the telemetry; stages
+	// of a frame run concurrently with the pipeline's setters
+	mu sync.Mutex
 }
 
 // Theme for styling
@@ -46,128 +102,323 @@ type Theme struct {
 // NewPipeline creates a new rendering pipeline with a specific renderer
 func NewPipeline(tree *core.Tree, renderer Renderer, theme *Theme) *Pipeline {
 	p := &Pipeline{
-		engine:       workflow.NewWorkflowEngine("render-pipeline"),
-		dependencies: graph.NewGraph(),
-		tree:         tree,
-		renderer:     renderer,
-		theme:        theme,
-		firstRender:  true,
+		engine:        workflow.NewWorkflowEngine("render-pipeline"),
+		tree:          tree,
+		renderer:      renderer,
+		theme:         theme,
+		firstRender:   true,
+		layoutOptions: core.DefaultParallelOptions(),
+		constraints: core.Constraints{
+			MinWidth:  0,
+			MaxWidth:  800,
+			MinHeight: 0,
+			MaxHeight: 600,
+		},
+		viewport: core.Bounds{Width: 800, Height: 600},
 	}
 
-	p.setupStages()
+	// The built-in stages are fixed, so failing to add them is a bug
+	if err := p.setupStages(); err != nil {
+		panic(fmt.Sprintf("render: setting up pipeline stages: %v", err))
+	}
 	return p
 }
 
 // setupStages configures all rendering stages
-func (p *Pipeline) setupStages() {
-	// Create stages using workflow.Stage
-	stages := []*workflow.Stage{
-		{
-			ID:   "mark-dirty",
-			Name: "Mark Dirty Nodes",
-			Execute: func(ctx context.Context, stageCtx *workflow.StageContext) error {
-				// Use tree's DirtyNodes iterator
-				for node := range p.tree.DirtyNodes() {
-					p.propagateDirty(node)
-				}
-				stageCtx.Output = p.tree
-				return nil
-			},
+func (p *Pipeline) setupStages() error {
+	if err := p.AddStage(&workflow.Stage{
+		ID:   "mark-dirty",
+		Name: "Mark Dirty Nodes",
+		Execute: func(ctx context.Context, stageCtx *workflow.StageContext) error {
+			// Use tree's DirtyNodes iterator
+			for node := range p.tree.DirtyNodes() {
+				p.propagateDirty(node)
+			}
+			stageCtx.Output = p.tree
+			return nil
 		},
-		{
-			ID:   "calculate-sizes",
-			Name: "Calculate Widget Sizes",
-			Execute: func(ctx context.Context, stageCtx *workflow.StageContext) error {
-				// Use PostOrderDFS for bottom-up calculation
-				for node := range p.tree.PostOrderDFS() {
+	}, Critical); err != nil {
+		return err
+	}
+
+	if err := p.AddStage(&workflow.Stage{
+		ID:   "calculate-sizes",
+		Name: "Calculate Widget Sizes",
+		Execute: func(ctx context.Context, stageCtx *workflow.StageContext) error {
+			// Bottom-up calculation; independent root subtrees are
+			// laid out in parallel since sizes only depend on descendants
+			err := p.tree.ParallelSubtreesContext(ctx, p.layoutOptions,
+				func(ctx context.Context, node *core.Node) error {
 					p.calculateNodeSize(node)
-				}
-				stageCtx.Output = p.tree
-				return nil
-			},
+					return nil
+				})
+			if err != nil {
+				return err
+			}
+			stageCtx.Output = p.tree
+			return nil
 		},
-		{
-			ID:   "assign-positions",
-			Name: "Assign Positions",
-			Execute: func(ctx context.Context, stageCtx *workflow.StageContext) error {
-				// Use PreOrderDFS for top-down positioning
-				for node := range p.tree.PreOrderDFS() {
-					p.assignNodePosition(node)
+	}, Critical, "mark-dirty"); err != nil {
+		return err
+	}
+
+	if err := p.AddStage(&workflow.Stage{
+		ID:   "assign-positions",
+		Name: "Assign Positions",
+		Execute: func(ctx context.Context, stageCtx *workflow.StageContext) error {
+			// Use PreOrderDFS for top-down positioning
+			for node := range p.tree.PreOrderDFS() {
+				p.assignNodePosition(node)
+				if cached := node.GetCachedValues(); cached != nil {
+					cached.Layout.Position = core.Offset{X: node.Bounds.X, Y: node.Bounds.Y}
 				}
-				stageCtx.Output = p.tree
-				return nil
-			},
+			}
+			stageCtx.Output = p.tree
+			return nil
 		},
-		{
-			ID:   "commit-dom",
-			Name: "Commit to DOM",
-			Execute: func(ctx context.Context, stageCtx *workflow.StageContext) error {
-				p.commitToDOM()
-				stageCtx.Output = p.tree
-				return nil
-			},
+	}, Critical, "calculate-sizes"); err != nil {
+		return err
+	}
+
+	if err := p.AddStage(&workflow.Stage{
+		ID:   "commit-dom",
+		Name: "Commit to DOM",
+		Execute: func(ctx context.Context, stageCtx *workflow.StageContext) error {
+			// Keep caches of visible nodes alive, then paint
+			p.tree.ApplyCachePolicy(p.viewport)
+			p.commitToDOM()
+			p.clearDirty()
+			stageCtx.Output = p.tree
+			return nil
 		},
+	}, Critical, "assign-positions"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddStage plugs a custom stage, e.g. accessibility or analytics, into
+// every frame after the given stages. Stages receive the tree as Input.
+// Use AddDependency to also run a stage before another one, such as
+// "commit-dom".
+func (p *Pipeline) AddStage(stage *workflow.Stage, priority StagePriority, after ...string) error {
+	if stage.Execute == nil {
+		return fmt.Errorf("stage %s has no Execute function", stage.ID)
 	}
 
-	// Add stages to workflow engine
-	for _, stage := range stages {
-		p.engine.AddStage(stage)
+	if priority == Deferrable {
+		execute := stage.Execute
+		deferrable := *stage
+		deferrable.Execute = func(ctx context.Context, stageCtx *workflow.StageContext) error {
+			if p.deferStage(stageCtx.Stage.ID) {
+				return nil
+			}
+			return execute(ctx, stageCtx)
+		}
+		stage = &deferrable
 	}
 
-	// Setup dependencies in graph
-	p.dependencies.AddNode(graph.NodeID("mark-dirty"), nil)
-	p.dependencies.AddNode(graph.NodeID("calculate-sizes"), nil)
-	p.dependencies.AddNode(graph.NodeID("assign-positions"), nil)
-	p.dependencies.AddNode(graph.NodeID("commit-dom"), nil)
+	// Validate the ordering first so a rejected stage is not left
+	// registered without it
+	for i, dep := range after {
+		if _, ok := p.engine.GetStage(dep); !ok {
+			return fmt.Errorf("stage %s cannot run after unknown stage %s", stage.ID, dep)
+		}
+		if slices.Contains(after[:i], dep) {
+			return fmt.Errorf("stage %s lists %s twice", stage.ID, dep)
+		}
+	}
 
-	p.dependencies.AddEdge(graph.NodeID("mark-dirty"), graph.NodeID("calculate-sizes"), 1.0)
-	p.dependencies.AddEdge(graph.NodeID("calculate-sizes"), graph.NodeID("assign-positions"), 1.0)
-	p.dependencies.AddEdge(graph.NodeID("assign-positions"), graph.NodeID("commit-dom"), 1.0)
+	defer p.invalidate()
+	if err := p.engine.AddStage(stage); err != nil {
+		return err
+	}
+	for _, dep := range after {
+		if err := p.engine.AddDependency(dep, stage.ID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// Execute runs the rendering pipeline
-func (p *Pipeline) Execute(ctx context.Context) error {
+// AddDependency orders stage to after stage from in every frame
+func (p *Pipeline) AddDependency(from, to string) error {
+	defer p.invalidate()
+	return p.engine.AddDependency(from, to)
+}
 
-	// Get topological order from dependency graph
-	order, err := p.dependencies.TopologicalSort()
-	if err != nil {
-		return fmt.Errorf("failed to sort stages: %w", err)
+// invalidate makes the next frame compile the engine again
+func (p *Pipeline) invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.def = nil
+}
+
+// SetLayoutParallelism configures how independent subtrees are laid out in parallel
+func (p *Pipeline) SetLayoutParallelism(opts core.ParallelOptions) {
+	p.layoutOptions = opts
+}
+
+// SetViewport sets the visible area; nodes outside it keep only weak caches
+func (p *Pipeline) SetViewport(width, height float64) {
+	p.viewport = core.Bounds{Width: width, Height: height}
+}
+
+// SetFrameBudget limits how long a frame may take; zero removes the limit
+func (p *Pipeline) SetFrameBudget(budget time.Duration, policy BudgetPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.budget = budget
+	p.budgetPolicy = policy
+	p.def = nil
+
+	if policy == BudgetAbort {
+		p.engine.SetTimeout(budget)
+	} else {
+		p.engine.SetTimeout(0)
 	}
+}
+
+// SetTelemetry records every frame as a run of the "render-pipeline"
+// workflow, with one span and duration sample per stage. Nil stops
+// recording.
+func (p *Pipeline) SetTelemetry(t *workflow.Telemetry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	// Execute stages in order
-	stageCtx := &workflow.StageContext{
-		Input:    p.tree,
-		Metadata: make(map[string]interface{}),
+	if p.unobserve != nil {
+		p.unobserve()
+		p.unobserve = nil
 	}
+	if t != nil {
+		p.unobserve = p.engine.Instrument(t)
+	}
+}
 
-	for _, nodeID := range order {
-		stageID := string(nodeID)
-		if stage, exists := p.engine.GetStage(stageID); exists {
-			stageCtx.Stage = stage
-			if err := stage.Execute(ctx, stageCtx); err != nil {
-				return fmt.Errorf("stage %s failed: %w", stageID, err)
-			}
-			// Use output as input for next stage
-			stageCtx.Input = stageCtx.Output
+// Metrics returns the stage timings of the last frame
+func (p *Pipeline) Metrics() *workflow.WorkflowMetrics {
+	if last := p.lastFrame(); last != nil {
+		return last.GetMetrics()
+	}
+	return p.engine.GetMetrics()
+}
+
+// Report returns the outcome of every stage in the last frame, or nil
+// before the first
+func (p *Pipeline) Report() *workflow.RunReport {
+	if last := p.lastFrame(); last != nil {
+		return last.Report()
+	}
+	return nil
+}
+
+// lastFrame returns the run of the last frame
+func (p *Pipeline) lastFrame() *workflow.Run {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.last
+}
+
+// Deferred returns the deferrable stages skipped in the last frame
+func (p *Pipeline) Deferred() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.deferred)
+}
+
+// Execute renders a frame by running the pipeline's workflow
+func (p *Pipeline) Execute(ctx context.Context) error {
+	// The lock is not held while the stages run, so they may use the
+	// pipeline's accessors
+	p.mu.Lock()
+	if p.def == nil {
+		def, err := p.engine.Compile()
+		if err != nil {
+			p.mu.Unlock()
+			return err
 		}
+		p.def = def
 	}
+	def := p.def
+	p.deferred = nil
+	p.frameStart = time.Now()
+	policy := p.budgetPolicy
+	p.mu.Unlock()
+
+	run, err := def.Execute(ctx, p.tree)
+	p.mu.Lock()
+	p.last = run
+	p.mu.Unlock()
+
+	if err != nil {
+		if policy == BudgetAbort && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w: %w", ErrFrameOverBudget, err)
+		}
+		return err
+	}
+
+	// Deliver this frame's tree mutations to observers as one batch
+	p.tree.FlushMutations()
 
 	return nil
 }
 
+// deferStage records a deferrable stage as skipped if the current frame
+// has spent its budget, reporting whether it should be skipped
+func (p *Pipeline) deferStage(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.budget <= 0 || time.Since(p.frameStart) <= p.budget {
+		return false
+	}
+	p.deferred = append(p.deferred, id)
+	return true
+}
+
 // propagateDirty marks ancestors as dirty
 func (p *Pipeline) propagateDirty(node *core.Node) {
+	// Transform and paint changes never affect the size of ancestors
+	if node.GetDirtyFlags()&(core.LayoutDirty|core.PropertiesDirty) == 0 {
+		return
+	}
+
 	for ancestor := range node.Ancestors() {
 		ancestor.MarkDirty(core.LayoutDirty)
 	}
 }
 
+// clearDirty resets dirty flags once a frame is committed so the next frame
+// only recomputes nodes that changed since
+func (p *Pipeline) clearDirty() {
+	for node := range p.tree.DirtyNodes() {
+		node.ClearDirty()
+	}
+}
+
 // calculateNodeSize calculates size based on widget type and children
 func (p *Pipeline) calculateNodeSize(node *core.Node) {
 	if node.Widget == nil {
 		return
 	}
 
+	bounds := node.Bounds
+	defer func() { node.SetBounds(bounds) }()
+
+	// Clean nodes reuse the size computed for the same version and constraints
+	if cached, ok := node.LookupLayout(p.constraints); ok {
+		bounds.Width = cached.Layout.Size.Width
+		bounds.Height = cached.Layout.Size.Height
+		return
+	}
+	defer func() {
+		var layout core.LayoutData
+		layout.Size.Width = bounds.Width
+		layout.Size.Height = bounds.Height
+		node.StoreLayout(p.constraints, layout)
+	}()
+
 	switch w := node.Widget.(type) {
 	case *widgets.Column:
 		// Vertical layout: sum heights, max width
@@ -182,8 +433,8 @@ func (p *Pipeline) calculateNodeSize(node *core.Node) {
 				maxWidth = child.Bounds.Width
 			}
 		}
-		node.Bounds.Width = maxWidth
-		node.Bounds.Height = totalHeight
+		bounds.Width = maxWidth
+		bounds.Height = totalHeight
 
 	case *widgets.Row:
 		// Horizontal layout: sum widths, max height
@@ -198,29 +449,26 @@ func (p *Pipeline) calculateNodeSize(node *core.Node) {
 				maxHeight = child.Bounds.Height
 			}
 		}
-		node.Bounds.Width = totalWidth
-		node.Bounds.Height = maxHeight
+		bounds.Width = totalWidth
+		bounds.Height = maxHeight
 
 	default:
 		// Regular widget uses its own layout
-		constraints := core.Constraints{
-			MinWidth:  0,
-			MaxWidth:  800,
-			MinHeight: 0,
-			MaxHeight: 600,
-		}
-		width, height := w.Layout(constraints)
-		node.Bounds.Width = width
-		node.Bounds.Height = height
+		width, height := w.Layout(p.constraints)
+		bounds.Width = width
+		bounds.Height = height
 	}
 }
 
 // assignNodePosition assigns position based on parent and layout type
 func (p *Pipeline) assignNodePosition(node *core.Node) {
+	bounds := node.Bounds
+	defer func() { node.SetBounds(bounds) }()
+
 	if node.Parent == nil {
 		// Root node
-		node.Bounds.X = 0
-		node.Bounds.Y = 0
+		bounds.X = 0
+		bounds.Y = 0
 		return
 	}
 
@@ -248,28 +496,28 @@ func (p *Pipeline) assignNodePosition(node *core.Node) {
 	switch parent.Widget.(type) {
 	case *widgets.Column:
 		// Vertical layout - children start at 0,0 relative to parent
-		node.Bounds.X = 0
-		node.Bounds.Y = 0
+		bounds.X = 0
+		bounds.Y = 0
 
 		// Add offset from previous siblings
 		for i := 0; i < childIndex; i++ {
-			node.Bounds.Y += parent.Children[i].Bounds.Height + 10 // gap
+			bounds.Y += parent.Children[i].Bounds.Height + 10 // gap
 		}
 
 	case *widgets.Row:
 		// Horizontal layout - children start at 0,0 relative to parent
-		node.Bounds.X = 0
-		node.Bounds.Y = 0
+		bounds.X = 0
+		bounds.Y = 0
 
 		// Add offset from previous siblings
 		for i := 0; i < childIndex; i++ {
-			node.Bounds.X += parent.Children[i].Bounds.Width + 10 // gap
+			bounds.X += parent.Children[i].Bounds.Width + 10 // gap
 		}
 
 	default:
 		// Default positioning - relative to parent
-		node.Bounds.X = 0
-		node.Bounds.Y = 0
+		bounds.X = 0
+		bounds.Y = 0
 	}
 }
 
@@ -326,8 +574,15 @@ func (p *Pipeline) findUpdates(newCommands []PaintCommand) []PaintCommand {
 		if prevCmd, exists := prevMap[newCmd.ID]; exists {
 			// Check if text changed
 			if newCmd.Type == PaintText && newCmd.Text != prevCmd.Text {
-				newCmd.Type = UpdateText // Mark as update
-				updates = append(updates, newCmd)
+				update := newCmd
+				update.Type = UpdateText // Mark as update
+				updates = append(updates, update)
+			}
+			// Check if transform changed (paint-only, no relayout)
+			if newCmd.Transform != prevCmd.Transform {
+				update := newCmd
+				update.Type = UpdateTransform
+				updates = append(updates, update)
 			}
 			// Add more change detection as needed
 		}
@@ -0,0 +1,337 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maya-framework/maya/internal/core"
+	"github.com/maya-framework/maya/internal/widgets"
+	"github.com/maya-framework/maya/internal/workflow"
+)
+
+// newTestPipeline renders a column holding a greeting and a button to a
+// headless renderer
+func newTestPipeline(t *testing.T) (*Pipeline, *HeadlessRenderer, *widgets.Text) {
+	t.Helper()
+
+	greeting := widgets.NewText("greeting", "Hello")
+	button := widgets.NewButton("ok", "OK", nil)
+	column := widgets.NewColumn("column", greeting, button)
+
+	root := core.NewNode("column", column)
+	root.AddChild(core.NewNode("greeting", greeting))
+	root.AddChild(core.NewNode("ok", button))
+	tree := core.NewTree()
+	tree.SetRoot(root)
+
+	renderer := NewHeadlessRenderer(800, 600)
+	return NewPipeline(tree, renderer, &Theme{}), renderer, greeting
+}
+
+func TestPipelineRendersFrame(t *testing.T) {
+	pipeline, renderer, _ := newTestPipeline(t)
+
+	if err := pipeline.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := renderer.Frames()
+	if len(frames) != 1 || len(frames[0].Commands) != 3 {
+		t.Fatalf("Expected one frame of 3 commands, got %+v", frames)
+	}
+	greeting, ok := renderer.Find("greeting")
+	if !ok || greeting.Type != PaintText || greeting.Text != "Hello" {
+		t.Errorf("Unexpected greeting command %+v", greeting)
+	}
+	button, _ := renderer.Find("ok")
+	if button.Type != PaintButton || button.Bounds.Y != greeting.Bounds.Height+10 {
+		t.Errorf("Expected the button below the greeting, got %+v", button.Bounds)
+	}
+
+	// Every stage of the frame is timed by the workflow engine
+	metrics := pipeline.Metrics()
+	for _, id := range []string{"mark-dirty", "calculate-sizes", "assign-positions", "commit-dom"} {
+		if sm, ok := metrics.StageMetrics[id]; !ok || !sm.Success {
+			t.Errorf("Missing metrics for %s", id)
+		}
+	}
+	if report := pipeline.Report(); report == nil || !report.Succeeded() || len(report.Stages) != 4 {
+		t.Errorf("Unexpected frame report %+v", report)
+	}
+}
+
+func TestPipelineSelectiveUpdates(t *testing.T) {
+	pipeline, renderer, greeting := newTestPipeline(t)
+	ctx := context.Background()
+	if err := pipeline.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := renderer.Find("greeting")
+
+	greeting.SetText("Goodbye, see you tomorrow")
+	if err := pipeline.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// New content is laid out again instead of reusing the cached size
+	after, _ := renderer.Find("greeting")
+	if after.Bounds.Width <= before.Bounds.Width {
+		t.Errorf("Expected the greeting to grow from %v, got %v", before.Bounds.Width, after.Bounds.Width)
+	}
+	if button, _ := renderer.Find("ok"); button.Bounds.Y != after.Bounds.Height+10 {
+		t.Errorf("Expected the button to stay below the greeting, got %+v", button.Bounds)
+	}
+	if column, _ := renderer.Find("column"); column.Bounds.Width < after.Bounds.Width {
+		t.Errorf("Expected the column to grow with the greeting, got %+v", column.Bounds)
+	}
+
+	updates := renderer.Updates()
+	if len(updates) != 1 || len(updates[0]) != 1 || updates[0][0].Type != UpdateText {
+		t.Fatalf("Expected one text update, got %+v", updates)
+	}
+	if cmd, _ := renderer.Find("greeting"); cmd.Text != "Goodbye, see you tomorrow" {
+		t.Errorf("Expected the new text on screen, got %q", cmd.Text)
+	}
+	if len(renderer.Frames()) != 1 {
+		t.Error("Selective updates should not repaint the frame")
+	}
+
+	// Renderers that cannot patch get a full redraw
+	renderer.SetSelectiveUpdates(false)
+	greeting.SetText("Hello again")
+	if err := pipeline.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if frames := renderer.Frames(); len(frames) != 2 || frames[1].Commands[1].Text != "Hello again" {
+		t.Errorf("Expected a second full frame, got %d", len(frames))
+	}
+
+	// Unchanged frames draw nothing
+	if err := pipeline.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(renderer.Frames()) != 2 || len(renderer.Updates()) != 1 {
+		t.Error("An unchanged frame should not reach the renderer")
+	}
+}
+
+func TestPipelineCustomStages(t *testing.T) {
+	pipeline, renderer, _ := newTestPipeline(t)
+
+	var mu sync.Mutex
+	var labels []string
+	err := pipeline.AddStage(&workflow.Stage{
+		ID: "accessibility",
+		Execute: func(ctx context.Context, sc *workflow.StageContext) error {
+			tree := sc.Input.(*core.Tree)
+			mu.Lock()
+			defer mu.Unlock()
+			for node := range tree.PreOrderDFS() {
+				labels = append(labels, string(node.ID))
+			}
+			return nil
+		},
+	}, Critical, "assign-positions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pipeline.AddDependency("accessibility", "commit-dom"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pipeline.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(labels, []string{"column", "greeting", "ok"}) {
+		t.Errorf("Unexpected accessibility pass %v", labels)
+	}
+
+	var order []string
+	for _, stage := range pipeline.Report().Stages {
+		order = append(order, stage.ID)
+	}
+	if want := []string{"mark-dirty", "calculate-sizes", "assign-positions", "accessibility", "commit-dom"}; !slices.Equal(order, want) {
+		t.Errorf("Expected %v, got %v", want, order)
+	}
+	if len(renderer.Frames()) != 1 {
+		t.Error("Expected the frame to be committed")
+	}
+
+	// A failing stage fails the frame before it is committed
+	pipeline.AddStage(&workflow.Stage{
+		ID: "broken",
+		Execute: func(ctx context.Context, sc *workflow.StageContext) error {
+			return errors.New("boom")
+		},
+	}, Critical, "mark-dirty")
+	pipeline.AddDependency("broken", "commit-dom")
+	if err := pipeline.Execute(context.Background()); err == nil {
+		t.Error("Expected the frame to fail")
+	}
+
+	if err := pipeline.AddStage(&workflow.Stage{ID: "empty"}, Critical); err == nil {
+		t.Error("Stages without Execute should be rejected")
+	}
+
+	// A stage ordered after an unknown stage is not registered unordered
+	analytics := &workflow.Stage{
+		ID:      "analytics",
+		Execute: func(ctx context.Context, sc *workflow.StageContext) error { return nil },
+	}
+	if err := pipeline.AddStage(analytics, Critical, "missing"); err == nil {
+		t.Error("Stages after an unknown stage should be rejected")
+	}
+	if err := pipeline.AddStage(analytics, Critical, "commit-dom"); err != nil {
+		t.Errorf("A rejected stage should leave its ID free, got %v", err)
+	}
+}
+
+func TestPipelineFrameBudget(t *testing.T) {
+	pipeline, renderer, _ := newTestPipeline(t)
+
+	analytics := 0
+	pipeline.AddStage(&workflow.Stage{
+		ID: "analytics",
+		Execute: func(ctx context.Context, sc *workflow.StageContext) error {
+			analytics++
+			return nil
+		},
+	}, Deferrable, "commit-dom")
+
+	ctx := context.Background()
+	if err := pipeline.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if analytics != 1 || len(pipeline.Deferred()) != 0 {
+		t.Fatal("Deferrable stages run while the frame is within budget")
+	}
+
+	// Any frame takes longer than a nanosecond
+	pipeline.SetFrameBudget(time.Nanosecond, BudgetDefer)
+	if err := pipeline.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if analytics != 1 || !slices.Equal(pipeline.Deferred(), []string{"analytics"}) {
+		t.Errorf("Expected analytics to be deferred, got %v", pipeline.Deferred())
+	}
+
+	// Aborting cancels the frame before it is committed
+	pipeline.AddStage(&workflow.Stage{
+		ID: "slow",
+		Execute: func(ctx context.Context, sc *workflow.StageContext) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}, Critical, "mark-dirty")
+	pipeline.AddDependency("slow", "commit-dom")
+	pipeline.SetFrameBudget(10*time.Millisecond, BudgetAbort)
+	renderer.Reset()
+	if err := pipeline.Execute(ctx); !errors.Is(err, ErrFrameOverBudget) {
+		t.Errorf("Expected the frame to be aborted, got %v", err)
+	}
+	if len(renderer.Frames()) != 0 {
+		t.Error("An aborted frame must not be committed")
+	}
+}
+
+func TestPipelineTelemetry(t *testing.T) {
+	pipeline, _, _ := newTestPipeline(t)
+	tel := workflow.NewTelemetry(nil, nil)
+	pipeline.SetTelemetry(tel)
+
+	for i := 0; i < 3; i++ {
+		if err := pipeline.Execute(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out strings.Builder
+	tel.Metrics().WritePrometheus(&out)
+	want := `maya_workflow_runs_total{workflow="render-pipeline",status="succeeded"} 3`
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("Missing %q in:\n%s", want, out.String())
+	}
+
+	// Nil stops recording
+	pipeline.SetTelemetry(nil)
+	pipeline.Execute(context.Background())
+	out.Reset()
+	tel.Metrics().WritePrometheus(&out)
+	if !strings.Contains(out.String(), want) {
+		t.Error("Frames after SetTelemetry(nil) should not be recorded")
+	}
+}
+
+func TestPipelineCompilesOnce(t *testing.T) {
+	pipeline, _, _ := newTestPipeline(t)
+	ctx := context.Background()
+
+	if err := pipeline.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	compiled := pipeline.def
+	if err := pipeline.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if compiled == nil || pipeline.def != compiled {
+		t.Fatal("Frames should reuse the compiled workflow")
+	}
+
+	// New stages and budgets take effect in the next frame
+	ran := false
+	pipeline.AddStage(&workflow.Stage{
+		ID: "analytics",
+		Execute: func(ctx context.Context, sc *workflow.StageContext) error {
+			ran = true
+			return nil
+		},
+	}, Critical, "commit-dom")
+	if err := pipeline.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !ran || pipeline.def == compiled {
+		t.Error("Adding a stage should recompile the workflow")
+	}
+
+	compiled = pipeline.def
+	pipeline.SetFrameBudget(time.Second, BudgetAbort)
+	if err := pipeline.Execute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pipeline.def == compiled {
+		t.Error("Changing the budget should recompile the workflow")
+	}
+}
+
+func TestPipelineConcurrentSetters(t *testing.T) {
+	pipeline, _, _ := newTestPipeline(t)
+	pipeline.AddStage(&workflow.Stage{
+		ID: "analytics",
+		Execute: func(ctx context.Context, sc *workflow.StageContext) error {
+			return nil
+		},
+	}, Deferrable, "commit-dom")
+
+	// Reconfiguring while frames run must not race with them
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			pipeline.SetFrameBudget(time.Duration(i)*time.Millisecond, BudgetPolicy(i%2))
+			pipeline.SetTelemetry(workflow.NewTelemetry(nil, nil))
+			pipeline.Deferred()
+			pipeline.Metrics()
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if err := pipeline.Execute(context.Background()); err != nil && !errors.Is(err, ErrFrameOverBudget) {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
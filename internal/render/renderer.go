@@ -1,6 +1,3 @@
-//go:build wasm
-// +build wasm
-
 package render
 
 import (
@@ -21,6 +18,10 @@ type PaintCommand struct {
 	Shadow     *ShadowStyle
 	FontSize   float64
 	OnClick    func()
+	
+	// Accumulated transform mapping Bounds (absolute layout coordinates)
+	// to the screen. Identity when the node and its ancestors are untransformed.
+	Transform  core.Transform
 }
 
 type PaintType int
@@ -30,7 +31,8 @@ const (
 	PaintText
 	PaintButton
 	PaintContainer
-	UpdateText // Selective update for text content only
+	UpdateText      // Selective update for text content only
+	UpdateTransform // Selective update for transform only
 )
 
 type BorderStyle struct {
@@ -91,6 +93,11 @@ type Transform struct {
 
 // ConvertNodeToCommands converts a node tree to paint commands
 func ConvertNodeToCommands(node *core.Node, offsetX, offsetY float64) []PaintCommand {
+	return convertNode(node, offsetX, offsetY, core.IdentityTransform())
+}
+
+// convertNode converts a node and its children, composing transforms down the tree
+func convertNode(node *core.Node, offsetX, offsetY float64, parentTransform core.Transform) []PaintCommand {
 	if node.Widget == nil {
 		return nil
 	}
@@ -101,6 +108,13 @@ func ConvertNodeToCommands(node *core.Node, offsetX, offsetY float64) []PaintCom
 	absX := node.Bounds.X + offsetX
 	absY := node.Bounds.Y + offsetY
 	
+	// The node's transform is expressed in its local space (origin at its
+	// top-left corner), so move it to the absolute position before composing
+	transform := parentTransform
+	if !node.Transform.IsIdentity() {
+		transform = parentTransform.Multiply(node.Transform.WithOrigin(absX, absY))
+	}
+	
 	// Create command based on widget type
 	cmd := PaintCommand{
 		ID:     string(node.ID), // Convert NodeID to string
@@ -110,6 +124,7 @@ func ConvertNodeToCommands(node *core.Node, offsetX, offsetY float64) []PaintCom
 			Width:  node.Bounds.Width,
 			Height: node.Bounds.Height,
 		},
+		Transform: transform,
 	}
 	
 	// Set command type and properties based on widget
@@ -162,7 +177,7 @@ func ConvertNodeToCommands(node *core.Node, offsetX, offsetY float64) []PaintCom
 	
 	// Recursively add children
 	for _, child := range node.Children {
-		childCommands := ConvertNodeToCommands(child, absX, absY)
+		childCommands := convertNode(child, absX, absY, transform)
 		commands = append(commands, childCommands...)
 	}
 	
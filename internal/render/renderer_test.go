@@ -0,0 +1,47 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/maya-framework/maya/internal/core"
+	"github.com/maya-framework/maya/internal/widgets"
+)
+
+func TestConvertNodeToCommands(t *testing.T) {
+	root := core.NewNode("row", widgets.NewRow("row"))
+	root.Bounds = core.Bounds{X: 5, Y: 5, Width: 200, Height: 40}
+
+	label := core.NewNode("label", widgets.NewText("label", "Name"))
+	label.Bounds = core.Bounds{X: 10, Y: 0, Width: 50, Height: 20}
+	root.AddChild(label)
+
+	moved := core.NewNode("moved", widgets.NewButton("moved", "Go", nil))
+	moved.Bounds = core.Bounds{X: 70, Y: 0, Width: 40, Height: 20}
+	moved.Transform = core.TranslateTransform(3, 4)
+	root.AddChild(moved)
+
+	// Widgetless nodes draw nothing
+	root.AddChild(core.NewNode("empty", nil))
+
+	commands := ConvertNodeToCommands(root, 100, 0)
+	if len(commands) != 3 {
+		t.Fatalf("Expected 3 commands, got %d", len(commands))
+	}
+
+	if cmd := commands[0]; cmd.ID != "row" || cmd.Type != PaintRect || cmd.Bounds.X != 105 {
+		t.Errorf("Unexpected root command %+v", cmd)
+	}
+	if cmd := commands[1]; cmd.Type != PaintText || cmd.Text != "Name" || cmd.Bounds.X != 115 || cmd.Bounds.Y != 5 {
+		t.Errorf("Expected the label at an absolute position, got %+v", cmd)
+	}
+	cmd := commands[2]
+	if cmd.Type != PaintButton || cmd.Text != "Go" || cmd.Bounds.X != 175 {
+		t.Errorf("Unexpected button command %+v", cmd)
+	}
+	if p := cmd.Transform.Apply(core.Offset{X: 175, Y: 5}); p.X != 178 || p.Y != 9 {
+		t.Errorf("Expected the button's transform to be applied, got %+v", p)
+	}
+	if !commands[1].Transform.IsIdentity() {
+		t.Error("Untransformed nodes should have the identity transform")
+	}
+}
@@ -0,0 +1,345 @@
+// Package telemetry provides a small metrics registry exposed in the
+// Prometheus text format and span records for tracing
+package telemetry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram bucket bounds in seconds, from 5ms to 10s
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricKind is the Prometheus type of a family
+type metricKind string
+
+const (
+	kindCounter   metricKind = "counter"
+	kindGauge     metricKind = "gauge"
+	kindHistogram metricKind = "histogram"
+)
+
+// Registry holds metric families. Asking for an existing name returns the
+// existing family, so independent components can share metrics.
+type Registry struct {
+	families map[string]*family
+	mu       sync.Mutex
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+	}
+}
+
+// family is a named metric with one series per label value combination
+type family struct {
+	name       string
+	help       string
+	kind       metricKind
+	labelNames []string
+	buckets    []float64
+	series     map[string]*series
+	mu         sync.Mutex
+}
+
+// series is the state of one label value combination
+type series struct {
+	labelValues []string
+
+	// Counters and gauges; fn overrides value when set
+	value float64
+	fn    func() float64
+
+	// Histograms: per-bucket (non-cumulative) counts
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// family returns the named family, creating it if needed. Reusing a name
+// with another type or other labels is a programming error and panics.
+func (r *Registry) family(name, help string, kind metricKind, buckets []float64, labelNames []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		if f.kind != kind || !slices.Equal(f.labelNames, labelNames) {
+			panic(fmt.Sprintf("telemetry: metric %s registered as %s%v, requested as %s%v", name, f.kind, f.labelNames, kind, labelNames))
+		}
+		return f
+	}
+
+	f := &family{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: slices.Clone(labelNames),
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+	r.families[name] = f
+	return f
+}
+
+// get returns the series of the label values, creating it if needed
+// (caller holds f.mu)
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("telemetry: metric %s expects labels %v, got %d values", f.name, f.labelNames, len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets)+1)
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter is a family of monotonically increasing values
+type Counter struct{ f *family }
+
+// Counter returns the counter family name with the given label names
+func (r *Registry) Counter(name, help string, labelNames ...string) *Counter {
+	return &Counter{r.family(name, help, kindCounter, nil, labelNames)}
+}
+
+// Add increases the series of the label values by v, which must not be
+// negative
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("telemetry: counters cannot decrease")
+	}
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.get(labelValues).value += v
+}
+
+// Inc increases the series of the label values by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the current value of a series
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	return c.f.get(labelValues).value
+}
+
+// Gauge is a family of values that go up and down
+type Gauge struct{ f *family }
+
+// Gauge returns the gauge family name with the given label names
+func (r *Registry) Gauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{r.family(name, help, kindGauge, nil, labelNames)}
+}
+
+// Set sets the series of the label values
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).value = v
+}
+
+// Add adds v, possibly negative, to the series of the label values
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).value += v
+}
+
+// SetFunc makes the series of the label values report fn at every scrape
+func (g *Gauge) SetFunc(fn func() float64, labelValues ...string) {
+	g.f.mu.Lock()
+	defer g.f.mu.Unlock()
+	g.f.get(labelValues).fn = fn
+}
+
+// Value returns the current value of a series
+func (g *Gauge) Value(labelValues ...string) float64 {
+	g.f.mu.Lock()
+	s := g.f.get(labelValues)
+	value, fn := s.value, s.fn
+	g.f.mu.Unlock()
+
+	if fn != nil {
+		return fn()
+	}
+	return value
+}
+
+// Histogram is a family of observation distributions
+type Histogram struct{ f *family }
+
+// Histogram returns the histogram family name with the given bucket upper
+// bounds, DefaultBuckets if nil, and label names
+func (r *Registry) Histogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &Histogram{r.family(name, help, kindHistogram, buckets, labelNames)}
+}
+
+// Observe records v in the series of the label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+
+	s := h.f.get(labelValues)
+	i, _ := slices.BinarySearch(h.f.buckets, v)
+	s.counts[i]++
+	s.sum += v
+	s.count++
+}
+
+// Count returns the number of observations and their sum for a series
+func (h *Histogram) Count(labelValues ...string) (uint64, float64) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+
+	s := h.f.get(labelValues)
+	return s.count, s.sum
+}
+
+// WritePrometheus writes every family in the Prometheus text exposition
+// format, families and series sorted for stable output
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+
+	slices.SortFunc(families, func(a, b *family) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	out := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(out)
+	}
+	return out.Flush()
+}
+
+// write renders a family
+func (f *family) write(out *bufio.Writer) {
+	f.mu.Lock()
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	// Copy the series so gauge functions run outside the lock
+	snapshot := make([]series, len(keys))
+	for i, key := range keys {
+		s := f.series[key]
+		snapshot[i] = *s
+		snapshot[i].counts = slices.Clone(s.counts)
+	}
+	f.mu.Unlock()
+
+	if f.help != "" {
+		fmt.Fprintf(out, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	}
+	fmt.Fprintf(out, "# TYPE %s %s\n", f.name, f.kind)
+
+	for _, s := range snapshot {
+		labels := formatLabels(f.labelNames, s.labelValues)
+
+		switch f.kind {
+		case kindHistogram:
+			var cumulative uint64
+			for i, bound := range f.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(out, "%s_bucket%s %d\n", f.name, withLabel(labels, "le", formatFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(out, "%s_bucket%s %d\n", f.name, withLabel(labels, "le", "+Inf"), s.count)
+			fmt.Fprintf(out, "%s_sum%s %s\n", f.name, labels, formatFloat(s.sum))
+			fmt.Fprintf(out, "%s_count%s %d\n", f.name, labels, s.count)
+
+		default:
+			value := s.value
+			if s.fn != nil {
+				value = s.fn()
+			}
+			fmt.Fprintf(out, "%s%s %s\n", f.name, labels, formatFloat(value))
+		}
+	}
+}
+
+// Handler serves the registry in the Prometheus text exposition format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WritePrometheus(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// formatLabels renders {name="value",...}, or nothing without labels
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, escapeLabel(values[i]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// withLabel appends a label to rendered labels
+func withLabel(labels, name, value string) string {
+	label := fmt.Sprintf("%s=\"%s\"", name, value)
+	if labels == "" {
+		return "{" + label + "}"
+	}
+	return labels[:len(labels)-1] + "," + label + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+// formatFloat renders a sample value the way Prometheus expects
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,84 @@
+package telemetry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Span is a finished, timed operation in the style of OpenTelemetry. Spans
+// of one trace share a TraceID; ParentID links a span to its enclosing
+// span and is empty for the root.
+type Span struct {
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Name     string
+	Start    time.Time
+	End      time.Time
+
+	Attributes map[string]string
+	Err        error
+}
+
+// Duration returns how long the span lasted
+func (s Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// SpanExporter receives finished spans. Exporters are called from the
+// goroutine that finished the span, so they must be safe for concurrent use
+// and should not block, e.g. by queueing spans for a background uploader.
+type SpanExporter interface {
+	ExportSpan(span Span)
+}
+
+// NewTraceID returns a random 16-byte trace ID in hex
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+// NewSpanID returns a random 8-byte span ID in hex
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// MemoryExporter keeps exported spans in memory, for tests and debugging
+type MemoryExporter struct {
+	spans []Span
+	mu    sync.Mutex
+}
+
+// NewMemoryExporter creates an empty in-memory exporter
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+// ExportSpan records a span
+func (e *MemoryExporter) ExportSpan(span Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the recorded spans in export order
+func (e *MemoryExporter) Spans() []Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.spans)
+}
+
+// Reset discards the recorded spans
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
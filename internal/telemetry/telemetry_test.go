@@ -0,0 +1,111 @@
+package telemetry
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusExposition(t *testing.T) {
+	registry := NewRegistry()
+
+	requests := registry.Counter("requests_total", "Requests served.", "path")
+	requests.Inc("/a")
+	requests.Add(2, `/b"\`)
+
+	depth := registry.Gauge("queue_depth", "Queued items.")
+	depth.Set(3)
+	depth.Add(-1)
+
+	latency := registry.Histogram("latency_seconds", "Request latency.", []float64{1, 0.1}, "path")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(7, "/a")
+
+	// Asking again shares the family
+	registry.Counter("requests_total", "ignored", "path").Inc("/a")
+
+	expected := `# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{path="/a",le="0.1"} 1
+latency_seconds_bucket{path="/a",le="1"} 2
+latency_seconds_bucket{path="/a",le="+Inf"} 3
+latency_seconds_sum{path="/a"} 7.55
+latency_seconds_count{path="/a"} 3
+# HELP queue_depth Queued items.
+# TYPE queue_depth gauge
+queue_depth 2
+# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{path="/a"} 2
+requests_total{path="/b\"\\"} 2
+`
+
+	server := httptest.NewServer(registry.Handler())
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != expected {
+		t.Errorf("Unexpected exposition:\n%s", body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+}
+
+func TestRegistryMisuse(t *testing.T) {
+	registry := NewRegistry()
+	registry.Counter("events_total", "", "kind")
+
+	for name, fn := range map[string]func(){
+		"type clash":   func() { registry.Gauge("events_total", "", "kind") },
+		"label clash":  func() { registry.Counter("events_total", "", "source") },
+		"label values": func() { registry.Counter("events_total", "", "kind").Inc("a", "b") },
+		"decrease":     func() { registry.Counter("events_total", "", "kind").Add(-1, "a") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestGaugeFuncAndMemoryExporter(t *testing.T) {
+	registry := NewRegistry()
+	n := 0
+	registry.Gauge("calls", "").SetFunc(func() float64 { n++; return float64(n) })
+
+	var out strings.Builder
+	registry.WritePrometheus(&out)
+	registry.WritePrometheus(&out)
+	if !strings.HasSuffix(out.String(), "calls 2\n") {
+		t.Errorf("Gauge functions should be evaluated per scrape:\n%s", out.String())
+	}
+
+	exporter := NewMemoryExporter()
+	start := time.Unix(0, 0)
+	exporter.ExportSpan(Span{TraceID: NewTraceID(), SpanID: NewSpanID(), Name: "work", Start: start, End: start.Add(time.Second)})
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].Duration() != time.Second || len(spans[0].TraceID) != 32 || len(spans[0].SpanID) != 16 {
+		t.Errorf("Unexpected spans %+v", spans)
+	}
+	exporter.Reset()
+	if len(exporter.Spans()) != 0 {
+		t.Error("Reset should discard spans")
+	}
+}
@@ -26,6 +26,12 @@ type BaseWidget struct {
 	needsRepaint *reactive.Signal[bool]
 	needsLayout  *reactive.Signal[bool]
 	
+	// Bumped whenever the widget needs layout, see Revision
+	revision     atomic.Uint64
+	
+	// Node the widget is attached to, dirtied when it needs layout
+	node         atomic.Pointer[core.Node]
+	
 	// Lifecycle
 	initialized atomic.Bool
 	disposed    atomic.Bool
@@ -114,7 +120,7 @@ func (w *BaseWidget) AddChild(child WidgetImpl) {
 		setter.SetParent(w)
 	}
 	
-	w.needsLayout.Set(true)
+	w.invalidateLayout()
 }
 
 // RemoveChild removes a child widget
@@ -132,7 +138,7 @@ func (w *BaseWidget) RemoveChild(child WidgetImpl) bool {
 				setter.SetParent(nil)
 			}
 			
-			w.needsLayout.Set(true)
+			w.invalidateLayout()
 			return true
 		}
 	}
@@ -151,7 +157,7 @@ func (w *BaseWidget) Build(ctx context.Context) RenderObject {
 func (w *BaseWidget) Init() {
 	if w.initialized.CompareAndSwap(false, true) {
 		// Initialization logic
-		w.needsLayout.Set(true)
+		w.invalidateLayout()
 		w.needsRepaint.Set(true)
 	}
 }
@@ -189,7 +195,7 @@ func (w *BaseWidget) GetProps() Props {
 func (w *BaseWidget) SetProps(props Props) {
 	reactive.Batch(func() {
 		w.props.Set(props)
-		w.needsLayout.Set(true)
+		w.invalidateLayout()
 		w.needsRepaint.Set(true)
 	})
 }
@@ -289,7 +295,7 @@ func (w *BaseWidget) MarkNeedsRepaint() {
 
 // MarkNeedsLayout marks widget as needing layout
 func (w *BaseWidget) MarkNeedsLayout() {
-	w.needsLayout.Set(true)
+	w.invalidateLayout()
 	
 	// Propagate to parent
 	if parent := w.Parent(); parent != nil {
@@ -299,6 +305,27 @@ func (w *BaseWidget) MarkNeedsLayout() {
 	}
 }
 
+// Revision changes whenever the widget's layout may have changed, so
+// layout caches can tell a stale size from a reusable one
+func (w *BaseWidget) Revision() uint64 {
+	return w.revision.Load()
+}
+
+// AttachNode links the widget to the tree node that renders it
+func (w *BaseWidget) AttachNode(node *core.Node) {
+	w.node.Store(node)
+}
+
+// invalidateLayout flags the widget for layout, bumps its revision and
+// marks its node dirty
+func (w *BaseWidget) invalidateLayout() {
+	w.revision.Add(1)
+	w.needsLayout.Set(true)
+	if node := w.node.Load(); node != nil {
+		node.MarkDirty(core.LayoutDirty)
+	}
+}
+
 // HandleEvent processes input events
 func (w *BaseWidget) HandleEvent(event core.Event) bool {
 	// Default implementation - propagate to children
@@ -60,6 +60,21 @@ func TestText_SetText(t *testing.T) {
 	if !text.NeedsRepaint() {
 		t.Error("Changing text should trigger repaint")
 	}
+	
+	// Layout caches key on the revision, so new content must bump it
+	revision := text.Revision()
+	text.SetText("Updated again")
+	if text.Revision() == revision {
+		t.Error("Changing text should bump the layout revision")
+	}
+	
+	// The node rendering the text is dirtied without polling the widget
+	node := core.NewNode("test", text)
+	node.ClearDirty()
+	text.SetText("Attached")
+	if node.GetDirtyFlags()&core.LayoutDirty == 0 {
+		t.Error("Changing text should mark the attached node for layout")
+	}
 }
 
 func TestText_SetStyle(t *testing.T) {
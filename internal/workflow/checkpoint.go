@@ -0,0 +1,192 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrCheckpointNotFound is returned when a run has no saved checkpoint
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// CheckpointStore persists the outputs of completed stages per run so a
+// failed run can be resumed
+type CheckpointStore interface {
+	// Save records the encoded output of a completed stage
+	Save(runID, stageID string, data []byte) error
+
+	// Load returns the encoded outputs of every completed stage of a run,
+	// or ErrCheckpointNotFound if nothing was saved for it
+	Load(runID string) (map[string][]byte, error)
+
+	// Delete discards the checkpoint of a run
+	Delete(runID string) error
+}
+
+// OutputCodec converts stage outputs to and from bytes for a CheckpointStore
+type OutputCodec interface {
+	Encode(output interface{}) ([]byte, error)
+	Decode(data []byte) (interface{}, error)
+}
+
+// JSONOutputCodec is the default OutputCodec. Decoded outputs have
+// encoding/json's generic types, e.g. map[string]interface{} for structs;
+// use a custom codec to restore concrete types.
+type JSONOutputCodec struct{}
+
+// Encode marshals the output
+func (JSONOutputCodec) Encode(output interface{}) ([]byte, error) {
+	return json.Marshal(output)
+}
+
+// Decode unmarshals the output
+func (JSONOutputCodec) Decode(data []byte) (interface{}, error) {
+	var output interface{}
+	err := json.Unmarshal(data, &output)
+	return output, err
+}
+
+// MemoryCheckpointStore keeps checkpoints in memory, e.g. for tests or to
+// retry a run within the same process
+type MemoryCheckpointStore struct {
+	runs map[string]map[string][]byte
+	mu   sync.RWMutex
+}
+
+// NewMemoryCheckpointStore creates an empty in-memory store
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		runs: make(map[string]map[string][]byte),
+	}
+}
+
+// Save records a stage output
+func (s *MemoryCheckpointStore) Save(runID, stageID string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, ok := s.runs[runID]
+	if !ok {
+		run = make(map[string][]byte)
+		s.runs[runID] = run
+	}
+	run[stageID] = append([]byte(nil), data...)
+	return nil
+}
+
+// Load returns a copy of the outputs saved for a run
+func (s *MemoryCheckpointStore) Load(runID string) (map[string][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	run, ok := s.runs[runID]
+	if !ok {
+		return nil, fmt.Errorf("run %s: %w", runID, ErrCheckpointNotFound)
+	}
+
+	outputs := make(map[string][]byte, len(run))
+	for stageID, data := range run {
+		outputs[stageID] = append([]byte(nil), data...)
+	}
+	return outputs, nil
+}
+
+// Delete discards a run
+func (s *MemoryCheckpointStore) Delete(runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.runs, runID)
+	return nil
+}
+
+// checkpointExt is the file extension of saved stage outputs
+const checkpointExt = ".ckpt"
+
+// FileCheckpointStore keeps each run in a directory holding one file per
+// completed stage. Files are written atomically, so a crash never leaves a
+// partial output behind.
+type FileCheckpointStore struct {
+	dir string
+}
+
+// NewFileCheckpointStore creates a store rooted at dir, creating it if needed
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+// runDir returns the directory of a run; IDs are escaped so they cannot
+// escape the store
+func (s *FileCheckpointStore) runDir(runID string) string {
+	return filepath.Join(s.dir, url.PathEscape(runID))
+}
+
+// Save writes a stage output to a temporary file and renames it into place
+func (s *FileCheckpointStore) Save(runID, stageID string, data []byte) error {
+	dir := s.runDir(runID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, url.PathEscape(stageID)+checkpointExt))
+}
+
+// Load reads every stage output saved for a run
+func (s *FileCheckpointStore) Load(runID string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(s.runDir(runID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("run %s: %w", runID, ErrCheckpointNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, checkpointExt) {
+			continue
+		}
+		stageID, err := url.PathUnescape(strings.TrimSuffix(name, checkpointExt))
+		if err != nil {
+			return nil, fmt.Errorf("run %s: invalid checkpoint file %s", runID, name)
+		}
+		data, err := os.ReadFile(filepath.Join(s.runDir(runID), name))
+		if err != nil {
+			return nil, err
+		}
+		outputs[stageID] = data
+	}
+	return outputs, nil
+}
+
+// Delete removes the directory of a run
+func (s *FileCheckpointStore) Delete(runID string) error {
+	return os.RemoveAll(s.runDir(runID))
+}
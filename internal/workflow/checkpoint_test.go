@@ -0,0 +1,171 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCheckpointStores(t *testing.T) {
+	fileStore, err := NewFileCheckpointStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]CheckpointStore{
+		"memory": NewMemoryCheckpointStore(),
+		"file":   fileStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.Load("run/1"); !errors.Is(err, ErrCheckpointNotFound) {
+				t.Errorf("Expected ErrCheckpointNotFound, got %v", err)
+			}
+
+			store.Save("run/1", "fetch/../data", []byte(`"a"`))
+			store.Save("run/1", "parse", []byte(`1`))
+			store.Save("run/1", "parse", []byte(`2`))
+			store.Save("run/2", "other", []byte(`3`))
+
+			outputs, err := store.Load("run/1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(outputs) != 2 || string(outputs["fetch/../data"]) != `"a"` || string(outputs["parse"]) != `2` {
+				t.Errorf("Unexpected outputs %q", outputs)
+			}
+
+			if err := store.Delete("run/1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Load("run/1"); !errors.Is(err, ErrCheckpointNotFound) {
+				t.Errorf("Expected deleted run to be gone, got %v", err)
+			}
+			if _, err := store.Load("run/2"); err != nil {
+				t.Errorf("Deleting a run should keep the others, got %v", err)
+			}
+		})
+	}
+}
+
+func TestResumeFrom(t *testing.T) {
+	store, err := NewFileCheckpointStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	executed := map[string]int{}
+	broken := true
+	var received interface{}
+
+	count := func(output interface{}) StageFunc {
+		return func(ctx context.Context, sc *StageContext) error {
+			executed[sc.Stage.ID]++
+			sc.Output = output
+			return nil
+		}
+	}
+	engine := newTestEngine(t, []*Stage{
+		{ID: "extract", Execute: count(map[string]interface{}{"rows": 3})},
+		{ID: "transform", Execute: count("transformed")},
+		{ID: "load", Execute: func(ctx context.Context, sc *StageContext) error {
+			executed["load"]++
+			if broken {
+				return errors.New("database down")
+			}
+			received = sc.Dependencies["transform"]
+			return nil
+		}},
+	}, [][2]string{{"extract", "transform"}, {"transform", "load"}})
+	engine.SetCheckpointStore(store, nil)
+
+	if err := engine.Execute(context.Background(), nil); err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+	runID := engine.Report().RunID
+
+	// A fresh engine, as after a restart
+	broken = false
+	resumed := newTestEngine(t, []*Stage{
+		engine.stages["extract"], engine.stages["transform"], engine.stages["load"],
+	}, [][2]string{{"extract", "transform"}, {"transform", "load"}})
+	resumed.SetCheckpointStore(store, nil)
+
+	if err := resumed.ResumeFrom(context.Background(), runID, nil); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	if executed["extract"] != 1 || executed["transform"] != 1 || executed["load"] != 2 {
+		t.Errorf("Only the failed stage should run again, got %v", executed)
+	}
+	if received != "transformed" {
+		t.Errorf("Expected the stored output as dependency, got %v", received)
+	}
+	if output, _ := resumed.GetResult("extract"); output.(map[string]interface{})["rows"] != float64(3) {
+		t.Errorf("Unexpected decoded output %v", output)
+	}
+
+	report := resumed.Report()
+	if report.RunID != runID {
+		t.Errorf("Expected run ID %s, got %s", runID, report.RunID)
+	}
+	for id, resumed := range map[string]bool{"extract": true, "transform": true, "load": false} {
+		if entry, _ := report.Stage(id); entry.Resumed != resumed || entry.Status != StageSucceeded {
+			t.Errorf("%s: unexpected report entry %+v", id, entry)
+		}
+	}
+
+	if err := resumed.ResumeFrom(context.Background(), "unknown", nil); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("Expected ErrCheckpointNotFound, got %v", err)
+	}
+}
+
+func TestResumeFanOut(t *testing.T) {
+	store := NewMemoryCheckpointStore()
+	var mu sync.Mutex
+	executed := map[string]int{}
+	broken := true
+
+	fan := listFiles("a", "b", "c")
+	fan.Execute = func(ctx context.Context, sc *StageContext) error {
+		mu.Lock()
+		defer mu.Unlock()
+		executed[sc.Stage.ID]++
+		if sc.Stage.ID == "files/b" && broken {
+			return errors.New("unreadable")
+		}
+		sc.Output = strings.ToUpper(sc.Input.(string))
+		return nil
+	}
+	var joined []interface{}
+	engine := newTestEngine(t, []*Stage{fan, {
+		ID: "join",
+		Execute: func(ctx context.Context, sc *StageContext) error {
+			joined = sc.Dependencies["files"].(*FanOutResult).Outputs()
+			return nil
+		},
+	}}, [][2]string{{"files", "join"}})
+	engine.SetCheckpointStore(store, nil)
+
+	if err := engine.Execute(context.Background(), nil); err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+	broken = false
+	if err := engine.ResumeFrom(context.Background(), engine.Report().RunID, nil); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	// Children that succeeded are restored, the failed one runs again
+	if executed["files/a"] != 1 || executed["files/b"] != 2 || executed["files/c"] != 1 {
+		t.Errorf("Only the failed child should run again, got %v", executed)
+	}
+	if !slices.Equal(joined, []interface{}{"A", "B", "C"}) {
+		t.Errorf("Expected every child output in the join, got %v", joined)
+	}
+	if entry, _ := engine.Report().Stage("files/a"); !entry.Resumed || entry.Parent != "files" {
+		t.Errorf("Unexpected report entry %+v", entry)
+	}
+}
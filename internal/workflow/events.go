@@ -0,0 +1,83 @@
+package workflow
+
+import "time"
+
+// EventType identifies the kind of lifecycle change recorded in an Event
+type EventType int
+
+const (
+	EventRunStarted       EventType = iota // Execute, ResumeFrom or Start was called
+	EventStageStarted                      // A stage (or stream item) began its first attempt
+	EventAttemptFailed                     // An attempt returned an error
+	EventRetryScheduled                    // A failed attempt will be retried after Delay
+	EventStageCompleted                    // A stage finished; Err is set if it failed
+	EventRunFinished                       // The run ended; Err is set if it failed
+	EventStageCompensated                  // Compensate ran for a stage; Err is set if it failed
+)
+
+// String returns a readable name for the event type
+func (t EventType) String() string {
+	switch t {
+	case EventRunStarted:
+		return "run started"
+	case EventStageStarted:
+		return "stage started"
+	case EventAttemptFailed:
+		return "attempt failed"
+	case EventRetryScheduled:
+		return "retry scheduled"
+	case EventStageCompleted:
+		return "stage completed"
+	case EventRunFinished:
+		return "run finished"
+	case EventStageCompensated:
+		return "stage compensated"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a lifecycle notification from a WorkflowEngine, Pipeline or
+// Stream. Source is the name of the emitter; Stage is empty for run events.
+type Event struct {
+	Type   EventType
+	Source string
+	RunID  string // Identifies one Execute, ResumeFrom or Start
+	Stage  string
+	Parent string // Fan-out stage of a dynamic child stage
+	Time   time.Time
+
+	// Attempt is 1-based; for EventRetryScheduled it is the attempt about
+	// to be made after Delay
+	Attempt int
+	Delay   time.Duration
+
+	// Item is the 1-based sequence number of a Stream item
+	Item uint64
+
+	Err error
+
+	// Report is set on EventRunFinished from a WorkflowEngine
+	Report *RunReport
+}
+
+// Observe registers a callback for lifecycle events and returns a function
+// that unsubscribes it. Events are delivered synchronously from the
+// goroutine running the stage, so callbacks must be safe for concurrent use
+// and should return quickly, e.g. by setting a reactive signal.
+func (w *WorkflowEngine) Observe(callback func(Event)) (unsubscribe func()) {
+	return w.events.Add(callback)
+}
+
+// Observe registers a callback for lifecycle events, see
+// WorkflowEngine.Observe
+func (p *Pipeline) Observe(callback func(Event)) (unsubscribe func()) {
+	return p.events.Add(callback)
+}
+
+// Observe registers a callback for lifecycle events, see
+// WorkflowEngine.Observe. Each item is reported as a stage named after the
+// stream, numbered by Item.
+func (s *TypedStream[In, Out]) Observe(callback func(Event)) (unsubscribe func()) {
+	return s.events.Add(callback)
+}
@@ -0,0 +1,175 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/maya-framework/maya/internal/reactive"
+)
+
+// eventLog records events from concurrent emitters
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (l *eventLog) record(e Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, e)
+}
+
+// trace renders the events as "type stage#attempt" lines
+func (l *eventLog) trace() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lines := make([]string, len(l.events))
+	for i, e := range l.events {
+		lines[i] = strings.TrimSpace(fmt.Sprintf("%s %s", e.Type, e.Stage))
+		if e.Stage != "" {
+			lines[i] += fmt.Sprintf("#%d", e.Attempt)
+		}
+	}
+	return lines
+}
+
+func TestWorkflowEvents(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	engine := NewWorkflowEngine("events")
+	engine.SetClock(clock)
+
+	failures := 1
+	engine.AddStage(&Stage{ID: "fetch", MaxRetries: 2, RetryPolicy: ConstantBackoff(time.Second),
+		Execute: func(ctx context.Context, sc *StageContext) error {
+			if failures > 0 {
+				failures--
+				return errors.New("timeout")
+			}
+			return nil
+		}})
+	engine.AddStage(&Stage{ID: "store", Execute: func(ctx context.Context, sc *StageContext) error { return nil }})
+	engine.AddDependency("fetch", "store")
+
+	log := &eventLog{}
+	unsubscribe := engine.Observe(log.record)
+
+	// Progress UIs can mirror events into a signal
+	completed := reactive.NewSignal(0)
+	defer engine.Observe(func(e Event) {
+		if e.Type == EventStageCompleted && e.Err == nil {
+			completed.Update(func(n int) int { return n + 1 })
+		}
+	})()
+
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"run started",
+		"stage started fetch#1",
+		"attempt failed fetch#1",
+		"retry scheduled fetch#2",
+		"stage completed fetch#2",
+		"stage started store#1",
+		"stage completed store#1",
+		"run finished",
+	}
+	if trace := log.trace(); !slices.Equal(trace, expected) {
+		t.Errorf("Expected events\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(trace, "\n"))
+	}
+
+	retry := log.events[3]
+	if retry.Delay != time.Second || retry.Err == nil || !retry.Time.Equal(time.Unix(0, 0)) {
+		t.Errorf("Unexpected retry event %+v", retry)
+	}
+	finished := log.events[len(log.events)-1]
+	if finished.Report == nil || finished.RunID == "" || finished.RunID != finished.Report.RunID {
+		t.Errorf("Run finished should carry the report, got %+v", finished)
+	}
+	if completed.Peek() != 2 {
+		t.Errorf("Expected 2 completed stages in the signal, got %d", completed.Peek())
+	}
+
+	unsubscribe()
+	engine.Execute(context.Background(), nil)
+	if len(log.events) != len(expected) {
+		t.Errorf("Unsubscribed observer should not receive events, got %d", len(log.events))
+	}
+}
+
+func TestPipelineAndStreamEvents(t *testing.T) {
+	boom := errors.New("boom")
+
+	pipeline := NewPipeline("pipe", 1)
+	pipeline.AddStage(&PipelineStage{Name: "double", Process: func(ctx context.Context, v interface{}) (interface{}, error) {
+		return v.(int) * 2, nil
+	}})
+	pipeline.AddStage(&PipelineStage{Name: "fail", Process: func(ctx context.Context, v interface{}) (interface{}, error) {
+		return nil, boom
+	}})
+
+	log := &eventLog{}
+	pipeline.Observe(log.record)
+	pipeline.Execute(context.Background(), 1)
+
+	expected := []string{
+		"run started",
+		"stage started double#1",
+		"stage completed double#1",
+		"stage started fail#1",
+		"attempt failed fail#1",
+		"stage completed fail#1",
+		"run finished",
+	}
+	if trace := log.trace(); !slices.Equal(trace, expected) {
+		t.Errorf("Expected pipeline events %v, got %v", expected, trace)
+	}
+	if last := log.events[len(log.events)-1]; !errors.Is(last.Err, boom) {
+		t.Errorf("Run finished should carry the error, got %v", last.Err)
+	}
+
+	stream := NewStream("stream", func(ctx context.Context, v interface{}) (interface{}, error) {
+		return v, nil
+	})
+	stream.SetWorkers(3)
+	log = &eventLog{}
+	stream.Observe(log.record)
+
+	items := func(yield func(interface{}) bool) {
+		for i := 0; i < 5; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	for range stream.Process(context.Background(), items) {
+	}
+
+	counts := map[EventType]int{}
+	seen := map[uint64]bool{}
+	for _, e := range log.events {
+		counts[e.Type]++
+		if e.Type == EventStageCompleted {
+			seen[e.Item] = true
+		}
+	}
+	if counts[EventRunStarted] != 1 || counts[EventStageStarted] != 5 || counts[EventStageCompleted] != 5 || counts[EventRunFinished] != 1 {
+		t.Errorf("Unexpected stream event counts %v", counts)
+	}
+	for i := uint64(1); i <= 5; i++ {
+		if !seen[i] {
+			t.Errorf("Missing completion of item %d", i)
+		}
+	}
+	if log.events[len(log.events)-1].Type != EventRunFinished {
+		t.Error("Run finished should be the last stream event")
+	}
+}
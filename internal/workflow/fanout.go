@@ -0,0 +1,177 @@
+package workflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/maya-framework/maya/internal/graph"
+)
+
+// ChildTask is one unit of work returned by a FanOut stage
+type ChildTask struct {
+	// ID names the child within its stage; the child runs as the dynamic
+	// stage "<stage>/<ID>". Defaults to the task's index.
+	ID string
+
+	// Input is handed to the child as StageContext.Input
+	Input interface{}
+
+	// Execute runs the child; nil uses the fan-out stage's Execute
+	Execute StageFunc
+}
+
+// ChildResult is the outcome of one child of a fan-out stage
+type ChildResult struct {
+	ID     string
+	Output interface{}
+	Err    error
+}
+
+// FanOutResult is what the dependents of a fan-out stage receive for it
+// in StageContext.Dependencies, once every child has finished
+type FanOutResult struct {
+	// Output is the Output set by the FanOut function
+	Output interface{}
+
+	// Children in task order
+	Children []ChildResult
+}
+
+// Outputs returns the outputs of the children that succeeded, in task
+// order
+func (f *FanOutResult) Outputs() []interface{} {
+	var outputs []interface{}
+	for _, child := range f.Children {
+		if child.Err == nil {
+			outputs = append(outputs, child.Output)
+		}
+	}
+	return outputs
+}
+
+// Failed returns the children that failed
+func (f *FanOutResult) Failed() []ChildResult {
+	var failed []ChildResult
+	for _, child := range f.Children {
+		if child.Err != nil {
+			failed = append(failed, child)
+		}
+	}
+	return failed
+}
+
+// expand turns the tasks returned by a fan-out stage into dynamic child
+// stages, returned as a graph.Expansion for the scheduler to insert
+// between the stage and its dependents. The run learns about the children
+// once they are inserted.
+func (r *Run) expand(stage *Stage, tasks []ChildTask) error {
+	label := stage.Name
+	if label == "" {
+		label = stage.ID
+	}
+
+	ids := make([]string, len(tasks))
+	inputs := make(map[string]interface{}, len(tasks))
+	children := make([]graph.ExpandedNode[string, *Stage], len(tasks))
+	for i, task := range tasks {
+		taskID := task.ID
+		if taskID == "" {
+			taskID = strconv.Itoa(i)
+		}
+		id := stage.ID + "/" + taskID
+		if _, dup := inputs[id]; dup {
+			return fmt.Errorf("duplicate child task %q", taskID)
+		}
+
+		// Children inherit the timeout, retries and resources of the stage
+		child := *stage
+		child.ID = id
+		child.Name = fmt.Sprintf("%s [%s]", label, taskID)
+		child.FanOut = nil
+		child.MaxChildFailures = 0
+		child.When = nil
+		child.AlwaysRun = false
+		child.Compensate = nil
+		if task.Execute != nil {
+			child.Execute = task.Execute
+		}
+		if child.Execute == nil {
+			return fmt.Errorf("child task %q has no Execute function", taskID)
+		}
+
+		ids[i] = id
+		inputs[id] = task.Input
+		children[i] = graph.ExpandedNode[string, *Stage]{ID: id, Data: &child}
+	}
+
+	// Children only become part of the run once the scheduler inserted
+	// them; a rejected child, e.g. one named like an existing stage, must
+	// not be mistaken for it
+	record := func() {
+		r.dynMu.Lock()
+		r.fanOuts[stage.ID] = ids
+		for _, id := range ids {
+			r.parents[id] = stage.ID
+			r.childInputs[id] = inputs[id]
+		}
+		r.dynMu.Unlock()
+
+		r.metrics.mu.Lock()
+		r.metrics.TotalStages += len(children)
+		r.metrics.mu.Unlock()
+	}
+
+	if len(children) == 0 {
+		record()
+		return nil
+	}
+	return &graph.Expansion[string, *Stage]{Children: children, Inserted: record}
+}
+
+// parentOf returns the fan-out stage of a dynamic child
+func (r *Run) parentOf(stageID string) (string, bool) {
+	r.dynMu.Lock()
+	defer r.dynMu.Unlock()
+	parent, ok := r.parents[stageID]
+	return parent, ok
+}
+
+// childInput returns the task input of a dynamic child
+func (r *Run) childInput(stageID string) (interface{}, bool) {
+	r.dynMu.Lock()
+	defer r.dynMu.Unlock()
+	input, ok := r.childInputs[stageID]
+	return input, ok
+}
+
+// tolerateFailure counts a failed child against its stage's
+// MaxChildFailures, reporting whether the failure is within the limit
+func (r *Run) tolerateFailure(parentID string) bool {
+	parent := r.stage(parentID)
+
+	r.dynMu.Lock()
+	defer r.dynMu.Unlock()
+
+	r.childFailures[parentID]++
+	return parent.MaxChildFailures < 0 || r.childFailures[parentID] <= parent.MaxChildFailures
+}
+
+// fanOutResult collects the outcomes of a fan-out stage's children
+func (r *Run) fanOutResult(stageID string, output interface{}) (*FanOutResult, bool) {
+	r.dynMu.Lock()
+	ids, ok := r.fanOuts[stageID]
+	r.dynMu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	result := &FanOutResult{Output: output}
+	for _, id := range ids {
+		child := ChildResult{ID: strings.TrimPrefix(id, stageID+"/")}
+		child.Output, _ = r.results.Load(id)
+		child.Err, _ = r.GetError(id)
+		result.Children = append(result.Children, child)
+	}
+	return result, true
+}
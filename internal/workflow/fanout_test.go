@@ -0,0 +1,215 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// listFiles fans out into one child per file, each returning the file
+// name in upper case
+func listFiles(files ...string) *Stage {
+	return &Stage{
+		ID: "files",
+		FanOut: func(ctx context.Context, sc *StageContext) ([]ChildTask, error) {
+			sc.Output = len(files)
+			tasks := make([]ChildTask, len(files))
+			for i, file := range files {
+				tasks[i] = ChildTask{ID: file, Input: file}
+			}
+			return tasks, nil
+		},
+		Execute: func(ctx context.Context, sc *StageContext) error {
+			sc.Output = strings.ToUpper(sc.Input.(string))
+			return nil
+		},
+	}
+}
+
+func TestFanOutAndJoin(t *testing.T) {
+	var joined *FanOutResult
+	engine := newTestEngine(t, []*Stage{
+		listFiles("a", "b", "c"),
+		{
+			ID: "join",
+			Execute: func(ctx context.Context, sc *StageContext) error {
+				joined = sc.Dependencies["files"].(*FanOutResult)
+				if len(sc.Dependencies) != 1 {
+					return fmt.Errorf("children should be reported through their stage, got %v", sc.Dependencies)
+				}
+				return nil
+			},
+		},
+	}, [][2]string{{"files", "join"}})
+
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if joined == nil || joined.Output != 3 {
+		t.Fatalf("Unexpected join input %+v", joined)
+	}
+	if outputs := joined.Outputs(); !slices.Equal(outputs, []interface{}{"A", "B", "C"}) {
+		t.Errorf("Expected child outputs in task order, got %v", outputs)
+	}
+
+	// Dynamic children show up in metrics and the report
+	metrics := engine.GetMetrics()
+	if metrics.TotalStages != 5 || metrics.CompletedStages != 5 {
+		t.Errorf("Expected 5 stages including children, got %d total and %d completed", metrics.TotalStages, metrics.CompletedStages)
+	}
+	if sm := metrics.StageMetrics["files/b"]; sm == nil || sm.Parent != "files" || !sm.Success {
+		t.Errorf("Unexpected child metrics %+v", sm)
+	}
+	report := engine.Report()
+	ids := make([]string, len(report.Stages))
+	for i, entry := range report.Stages {
+		ids[i] = entry.ID
+	}
+	if len(ids) != 5 || ids[0] != "files" || ids[4] != "join" {
+		t.Errorf("Expected children between the stage and its join, got %v", ids)
+	}
+	if entry, _ := report.Stage("files/a"); entry.Parent != "files" || entry.Name != "files [a]" {
+		t.Errorf("Unexpected child report %+v", entry)
+	}
+
+	// Runs do not see each other's children
+	def, _ := engine.Compile()
+	run, _ := def.Execute(context.Background(), nil)
+	if _, ok := def.GetStage("files/a"); ok || run.GetMetrics().TotalStages != 5 {
+		t.Error("Children belong to the run, not the definition")
+	}
+}
+
+func TestFanOutChildRetriesAndPartialFailure(t *testing.T) {
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+
+	fan := listFiles("ok", "flaky", "broken")
+	fan.MaxRetries = 2
+	fan.RetryPolicy = ConstantBackoff(time.Millisecond)
+	fan.MaxChildFailures = 1
+	fan.Execute = func(ctx context.Context, sc *StageContext) error {
+		file := sc.Input.(string)
+		mu.Lock()
+		attempts[file]++
+		n := attempts[file]
+		mu.Unlock()
+
+		switch {
+		case file == "broken":
+			return errors.New("corrupt")
+		case file == "flaky" && n < 3:
+			return errors.New("timeout")
+		}
+		sc.Output = file
+		return nil
+	}
+
+	var joined *FanOutResult
+	engine := newTestEngine(t, []*Stage{
+		fan,
+		{
+			ID: "join",
+			Execute: func(ctx context.Context, sc *StageContext) error {
+				joined = sc.Dependencies["files"].(*FanOutResult)
+				return nil
+			},
+		},
+	}, [][2]string{{"files", "join"}})
+
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatalf("A tolerated child failure should not fail the run: %v", err)
+	}
+
+	if attempts["flaky"] != 3 || attempts["broken"] != 3 {
+		t.Errorf("Each child should be retried on its own, got %v", attempts)
+	}
+	if joined == nil || !slices.Equal(joined.Outputs(), []interface{}{"ok", "flaky"}) {
+		t.Fatalf("Join should get the successful outputs, got %+v", joined)
+	}
+	if failed := joined.Failed(); len(failed) != 1 || failed[0].ID != "broken" || failed[0].Err.Error() != "corrupt" {
+		t.Errorf("Unexpected failed children %+v", failed)
+	}
+	if sm := engine.GetMetrics().StageMetrics["files/flaky"]; sm.RetryCount != 2 {
+		t.Errorf("Expected the child's retries in its metrics, got %d", sm.RetryCount)
+	}
+	assertStatuses(t, engine.Report(), map[string]StageStatus{
+		"files":        StageSucceeded,
+		"files/ok":     StageSucceeded,
+		"files/broken": StageFailed,
+		"join":         StageSucceeded,
+	})
+
+	// Beyond MaxChildFailures the stage's failure policy applies
+	fan.MaxChildFailures = 0
+	joined = nil
+	attempts = make(map[string]int)
+	if err := engine.Execute(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "files/broken") {
+		t.Errorf("Expected the child failure, got %v", err)
+	}
+	if joined != nil {
+		t.Error("Join should not run after an intolerable failure")
+	}
+}
+
+func TestFanOutErrors(t *testing.T) {
+	fan := listFiles("a", "a")
+	engine := newTestEngine(t, []*Stage{fan}, nil)
+	if err := engine.Execute(context.Background(), nil); err == nil || !strings.Contains(err.Error(), `duplicate child task "a"`) {
+		t.Errorf("Expected a duplicate task error, got %v", err)
+	}
+
+	// Without a stage Execute every task needs its own
+	fan = listFiles("x")
+	fan.Execute = nil
+	engine = NewWorkflowEngine("test")
+	engine.AddStage(fan)
+	if err := engine.Execute(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "no Execute function") {
+		t.Errorf("Expected a missing Execute error, got %v", err)
+	}
+
+	// No tasks: the join still gets an (empty) result
+	var joined interface{}
+	engine = newTestEngine(t, []*Stage{
+		listFiles(),
+		{ID: "join", Execute: func(ctx context.Context, sc *StageContext) error {
+			joined = sc.Dependencies["files"]
+			return nil
+		}},
+	}, [][2]string{{"files", "join"}})
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if result, ok := joined.(*FanOutResult); !ok || len(result.Children) != 0 {
+		t.Errorf("Expected an empty fan-out result, got %#v", joined)
+	}
+
+	// A child named like a real stage fails the fan-out and leaves the
+	// stage alone
+	engine = newTestEngine(t, []*Stage{
+		listFiles("x", "join"),
+		{ID: "files/join", Execute: func(ctx context.Context, sc *StageContext) error { return nil }},
+		{ID: "join", Execute: func(ctx context.Context, sc *StageContext) error { return nil }},
+	}, [][2]string{{"files", "join"}})
+	err := engine.Execute(context.Background(), nil)
+	report := engine.Report()
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected the colliding child in the error, got %v", err)
+	}
+	if stage, ok := report.Stage("files/join"); !ok || stage.Status != StageSucceeded || stage.Parent != "" {
+		t.Errorf("Expected files/join to run as a regular stage, got %+v", stage)
+	}
+	if metrics := engine.GetMetrics(); metrics.TotalStages != 3 {
+		t.Errorf("Rejected children should not be counted, got %d stages", metrics.TotalStages)
+	}
+
+	if label := describeStage(fan); !strings.Contains(label, "fan-out") {
+		t.Errorf("Describe should mark fan-out stages, got %q", label)
+	}
+}
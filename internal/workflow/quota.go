@@ -0,0 +1,239 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// WaitStats summarises the time spent waiting for a pool or rate limiter
+type WaitStats struct {
+	Acquired  uint64 // Successful acquisitions
+	Waited    uint64 // Acquisitions that had to wait
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// record adds an acquisition that waited d
+func (s *WaitStats) record(d time.Duration) {
+	s.Acquired++
+	if d > 0 {
+		s.Waited++
+		s.TotalWait += d
+		s.MaxWait = max(s.MaxWait, d)
+	}
+}
+
+// resourcePool is a named counting semaphore
+type resourcePool struct {
+	slots chan struct{}
+	stats WaitStats
+}
+
+// Quotas holds named resource pools, e.g. "network" with 4 slots or "cpu"
+// with runtime.NumCPU(), shared by every WorkflowEngine, Pipeline and
+// Stream it is given to. Stages declare the pools they need and hold one
+// slot of each while they run.
+type Quotas struct {
+	pools map[string]*resourcePool
+	mu    sync.Mutex
+}
+
+// NewQuotas creates an empty set of pools
+func NewQuotas() *Quotas {
+	return &Quotas{
+		pools: make(map[string]*resourcePool),
+	}
+}
+
+// SetPool creates or replaces the pool name with capacity slots. Holders
+// of slots from a replaced pool release them to the old pool.
+func (q *Quotas) SetPool(name string, capacity int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pools[name] = &resourcePool{slots: make(chan struct{}, capacity)}
+}
+
+// InUse returns the number of slots held in a pool
+func (q *Quotas) InUse(name string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if pool, ok := q.pools[name]; ok {
+		return len(pool.slots)
+	}
+	return 0
+}
+
+// Stats returns the wait statistics of every pool
+func (q *Quotas) Stats() map[string]WaitStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := make(map[string]WaitStats, len(q.pools))
+	for name, pool := range q.pools {
+		stats[name] = pool.stats
+	}
+	return stats
+}
+
+// Acquire takes one slot from each named pool, waiting as needed, and
+// returns a function releasing them all along with the time spent waiting.
+// Pools are taken in name order so concurrent callers cannot deadlock.
+func (q *Quotas) Acquire(ctx context.Context, names ...string) (release func(), waited time.Duration, err error) {
+	names = slices.Clone(names)
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	q.mu.Lock()
+	pools := make([]*resourcePool, len(names))
+	for i, name := range names {
+		pool, ok := q.pools[name]
+		if !ok {
+			q.mu.Unlock()
+			return nil, 0, fmt.Errorf("unknown resource pool %q", name)
+		}
+		pools[i] = pool
+	}
+	q.mu.Unlock()
+
+	held := 0
+	release = func() {
+		for _, pool := range pools[:held] {
+			<-pool.slots
+		}
+	}
+
+	for _, pool := range pools {
+		// Only a blocked acquisition counts as waiting
+		var wait time.Duration
+		select {
+		case pool.slots <- struct{}{}:
+		default:
+			start := time.Now()
+			select {
+			case pool.slots <- struct{}{}:
+			case <-ctx.Done():
+				release()
+				return nil, waited, ctx.Err()
+			}
+			wait = time.Since(start)
+		}
+		held++
+
+		waited += wait
+		q.mu.Lock()
+		pool.stats.record(wait)
+		q.mu.Unlock()
+	}
+
+	return release, waited, nil
+}
+
+// RateLimiter is a token bucket allowing Rate acquisitions per second on
+// average, with bursts of up to Burst. A limiter may be shared by several
+// stages to give them a common budget. A rate of zero or less means no
+// limit.
+type RateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	clock  Clock
+	stats  WaitStats
+	mu     sync.Mutex
+}
+
+// NewRateLimiter creates a full bucket allowing rate acquisitions per
+// second with bursts of burst; a rate of zero or less never waits
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(max(burst, 1)),
+		tokens: float64(max(burst, 1)),
+		clock:  realClock{},
+	}
+}
+
+// SetClock replaces the clock used to refill the bucket and wait
+func (l *RateLimiter) SetClock(clock Clock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clock = clock
+	l.last = time.Time{}
+}
+
+// Stats returns the wait statistics of the limiter
+func (l *RateLimiter) Stats() WaitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// Wait takes a token, waiting for the bucket to refill if it is empty,
+// and returns the time spent waiting
+func (l *RateLimiter) Wait(ctx context.Context) (time.Duration, error) {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.stats.record(0)
+		l.mu.Unlock()
+		return 0, nil
+	}
+
+	now := l.clock.Now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+
+	// Reserve the token; a negative balance queues callers behind each other
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	clock := l.clock
+	l.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-clock.After(delay):
+		case <-ctx.Done():
+			// Give the reservation back
+			l.mu.Lock()
+			l.tokens++
+			l.mu.Unlock()
+			return 0, ctx.Err()
+		}
+	}
+
+	l.mu.Lock()
+	l.stats.record(delay)
+	l.mu.Unlock()
+	return delay, nil
+}
+
+// acquireQuota waits for the rate limiter, then the named pools. Either
+// may be absent; pools without Quotas are an error.
+func acquireQuota(ctx context.Context, quotas *Quotas, resources []string, limiter *RateLimiter) (func(), time.Duration, error) {
+	var waited time.Duration
+	if limiter != nil {
+		wait, err := limiter.Wait(ctx)
+		if err != nil {
+			return nil, wait, err
+		}
+		waited += wait
+	}
+
+	if len(resources) == 0 {
+		return func() {}, waited, nil
+	}
+	if quotas == nil {
+		return nil, waited, fmt.Errorf("resources %v declared without quotas", resources)
+	}
+
+	release, wait, err := quotas.Acquire(ctx, resources...)
+	return release, waited + wait, err
+}
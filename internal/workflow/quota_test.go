@@ -0,0 +1,225 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// peakTracker records the highest number of concurrent holders
+type peakTracker struct {
+	current, peak atomic.Int32
+}
+
+func (p *peakTracker) enter() func() {
+	n := p.current.Add(1)
+	for {
+		old := p.peak.Load()
+		if n <= old || p.peak.CompareAndSwap(old, n) {
+			break
+		}
+	}
+	return func() { p.current.Add(-1) }
+}
+
+func TestEngineResourcePools(t *testing.T) {
+	quotas := NewQuotas()
+	quotas.SetPool("network", 2)
+
+	// Free slots are taken without waiting
+	for i := 0; i < 2; i++ {
+		release, waited, err := quotas.Acquire(context.Background(), "network")
+		if err != nil || waited != 0 {
+			t.Fatalf("Uncontended acquire waited %v: %v", waited, err)
+		}
+		release()
+	}
+	if stats := quotas.Stats()["network"]; stats.Acquired != 2 || stats.Waited != 0 || stats.TotalWait != 0 {
+		t.Errorf("Uncontended acquires should not count as waits, got %+v", stats)
+	}
+
+	// A fresh pool for the contended run below
+	quotas.SetPool("network", 2)
+
+	var network peakTracker
+	var stages []*Stage
+	for i := 0; i < 6; i++ {
+		stages = append(stages, &Stage{
+			ID:        fmt.Sprintf("fetch-%d", i),
+			Resources: []string{"network"},
+			Execute: func(ctx context.Context, sc *StageContext) error {
+				defer network.enter()()
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			},
+		})
+	}
+	engine := newTestEngine(t, stages, nil)
+	engine.SetQuotas(quotas)
+
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if network.peak.Load() != 2 {
+		t.Errorf("Expected 2 stages holding the network pool at once, got %d", network.peak.Load())
+	}
+
+	stats := quotas.Stats()["network"]
+	if stats.Acquired != 6 || stats.Waited == 0 || stats.TotalWait == 0 {
+		t.Errorf("Unexpected pool stats %+v", stats)
+	}
+	var waited time.Duration
+	for _, sm := range engine.GetMetrics().StageMetrics {
+		waited += sm.QuotaWait
+	}
+	if waited == 0 {
+		t.Error("Stage metrics should record the quota wait")
+	}
+	if quotas.InUse("network") != 0 {
+		t.Error("Slots should be released after the run")
+	}
+	if label := describeStage(stages[0]); !strings.Contains(label, "uses network") {
+		t.Errorf("Describe should list the pools, got %q", label)
+	}
+
+	// Undeclared pools fail the stage instead of running it unbounded
+	engine = newTestEngine(t, []*Stage{{ID: "gpu", Resources: []string{"gpu"}}}, nil)
+	if err := engine.Execute(context.Background(), nil); err == nil {
+		t.Error("Expected an error for resources without quotas")
+	}
+	engine.SetQuotas(quotas)
+	if err := engine.Execute(context.Background(), nil); err == nil || !strings.Contains(err.Error(), `unknown resource pool "gpu"`) {
+		t.Errorf("Expected an unknown pool error, got %v", err)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := NewRateLimiter(2, 1)
+	limiter.SetClock(clock)
+
+	engine := NewWorkflowEngine("limited")
+	engine.SetClock(clock)
+	for i := 0; i < 3; i++ {
+		engine.AddStage(&Stage{
+			ID:        fmt.Sprintf("call-%d", i),
+			RateLimit: limiter,
+			Execute:   func(ctx context.Context, sc *StageContext) error { return nil },
+		})
+		if i > 0 {
+			engine.AddDependency(fmt.Sprintf("call-%d", i-1), fmt.Sprintf("call-%d", i))
+		}
+	}
+
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A burst of one, then one call every 500ms
+	expected := []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}
+	if !slices.Equal(clock.waits, expected) {
+		t.Errorf("Expected waits %v, got %v", expected, clock.waits)
+	}
+	stats := limiter.Stats()
+	if stats.Acquired != 3 || stats.Waited != 2 || stats.MaxWait != 500*time.Millisecond {
+		t.Errorf("Unexpected limiter stats %+v", stats)
+	}
+	if sm := engine.GetMetrics().StageMetrics["call-2"]; sm.QuotaWait != 500*time.Millisecond {
+		t.Errorf("Expected a 500ms quota wait, got %v", sm.QuotaWait)
+	}
+
+	// The bucket refills over time, up to the burst
+	clock.now = clock.now.Add(time.Hour)
+	clock.waits = nil
+	limiter.Wait(context.Background())
+	if len(clock.waits) != 0 {
+		t.Errorf("A refilled bucket should not wait, got %v", clock.waits)
+	}
+
+	// Without a positive rate there is no limit
+	for _, rate := range []float64{0, -1} {
+		unlimited := NewRateLimiter(rate, 1)
+		unlimited.SetClock(clock)
+		for i := 0; i < 5; i++ {
+			if delay, err := unlimited.Wait(context.Background()); err != nil || delay != 0 {
+				t.Fatalf("Rate %v: expected no wait, got %v (%v)", rate, delay, err)
+			}
+		}
+		if stats := unlimited.Stats(); stats.Acquired != 5 || stats.Waited != 0 {
+			t.Errorf("Rate %v: unexpected stats %+v", rate, stats)
+		}
+	}
+	if len(clock.waits) != 0 {
+		t.Errorf("An unlimited limiter should not wait, got %v", clock.waits)
+	}
+}
+
+func TestQuotasSharedAcrossPipelineAndStream(t *testing.T) {
+	quotas := NewQuotas()
+	quotas.SetPool("db", 1)
+
+	var db peakTracker
+	query := func(ctx context.Context, v interface{}) (interface{}, error) {
+		defer db.enter()()
+		time.Sleep(2 * time.Millisecond)
+		return v, nil
+	}
+
+	pipeline := NewPipeline("pipeline", 4)
+	pipeline.SetQuotas(quotas)
+	pipeline.AddStage(&PipelineStage{Name: "query", Process: query, Parallel: true, Workers: 4, Resources: []string{"db"}})
+
+	stream := NewStream("stream", query)
+	stream.SetWorkers(4)
+	stream.SetQuotas(quotas, "db")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range stream.Process(context.Background(), countTo(8)) {
+		}
+	}()
+	if _, err := pipeline.Execute(context.Background(), []interface{}{1, 2, 3, 4, 5, 6, 7, 8}); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if db.peak.Load() != 1 {
+		t.Errorf("Expected the db pool to serialise both, got %d at once", db.peak.Load())
+	}
+	if stats := quotas.Stats()["db"]; stats.Acquired != 16 {
+		t.Errorf("Expected 16 acquisitions, got %+v", stats)
+	}
+}
+
+func TestStageForEach(t *testing.T) {
+	var workers peakTracker
+	var sum atomic.Int32
+	engine := newTestEngine(t, []*Stage{{
+		ID:         "fan",
+		Parallel:   true,
+		MaxWorkers: 3,
+		Execute: func(ctx context.Context, sc *StageContext) error {
+			return sc.ForEach(ctx, 10, func(ctx context.Context, i int) error {
+				defer workers.enter()()
+				time.Sleep(2 * time.Millisecond)
+				sum.Add(int32(i))
+				return nil
+			})
+		},
+	}}, nil)
+
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if sum.Load() != 45 {
+		t.Errorf("Expected every index once, got sum %d", sum.Load())
+	}
+	if workers.peak.Load() != 3 {
+		t.Errorf("Expected MaxWorkers to bound the fan-out at 3, got %d", workers.peak.Load())
+	}
+}
@@ -0,0 +1,256 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/maya-framework/maya/internal/graph"
+)
+
+// FailurePolicy decides what a stage failure does to the rest of the run
+type FailurePolicy int
+
+const (
+	// FailFast stops the run: running stages are cancelled and no new
+	// ones start except AlwaysRun stages
+	FailFast FailurePolicy = iota
+
+	// FailContinue skips the stage's dependents but lets unrelated
+	// stages carry on
+	FailContinue
+
+	// FailCompensate stops the run like FailFast, then calls Compensate
+	// on every stage that had succeeded, dependents before their
+	// dependencies
+	FailCompensate
+)
+
+// StageStatus is the final state of a stage in a RunReport
+type StageStatus int
+
+const (
+	StageSucceeded StageStatus = iota
+	StageFailed
+
+	// StageSkipped means the stage's When predicate returned false, or a
+	// dependency failed or was skipped
+	StageSkipped
+
+	// StageCancelled means the stage did not start, or was interrupted,
+	// because the run was stopped
+	StageCancelled
+)
+
+// String returns a readable name for the status
+func (s StageStatus) String() string {
+	switch s {
+	case StageSucceeded:
+		return "succeeded"
+	case StageFailed:
+		return "failed"
+	case StageSkipped:
+		return "skipped"
+	case StageCancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+// StageReport describes how a stage fared in a run
+type StageReport struct {
+	ID       string
+	Name     string
+	Status   StageStatus
+	Err      error
+	Attempts int
+	Duration time.Duration
+
+	// Resumed is set when the stage's output was restored from a
+	// checkpoint instead of being executed
+	Resumed bool
+
+	// Parent is the fan-out stage of a dynamic child
+	Parent string
+
+	// Compensated is set when Compensate ran for the stage
+	Compensated     bool
+	CompensationErr error
+}
+
+// RunReport summarises a workflow run
+type RunReport struct {
+	Workflow  string
+	RunID     string
+	StartTime time.Time
+	EndTime   time.Time
+
+	// Stages in topological order
+	Stages []StageReport
+
+	// Err is the error returned by Execute
+	Err error
+
+	// Compensated lists the stages whose Compensate ran, in the order
+	// they ran
+	Compensated []string
+
+	// CompensationErr joins the errors returned by Compensate
+	CompensationErr error
+}
+
+// Stage returns the report of a stage
+func (r *RunReport) Stage(id string) (StageReport, bool) {
+	for _, stage := range r.Stages {
+		if stage.ID == id {
+			return stage, true
+		}
+	}
+	return StageReport{}, false
+}
+
+// Count returns how many stages ended with the given status
+func (r *RunReport) Count(status StageStatus) int {
+	count := 0
+	for _, stage := range r.Stages {
+		if stage.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Succeeded reports whether no stage failed or was cancelled
+func (r *RunReport) Succeeded() bool {
+	return r.Count(StageFailed) == 0 && r.Count(StageCancelled) == 0
+}
+
+// stageStatus maps a scheduler outcome to a stage status
+func stageStatus(status graph.NodeStatus) StageStatus {
+	switch status {
+	case graph.NodeSucceeded:
+		return StageSucceeded
+	case graph.NodeFailed:
+		return StageFailed
+	case graph.NodeSkipped:
+		return StageSkipped
+	default:
+		return StageCancelled
+	}
+}
+
+// buildReport assembles the report of a run from the scheduler outcomes
+// and the recorded stage metrics
+func (r *Run) buildReport(outcomes map[string]graph.NodeOutcome, err error) *RunReport {
+	r.metrics.mu.RLock()
+	defer r.metrics.mu.RUnlock()
+
+	report := &RunReport{
+		Workflow:  r.def.name,
+		RunID:     r.id,
+		StartTime: r.metrics.StartTime,
+		EndTime:   r.metrics.EndTime,
+		Err:       err,
+	}
+
+	order, sortErr := r.graph.TopologicalSort()
+	if sortErr != nil {
+		return report
+	}
+
+	for _, id := range order {
+		stage := r.stage(id)
+		outcome := outcomes[id]
+
+		entry := StageReport{
+			ID:     id,
+			Name:   stage.Name,
+			Status: stageStatus(outcome.Status),
+			Err:    outcome.Err,
+		}
+		entry.Parent, _ = r.parentOf(id)
+		if r.resumed[id] {
+			entry.Resumed = true
+			report.Stages = append(report.Stages, entry)
+			continue
+		}
+		if sm, ok := r.metrics.StageMetrics[id]; ok && (outcome.Status == graph.NodeSucceeded || outcome.Status == graph.NodeFailed) {
+			entry.Attempts = sm.RetryCount + 1
+			entry.Duration = sm.Duration
+			if sm.Error != nil {
+				entry.Err = sm.Error
+			}
+		}
+		report.Stages = append(report.Stages, entry)
+	}
+
+	return report
+}
+
+// compensate undoes the succeeded stages of a failed run, in reverse
+// topological order so that a stage is compensated before the stages it
+// depends on. It runs when a failure stops the run and the failed stage
+// had the FailCompensate policy or the workflow is a saga. Compensation
+// errors do not stop the stages that remain to be compensated.
+func (r *Run) compensate(ctx context.Context, report *RunReport) {
+	trigger := false
+	for _, entry := range report.Stages {
+		if r.stopsRun(entry) && (r.def.saga || r.stage(entry.ID).OnFailure == FailCompensate) {
+			trigger = true
+			break
+		}
+	}
+	if !trigger {
+		return
+	}
+
+	// Compensation must run even if the run was cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	var errs []error
+	for i := len(report.Stages) - 1; i >= 0; i-- {
+		entry := &report.Stages[i]
+		stage := r.stage(entry.ID)
+		if entry.Status != StageSucceeded || stage.Compensate == nil {
+			continue
+		}
+
+		output, _ := r.results.Load(entry.ID)
+		stageCtx := &StageContext{
+			Stage:        stage,
+			Input:        r.input,
+			Output:       output,
+			Metadata:     make(map[string]interface{}),
+			Dependencies: r.getDependencyResults(entry.ID),
+		}
+		entry.CompensationErr = stage.Compensate(ctx, stageCtx)
+		entry.Compensated = true
+		report.Compensated = append(report.Compensated, entry.ID)
+		if entry.CompensationErr != nil {
+			errs = append(errs, fmt.Errorf("compensate %s: %w", entry.ID, entry.CompensationErr))
+		}
+
+		event := Event{Source: r.def.name, RunID: r.id, Stage: entry.ID, Parent: entry.Parent}
+		r.emitStage(event, EventStageCompensated, 1, entry.CompensationErr)
+	}
+	report.CompensationErr = errors.Join(errs...)
+}
+
+// stopsRun reports whether a stage failed in a way that stops the run:
+// not under FailContinue and, for a fan-out child, beyond its stage's
+// MaxChildFailures
+func (r *Run) stopsRun(entry StageReport) bool {
+	if entry.Status != StageFailed || r.stage(entry.ID).OnFailure == FailContinue {
+		return false
+	}
+	if entry.Parent == "" {
+		return true
+	}
+
+	parent := r.stage(entry.Parent)
+	r.dynMu.Lock()
+	defer r.dynMu.Unlock()
+	return parent.MaxChildFailures >= 0 && r.childFailures[entry.Parent] > parent.MaxChildFailures
+}
@@ -0,0 +1,257 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// newTestEngine builds an engine from stages and dependency pairs
+func newTestEngine(t *testing.T, stages []*Stage, deps [][2]string) *WorkflowEngine {
+	t.Helper()
+
+	engine := NewWorkflowEngine("test")
+	for _, stage := range stages {
+		if stage.Execute == nil {
+			stage.Execute = func(ctx context.Context, sc *StageContext) error {
+				sc.Output = sc.Stage.ID
+				return nil
+			}
+		}
+		if err := engine.AddStage(stage); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dep := range deps {
+		if err := engine.AddDependency(dep[0], dep[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return engine
+}
+
+func failing(err error) StageFunc {
+	return func(ctx context.Context, sc *StageContext) error {
+		return err
+	}
+}
+
+func assertStatuses(t *testing.T, report *RunReport, expected map[string]StageStatus) {
+	t.Helper()
+
+	for id, status := range expected {
+		entry, ok := report.Stage(id)
+		if !ok {
+			t.Errorf("%s missing from report", id)
+			continue
+		}
+		if entry.Status != status {
+			t.Errorf("%s: expected %s, got %s (%v)", id, status, entry.Status, entry.Err)
+		}
+	}
+}
+
+func TestWhenSkipsDependents(t *testing.T) {
+	engine := newTestEngine(t, []*Stage{
+		{ID: "detect"},
+		{ID: "migrate", When: func(ctx context.Context, deps map[string]interface{}) bool {
+			return deps["detect"] == "needs-migration"
+		}},
+		{ID: "verify"},
+		{ID: "report"},
+	}, [][2]string{{"detect", "migrate"}, {"migrate", "verify"}, {"detect", "report"}})
+
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatalf("Skipping is not an error, got %v", err)
+	}
+
+	report := engine.Report()
+	assertStatuses(t, report, map[string]StageStatus{
+		"detect":  StageSucceeded,
+		"migrate": StageSkipped,
+		"verify":  StageSkipped,
+		"report":  StageSucceeded,
+	})
+	if !report.Succeeded() {
+		t.Error("A run with only skips should succeed")
+	}
+	if entry, _ := report.Stage("detect"); entry.Attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", entry.Attempts)
+	}
+}
+
+func TestFailurePolicies(t *testing.T) {
+	boom := errors.New("boom")
+
+	t.Run("continue", func(t *testing.T) {
+		engine := newTestEngine(t, []*Stage{
+			{ID: "optional", Execute: failing(boom), OnFailure: FailContinue},
+			{ID: "after-optional"},
+			{ID: "main"},
+		}, [][2]string{{"optional", "after-optional"}})
+
+		err := engine.Execute(context.Background(), nil)
+		if !errors.Is(err, boom) {
+			t.Errorf("Expected the failure to be reported, got %v", err)
+		}
+		assertStatuses(t, engine.Report(), map[string]StageStatus{
+			"optional":       StageFailed,
+			"after-optional": StageSkipped,
+			"main":           StageSucceeded,
+		})
+	})
+
+	t.Run("fail_fast_with_cleanup", func(t *testing.T) {
+		cleaned := false
+		engine := newTestEngine(t, []*Stage{
+			{ID: "setup"},
+			{ID: "critical", Execute: failing(boom)},
+			{ID: "next"},
+			{ID: "cleanup", AlwaysRun: true, Execute: func(ctx context.Context, sc *StageContext) error {
+				cleaned = ctx.Err() == nil
+				return nil
+			}},
+		}, [][2]string{{"setup", "critical"}, {"critical", "next"}, {"next", "cleanup"}})
+
+		if err := engine.Execute(context.Background(), nil); !errors.Is(err, boom) {
+			t.Errorf("Expected failure, got %v", err)
+		}
+		report := engine.Report()
+		assertStatuses(t, report, map[string]StageStatus{
+			"setup":    StageSucceeded,
+			"critical": StageFailed,
+			"next":     StageSkipped,
+			"cleanup":  StageSucceeded,
+		})
+		if !cleaned {
+			t.Error("Cleanup should run with a live context")
+		}
+		if report.Succeeded() {
+			t.Error("Report should not succeed with a failed stage")
+		}
+	})
+
+	t.Run("compensate", func(t *testing.T) {
+		var mu sync.Mutex
+		var undone []string
+		undo := func(ctx context.Context, sc *StageContext) error {
+			mu.Lock()
+			undone = append(undone, sc.Output.(string))
+			mu.Unlock()
+			return nil
+		}
+
+		engine := newTestEngine(t, []*Stage{
+			{ID: "reserve", Compensate: undo},
+			{ID: "charge", Compensate: undo},
+			{ID: "ship", Execute: failing(boom), OnFailure: FailCompensate},
+		}, [][2]string{{"reserve", "charge"}, {"charge", "ship"}})
+
+		engine.Execute(context.Background(), nil)
+
+		if len(undone) != 2 || undone[0] != "charge" || undone[1] != "reserve" {
+			t.Errorf("Expected charge then reserve to be compensated, got %v", undone)
+		}
+		report := engine.Report()
+		for _, id := range []string{"reserve", "charge"} {
+			if entry, _ := report.Stage(id); !entry.Compensated || entry.CompensationErr != nil {
+				t.Errorf("%s should be compensated: %+v", id, entry)
+			}
+		}
+	})
+}
+
+func TestSaga(t *testing.T) {
+	var mu sync.Mutex
+	var undone []string
+	undo := func(ctx context.Context, sc *StageContext) error {
+		mu.Lock()
+		undone = append(undone, sc.Stage.ID)
+		mu.Unlock()
+		if sc.Stage.ID == "hold" {
+			return errors.New("refund pending")
+		}
+		return nil
+	}
+
+	// order fans into hold and charge, which both feed ship
+	boom := errors.New("carrier down")
+	engine := newTestEngine(t, []*Stage{
+		{ID: "order", Compensate: undo},
+		{ID: "hold", Compensate: undo},
+		{ID: "charge", Compensate: undo},
+		{ID: "label"},
+		{ID: "ship", Execute: failing(boom)},
+	}, [][2]string{{"order", "hold"}, {"order", "charge"}, {"hold", "label"}, {"charge", "label"}, {"label", "ship"}})
+
+	var compensated []Event
+	engine.Observe(func(e Event) {
+		if e.Type == EventStageCompensated {
+			compensated = append(compensated, e)
+		}
+	})
+
+	// Without saga semantics a FailFast failure compensates nothing
+	engine.Execute(context.Background(), nil)
+	if len(undone) != 0 {
+		t.Fatalf("Expected no compensation, got %v", undone)
+	}
+
+	engine.SetSaga(true)
+	if err := engine.Execute(context.Background(), nil); !errors.Is(err, boom) {
+		t.Fatalf("Expected the ship failure, got %v", err)
+	}
+
+	// Dependents are compensated before their dependencies, and a failed
+	// compensation does not stop the rest
+	if len(undone) != 3 || undone[2] != "order" {
+		t.Fatalf("Expected order to be compensated last, got %v", undone)
+	}
+	report := engine.Report()
+	if len(report.Compensated) != 3 || report.Compensated[2] != "order" {
+		t.Errorf("Unexpected compensation order %v", report.Compensated)
+	}
+	if entry, _ := report.Stage("hold"); !entry.Compensated || entry.CompensationErr == nil {
+		t.Errorf("Expected hold's compensation error, got %+v", entry)
+	}
+	if entry, _ := report.Stage("label"); entry.Compensated {
+		t.Error("Stages without Compensate are not compensated")
+	}
+	if report.CompensationErr == nil || report.CompensationErr.Error() != "compensate hold: refund pending" {
+		t.Errorf("Unexpected compensation error %v", report.CompensationErr)
+	}
+	if len(compensated) != 3 || compensated[0].Stage == "order" {
+		t.Errorf("Expected an event per compensation, got %+v", compensated)
+	}
+	for _, e := range compensated {
+		if (e.Stage == "hold") != (e.Err != nil) {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	}
+
+	// A successful run is left alone
+	undone = nil
+	ship, _ := engine.GetStage("ship")
+	ship.Execute = func(ctx context.Context, sc *StageContext) error { return nil }
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) != 0 || engine.Report().Compensated != nil {
+		t.Errorf("Successful runs should not compensate, got %v", undone)
+	}
+
+	// So is a run whose only failures let it carry on
+	engine.AddStage(&Stage{ID: "notify", Execute: failing(boom), OnFailure: FailContinue})
+	engine.AddStage(&Stage{ID: "scan", FanOut: func(ctx context.Context, sc *StageContext) ([]ChildTask, error) {
+		return []ChildTask{{ID: "bad", Execute: failing(boom)}, {ID: "good"}}, nil
+	}, Execute: func(ctx context.Context, sc *StageContext) error { return nil }, MaxChildFailures: 1})
+	engine.AddDependency("order", "notify")
+	engine.AddDependency("order", "scan")
+	if err := engine.Execute(context.Background(), nil); !errors.Is(err, boom) {
+		t.Fatalf("Expected the notify failure, got %v", err)
+	}
+	if len(undone) != 0 || engine.Report().Compensated != nil {
+		t.Errorf("Continued and tolerated failures should not compensate, got %v", undone)
+	}
+}
@@ -0,0 +1,124 @@
+package workflow
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Clock abstracts time so retry behaviour can be tested without waiting
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the default Clock backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// RetryPolicy decides whether a failed stage is retried and how long to
+// wait first. Stage.MaxRetries and Stage.MaxElapsed still bound the total.
+type RetryPolicy interface {
+	// Delay returns the wait before retry number attempt (starting at 1)
+	// after err, or false to stop retrying
+	Delay(attempt int, err error) (time.Duration, bool)
+}
+
+// RetryFunc adapts a function to a RetryPolicy
+type RetryFunc func(attempt int, err error) (time.Duration, bool)
+
+// Delay calls the function
+func (f RetryFunc) Delay(attempt int, err error) (time.Duration, bool) {
+	return f(attempt, err)
+}
+
+// ConstantBackoff waits the same delay before every retry
+func ConstantBackoff(delay time.Duration) RetryPolicy {
+	return constantBackoff{delay}
+}
+
+// constantBackoff is the policy returned by ConstantBackoff
+type constantBackoff struct{ delay time.Duration }
+
+func (b constantBackoff) Delay(int, error) (time.Duration, bool) {
+	return b.delay, true
+}
+
+// LinearBackoff waits attempt times step before each retry.
+// LinearBackoff(time.Second) is the default for stages without a policy.
+func LinearBackoff(step time.Duration) RetryPolicy {
+	return linearBackoff{step}
+}
+
+// linearBackoff is the policy returned by LinearBackoff
+type linearBackoff struct{ step time.Duration }
+
+func (b linearBackoff) Delay(attempt int, _ error) (time.Duration, bool) {
+	return time.Duration(attempt) * b.step, true
+}
+
+// ExponentialBackoff multiplies the delay after every retry, up to Max,
+// and optionally randomises it to avoid synchronised retries
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration // Zero means uncapped
+	Multiplier float64       // Defaults to 2
+
+	// Jitter in [0, 1] removes up to that fraction of each delay at random
+	Jitter float64
+
+	// Rand returns values in [0, 1); defaults to math/rand
+	Rand func() float64
+}
+
+// Delay returns Initial * Multiplier^(attempt-1), capped and jittered
+func (b ExponentialBackoff) Delay(attempt int, _ error) (time.Duration, bool) {
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	delay := float64(b.Initial) * math.Pow(multiplier, float64(attempt-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		random := b.Rand
+		if random == nil {
+			random = rand.Float64
+		}
+		delay -= delay * math.Min(b.Jitter, 1) * random()
+	}
+
+	// Uncapped delays outgrow a Duration after enough attempts
+	if delay >= math.MaxInt64 {
+		return math.MaxInt64, true
+	}
+	return time.Duration(delay), true
+}
+
+// RetryIf retries only errors accepted by retryable
+func RetryIf(policy RetryPolicy, retryable func(error) bool) RetryPolicy {
+	return RetryFunc(func(attempt int, err error) (time.Duration, bool) {
+		if !retryable(err) {
+			return 0, false
+		}
+		return policy.Delay(attempt, err)
+	})
+}
+
+// RetryOn retries only errors matching one of targets under errors.Is
+func RetryOn(policy RetryPolicy, targets ...error) RetryPolicy {
+	return RetryIf(policy, func(err error) bool {
+		for _, target := range targets {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,195 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock advances instantly and records every wait
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.waits = append(c.waits, d)
+
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// runFlaky runs a single stage that fails until succeedAt attempts have
+// been made, returning the recorded waits and the attempts made
+func runFlaky(t *testing.T, stage *Stage, succeedAt int, failWith error) ([]time.Duration, int, error) {
+	t.Helper()
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	engine := NewWorkflowEngine("retry")
+	engine.SetClock(clock)
+
+	attempts := 0
+	stage.ID = "flaky"
+	stage.Execute = func(ctx context.Context, sc *StageContext) error {
+		attempts++
+		if attempts < succeedAt {
+			return failWith
+		}
+		return nil
+	}
+	if err := engine.AddStage(stage); err != nil {
+		t.Fatal(err)
+	}
+
+	err := engine.Execute(context.Background(), nil)
+	return clock.waits, attempts, err
+}
+
+func TestRetryPolicies(t *testing.T) {
+	transient := errors.New("transient")
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		expected []time.Duration
+	}{
+		{"default linear", nil, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}},
+		{"constant", ConstantBackoff(time.Second), []time.Duration{time.Second, time.Second, time.Second}},
+		{"exponential", ExponentialBackoff{Initial: time.Second, Max: 3 * time.Second}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}},
+		{"jitter", ExponentialBackoff{Initial: time.Second, Jitter: 0.5, Rand: func() float64 { return 1 }}, []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second}},
+		{"custom", RetryFunc(func(attempt int, err error) (time.Duration, bool) {
+			return time.Duration(attempt) * time.Millisecond, true
+		}), []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits, attempts, err := runFlaky(t, &Stage{MaxRetries: 5, RetryPolicy: tt.policy}, 4, transient)
+			if err != nil {
+				t.Fatalf("Expected success, got %v", err)
+			}
+			if attempts != 4 {
+				t.Errorf("Expected 4 attempts, got %d", attempts)
+			}
+			if len(waits) != len(tt.expected) {
+				t.Fatalf("Expected waits %v, got %v", tt.expected, waits)
+			}
+			for i := range waits {
+				if waits[i] != tt.expected[i] {
+					t.Errorf("Wait %d: expected %v, got %v", i, tt.expected[i], waits[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryRunTimesFollowClock(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	engine := NewWorkflowEngine("retry")
+	engine.SetClock(clock)
+
+	attempts := 0
+	engine.AddStage(&Stage{
+		ID:          "flaky",
+		MaxRetries:  2,
+		RetryPolicy: ConstantBackoff(time.Minute),
+		Execute: func(ctx context.Context, sc *StageContext) error {
+			if attempts++; attempts < 3 {
+				return errors.New("transient")
+			}
+			return nil
+		},
+	})
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The run is timed like its stages, on the engine clock
+	metrics := engine.GetMetrics()
+	if !metrics.StartTime.Equal(time.Unix(0, 0)) || metrics.EndTime.Sub(metrics.StartTime) != 2*time.Minute {
+		t.Errorf("Expected a 2m run from the epoch, got %v to %v", metrics.StartTime, metrics.EndTime)
+	}
+}
+
+func TestExponentialBackoffUncapped(t *testing.T) {
+	backoff := ExponentialBackoff{Initial: time.Second}
+	for _, attempt := range []int{64, 1100} {
+		if delay, _ := backoff.Delay(attempt, nil); delay != math.MaxInt64 {
+			t.Errorf("Attempt %d: expected the delay to saturate, got %v", attempt, delay)
+		}
+	}
+}
+
+func TestRetryLimits(t *testing.T) {
+	transient := errors.New("transient")
+	fatal := errors.New("fatal")
+
+	t.Run("max_retries", func(t *testing.T) {
+		_, attempts, err := runFlaky(t, &Stage{MaxRetries: 2}, 10, transient)
+		if !errors.Is(err, transient) || attempts != 3 {
+			t.Errorf("Expected 3 attempts and the stage error, got %d (%v)", attempts, err)
+		}
+	})
+
+	t.Run("not_retryable", func(t *testing.T) {
+		policy := RetryOn(ConstantBackoff(time.Second), transient)
+		waits, attempts, err := runFlaky(t, &Stage{MaxRetries: 5, RetryPolicy: policy}, 10, fatal)
+		if !errors.Is(err, fatal) || attempts != 1 || len(waits) != 0 {
+			t.Errorf("Fatal errors should not be retried, got %d attempts (%v)", attempts, err)
+		}
+	})
+
+	t.Run("max_elapsed", func(t *testing.T) {
+		stage := &Stage{MaxRetries: 10, RetryPolicy: ConstantBackoff(time.Second), MaxElapsed: 3500 * time.Millisecond}
+		waits, attempts, err := runFlaky(t, stage, 10, transient)
+		if err == nil || attempts != 4 || len(waits) != 3 {
+			t.Errorf("Expected 4 attempts within 3.5s, got %d attempts, waits %v (%v)", attempts, waits, err)
+		}
+	})
+}
+
+func TestRetryCancelledDuringWait(t *testing.T) {
+	engine := NewWorkflowEngine("cancel")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	engine.AddStage(&Stage{
+		ID:          "slow",
+		MaxRetries:  5,
+		RetryPolicy: ConstantBackoff(time.Hour),
+		Execute: func(ctx context.Context, sc *StageContext) error {
+			attempts++
+			time.AfterFunc(20*time.Millisecond, cancel)
+			return errors.New("failed")
+		},
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- engine.Execute(ctx, nil) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected cancellation, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Retry wait should stop on cancellation")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected no retry after cancellation, got %d attempts", attempts)
+	}
+}
@@ -0,0 +1,571 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/maya-framework/maya/internal/graph"
+	"github.com/maya-framework/maya/internal/observer"
+)
+
+// WorkflowDefinition is an immutable snapshot of a WorkflowEngine's stages,
+// dependencies and configuration. It holds no run state, so any number of
+// runs of one definition may proceed at once, each inspected through its
+// own Run.
+type WorkflowDefinition struct {
+	name        string
+	description string
+	graph       *graph.TypedGraph[string, *Stage, struct{}]
+	stages      map[string]*Stage
+
+	// Configuration
+	maxConcurrency int
+	timeout        time.Duration
+	clock          Clock
+	quotas         *Quotas
+	saga           bool
+	checkpoints    CheckpointStore
+	codec          OutputCodec
+
+	// Observers of the engine the definition was compiled from
+	events *observer.List[Event]
+}
+
+// Compile snapshots the workflow into a definition. Later changes to the
+// engine or its stages do not affect the definition; observers registered
+// on the engine see the runs of every definition compiled from it.
+func (w *WorkflowEngine) Compile() (*WorkflowDefinition, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	def := &WorkflowDefinition{
+		name:           w.name,
+		description:    w.description,
+		graph:          w.graph.Clone(),
+		stages:         make(map[string]*Stage, len(w.stages)),
+		maxConcurrency: w.maxConcurrency,
+		timeout:        w.timeout,
+		clock:          w.clock,
+		quotas:         w.quotas,
+		saga:           w.saga,
+		checkpoints:    w.checkpoints,
+		codec:          w.codec,
+		events:         &w.events,
+	}
+
+	for id, stage := range w.stages {
+		if stage.Execute == nil && stage.FanOut == nil {
+			return nil, fmt.Errorf("stage %s has no Execute function", id)
+		}
+
+		copied := *stage
+		copied.Resources = append([]string(nil), stage.Resources...)
+		node, _ := def.graph.GetNode(id)
+		node.Data = &copied
+		def.stages[id] = &copied
+	}
+
+	if _, err := def.graph.TopologicalSort(); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// Name returns the name of the workflow
+func (d *WorkflowDefinition) Name() string {
+	return d.name
+}
+
+// GetStage retrieves a stage by ID. The stage belongs to the definition
+// and must not be modified.
+func (d *WorkflowDefinition) GetStage(id string) (*Stage, bool) {
+	stage, exists := d.stages[id]
+	return stage, exists
+}
+
+// Start runs the workflow in the background under a new run ID and
+// returns the run at once
+func (d *WorkflowDefinition) Start(ctx context.Context, input interface{}) *Run {
+	run := d.newRun(ctx, newRunID(d.name), nil, input)
+	go run.execute()
+	return run
+}
+
+// Execute runs the workflow under a new run ID and returns the finished
+// run along with its error
+func (d *WorkflowDefinition) Execute(ctx context.Context, input interface{}) (*Run, error) {
+	run := d.newRun(ctx, newRunID(d.name), nil, input)
+	return run, run.execute()
+}
+
+// Resume continues a run from its checkpoint. Stages whose output was
+// saved are not executed again; their stored outputs are handed to their
+// dependents as if they had just run.
+func (d *WorkflowDefinition) Resume(ctx context.Context, runID string, input interface{}) (*Run, error) {
+	restored, err := d.restore(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	run := d.newRun(ctx, runID, restored, input)
+	return run, run.execute()
+}
+
+// restore loads and decodes the checkpoint of a run
+func (d *WorkflowDefinition) restore(runID string) (map[string]interface{}, error) {
+	if d.checkpoints == nil {
+		return nil, fmt.Errorf("no checkpoint store configured")
+	}
+
+	saved, err := d.checkpoints.Load(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := make(map[string]interface{}, len(saved))
+	for stageID, data := range saved {
+		// Stages removed since the checkpoint was taken are ignored
+		if _, ok := d.stages[stageID]; !ok && !d.isChild(stageID) {
+			continue
+		}
+		output, err := d.codec.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding checkpoint of stage %s: %w", stageID, err)
+		}
+		restored[stageID] = output
+	}
+	return restored, nil
+}
+
+// isChild reports whether stageID names a dynamic child of one of the
+// definition's fan-out stages
+func (d *WorkflowDefinition) isChild(stageID string) bool {
+	for id, stage := range d.stages {
+		if stage.FanOut != nil && strings.HasPrefix(stageID, id+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// lastRunStamp keeps run IDs unique when runs start in the same nanosecond
+var lastRunStamp atomic.Int64
+
+// newRunID returns a run ID made of the name and a unique timestamp
+func newRunID(name string) string {
+	for {
+		last := lastRunStamp.Load()
+		stamp := max(time.Now().UnixNano(), last+1)
+		if lastRunStamp.CompareAndSwap(last, stamp) {
+			return fmt.Sprintf("%s-%d", name, stamp)
+		}
+	}
+}
+
+// Run is one execution of a WorkflowDefinition, holding its own results,
+// metrics and cancellation
+type Run struct {
+	def   *WorkflowDefinition
+	id    string
+	input interface{}
+
+	// The definition's graph plus the dynamic children of fan-out stages.
+	// A clone per run, since Schedule inserts the children into the graph
+	// it runs.
+	graph *graph.TypedGraph[string, *Stage, struct{}]
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// Stages restored from a checkpoint
+	resumed map[string]bool
+
+	// Execution state
+	results sync.Map // map[string]interface{}
+	errors  sync.Map // map[string]error
+	metrics *WorkflowMetrics
+
+	// Dynamic children: by fan-out stage, their stage, their task input
+	// and the failures counted against MaxChildFailures
+	fanOuts       map[string][]string
+	parents       map[string]string
+	childInputs   map[string]interface{}
+	childFailures map[string]int
+	dynMu         sync.Mutex
+
+	// Set when the run finishes
+	err    error
+	report *RunReport
+}
+
+// newRun prepares a run, treating the restored stages as completed
+func (d *WorkflowDefinition) newRun(ctx context.Context, runID string, restored map[string]interface{}, input interface{}) *Run {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Run{
+		def:     d,
+		id:      runID,
+		input:   input,
+		graph:   d.graph.Clone(),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		resumed: make(map[string]bool, len(restored)),
+		metrics: &WorkflowMetrics{
+			StageMetrics: make(map[string]*StageMetrics),
+		},
+		fanOuts:       make(map[string][]string),
+		parents:       make(map[string]string),
+		childInputs:   make(map[string]interface{}),
+		childFailures: make(map[string]int),
+	}
+
+	for stageID, output := range restored {
+		r.results.Store(stageID, output)
+		r.resumed[stageID] = true
+	}
+	return r
+}
+
+// ID returns the run ID, also reported in RunReport.RunID
+func (r *Run) ID() string {
+	return r.id
+}
+
+// Cancel stops the run; running stages see their context cancelled
+func (r *Run) Cancel() {
+	r.cancel()
+}
+
+// Done is closed when the run has finished
+func (r *Run) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait blocks until the run has finished and returns its error
+func (r *Run) Wait() error {
+	<-r.done
+	return r.err
+}
+
+// Report returns the report of the run, or nil while it is running
+func (r *Run) Report() *RunReport {
+	select {
+	case <-r.done:
+		return r.report
+	default:
+		return nil
+	}
+}
+
+// execute runs the workflow to completion
+func (r *Run) execute() error {
+	defer close(r.done)
+	defer r.cancel()
+
+	d := r.def
+	ctx := r.ctx
+	d.events.Notify(Event{Type: EventRunStarted, Source: d.name, RunID: r.id, Time: d.clock.Now()})
+
+	// Create context with timeout
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	// Initialize metrics
+	r.metrics.mu.Lock()
+	r.metrics.StartTime = d.clock.Now()
+	r.metrics.TotalStages = len(d.stages)
+	r.metrics.mu.Unlock()
+
+	// Execute using the graph scheduler, starting stages as soon as their
+	// dependencies complete
+	opts := graph.ScheduleOptions[string, *Stage]{
+		MaxConcurrency: d.maxConcurrency,
+		StopOnFailure: func(node *graph.TypedNode[string, *Stage]) bool {
+			return node.Data.OnFailure != FailContinue
+		},
+		AlwaysRun: func(node *graph.TypedNode[string, *Stage]) bool {
+			return node.Data.AlwaysRun
+		},
+	}
+	outcomes, err := r.graph.Schedule(ctx, opts, func(ctx context.Context, node *graph.TypedNode[string, *Stage]) error {
+		return r.executeStage(ctx, node.Data)
+	})
+
+	r.metrics.mu.Lock()
+	r.metrics.EndTime = d.clock.Now()
+	r.metrics.mu.Unlock()
+
+	report := r.buildReport(outcomes, err)
+	r.compensate(ctx, report)
+
+	r.err = err
+	r.report = report
+	d.events.Notify(Event{Type: EventRunFinished, Source: d.name, RunID: r.id, Time: d.clock.Now(), Err: err, Report: report})
+
+	return err
+}
+
+// stage returns a stage of the run, including dynamic children
+func (r *Run) stage(id string) *Stage {
+	if node, ok := r.graph.GetNode(id); ok {
+		return node.Data
+	}
+	return nil
+}
+
+// executeStage executes a single stage with retry logic
+func (r *Run) executeStage(ctx context.Context, stage *Stage) error {
+	d := r.def
+
+	// Restored from a checkpoint, the output is already in results
+	if r.resumed[stage.ID] {
+		return nil
+	}
+
+	dependencies := r.getDependencyResults(stage.ID)
+	if stage.When != nil && !stage.When(ctx, dependencies) {
+		return graph.ErrSkipNode
+	}
+
+	parent, isChild := r.parentOf(stage.ID)
+	metrics := &StageMetrics{
+		StartTime: d.clock.Now(),
+		Parent:    parent,
+	}
+
+	// Store metrics
+	r.metrics.mu.Lock()
+	r.metrics.StageMetrics[stage.ID] = metrics
+	r.metrics.mu.Unlock()
+
+	event := Event{Source: d.name, RunID: r.id, Stage: stage.ID, Parent: parent}
+	r.emitStage(event, EventStageStarted, 1, nil)
+
+	// Create stage context; children get their task input
+	input := r.input
+	if isChild {
+		input, _ = r.childInput(stage.ID)
+	}
+	stageCtx := &StageContext{
+		Stage:        stage,
+		Input:        input,
+		Metadata:     make(map[string]interface{}),
+		Dependencies: dependencies,
+	}
+
+	// Apply timeout if specified
+	if stage.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, stage.Timeout)
+		defer cancel()
+	}
+
+	// Execute with retries
+	policy := stage.RetryPolicy
+	if policy == nil {
+		policy = LinearBackoff(time.Second)
+	}
+
+	// Fan-out stages produce their children instead of running
+	execute := stage.Execute
+	var tasks []ChildTask
+	if stage.FanOut != nil {
+		execute = func(ctx context.Context, sc *StageContext) error {
+			var err error
+			tasks, err = stage.FanOut(ctx, sc)
+			return err
+		}
+	}
+
+	var err error
+	attempts := 0
+	var quotaWait time.Duration
+retries:
+	for attempt := 0; ; attempt++ {
+		attempts = attempt + 1
+
+		// Execute stage once its quota is available
+		release, wait, quotaErr := acquireQuota(ctx, d.quotas, stage.Resources, stage.RateLimit)
+		quotaWait += wait
+		if quotaErr != nil {
+			err = quotaErr
+			break
+		}
+		err = execute(ctx, stageCtx)
+		release()
+		if err == nil {
+			break
+		}
+		r.emitStage(event, EventAttemptFailed, attempt+1, err)
+
+		if attempt >= stage.MaxRetries || ctx.Err() != nil {
+			break
+		}
+
+		delay, retry := policy.Delay(attempt+1, err)
+		if !retry {
+			break
+		}
+		if stage.MaxElapsed > 0 && d.clock.Now().Add(delay).Sub(metrics.StartTime) > stage.MaxElapsed {
+			break
+		}
+
+		// Wait before retry
+		event.Delay = delay
+		r.emitStage(event, EventRetryScheduled, attempt+2, err)
+		event.Delay = 0
+		select {
+		case <-d.clock.After(delay):
+		case <-ctx.Done():
+			err = ctx.Err()
+			break retries
+		}
+	}
+
+	if err == nil {
+		err = r.saveCheckpoint(stage.ID, stageCtx.Output)
+	}
+	if err == nil {
+		// Success
+		r.results.Store(stage.ID, stageCtx.Output)
+	}
+
+	// Update metrics
+	end := d.clock.Now()
+	r.metrics.mu.Lock()
+	metrics.RetryCount = attempts - 1
+	metrics.QuotaWait = quotaWait
+	metrics.Success = err == nil
+	metrics.EndTime = end
+	metrics.Duration = end.Sub(metrics.StartTime)
+	metrics.Error = err
+	if err != nil {
+		r.metrics.FailedStages++
+	} else {
+		r.metrics.CompletedStages++
+	}
+	r.metrics.mu.Unlock()
+	r.emitStage(event, EventStageCompleted, attempts, err)
+
+	if err != nil {
+		r.errors.Store(stage.ID, err)
+		err = fmt.Errorf("stage %s failed: %w", stage.ID, err)
+		if isChild && r.tolerateFailure(parent) {
+			return graph.Tolerate(err)
+		}
+		return err
+	}
+	if stage.FanOut != nil {
+		return r.expand(stage, tasks)
+	}
+	return nil
+}
+
+// emitStage stamps and delivers a stage event
+func (r *Run) emitStage(event Event, typ EventType, attempt int, err error) {
+	if r.def.events.Len() == 0 {
+		return
+	}
+	event.Type = typ
+	event.Time = r.def.clock.Now()
+	event.Attempt = attempt
+	event.Err = err
+	r.def.events.Notify(event)
+}
+
+// saveCheckpoint persists the output of a completed stage, if a
+// checkpoint store is configured
+func (r *Run) saveCheckpoint(stageID string, output interface{}) error {
+	d := r.def
+	if d.checkpoints == nil {
+		return nil
+	}
+
+	// Fan-out stages run again on resume to recreate their children,
+	// whose outputs are saved under their own "<stage>/<id>"
+	if stage, static := d.stages[stageID]; static && stage.FanOut != nil {
+		return nil
+	}
+
+	data, err := d.codec.Encode(output)
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+	if err := d.checkpoints.Save(r.id, stageID, data); err != nil {
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
+	return nil
+}
+
+// getDependencyResults gets results from dependency stages. The children
+// of a fan-out stage are reported through it as a *FanOutResult, except
+// to the children themselves, which get its plain output.
+func (r *Run) getDependencyResults(stageID string) map[string]interface{} {
+	dependencies := r.graph.GetDependencies(stageID)
+	results := make(map[string]interface{})
+	parent, _ := r.parentOf(stageID)
+
+	for _, depID := range dependencies {
+		if _, isChild := r.parentOf(depID); isChild {
+			continue
+		}
+		value, ok := r.results.Load(depID)
+		if !ok {
+			continue
+		}
+		if depID != parent {
+			if fanOut, ok := r.fanOutResult(depID, value); ok {
+				value = fanOut
+			}
+		}
+		results[depID] = value
+	}
+
+	return results
+}
+
+// GetResult returns the result of a stage
+func (r *Run) GetResult(stageID string) (interface{}, bool) {
+	return r.results.Load(stageID)
+}
+
+// GetError returns the error of a stage
+func (r *Run) GetError(stageID string) (error, bool) {
+	if err, ok := r.errors.Load(stageID); ok {
+		return err.(error), true
+	}
+	return nil, false
+}
+
+// CriticalPath returns the chain of dependent stages that bounded the
+// latency of the run, using the recorded stage durations. Stages that did
+// not run count as zero.
+func (r *Run) CriticalPath() ([]string, time.Duration, error) {
+	r.metrics.mu.RLock()
+	defer r.metrics.mu.RUnlock()
+
+	path, err := r.graph.CriticalPath(func(node *graph.TypedNode[string, *Stage]) float64 {
+		if sm, ok := r.metrics.StageMetrics[node.ID]; ok {
+			return float64(sm.Duration)
+		}
+		return 0
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return path.Nodes, time.Duration(path.Cost), nil
+}
+
+// GetMetrics returns a copy of the run's metrics
+func (r *Run) GetMetrics() *WorkflowMetrics {
+	return r.metrics.snapshot()
+}
@@ -0,0 +1,187 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentRunsOfOneDefinition(t *testing.T) {
+	// Every run blocks in "wait" until all have started, proving overlap
+	const runs = 4
+	var started sync.WaitGroup
+	started.Add(runs)
+
+	engine := newTestEngine(t, []*Stage{
+		{
+			ID: "wait",
+			Execute: func(ctx context.Context, sc *StageContext) error {
+				started.Done()
+				started.Wait()
+				sc.Output = sc.Input
+				return nil
+			},
+		},
+		{
+			ID: "double",
+			Execute: func(ctx context.Context, sc *StageContext) error {
+				sc.Output = sc.Dependencies["wait"].(int) * 2
+				return nil
+			},
+		},
+	}, [][2]string{{"wait", "double"}})
+
+	def, err := engine.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var all []*Run
+	for i := 0; i < runs; i++ {
+		all = append(all, def.Start(context.Background(), i))
+	}
+
+	ids := make(map[string]bool)
+	for i, run := range all {
+		if err := run.Wait(); err != nil {
+			t.Fatalf("Run %d failed: %v", i, err)
+		}
+		if result, _ := run.GetResult("double"); result != i*2 {
+			t.Errorf("Run %d: expected %d, got %v", i, i*2, result)
+		}
+		if metrics := run.GetMetrics(); metrics.CompletedStages != 2 {
+			t.Errorf("Run %d: expected 2 completed stages, got %d", i, metrics.CompletedStages)
+		}
+		if report := run.Report(); report == nil || report.RunID != run.ID() || !report.Succeeded() {
+			t.Errorf("Run %d: unexpected report %+v", i, report)
+		}
+		ids[run.ID()] = true
+	}
+	if len(ids) != runs {
+		t.Errorf("Expected %d distinct run IDs, got %d", runs, len(ids))
+	}
+}
+
+func TestDefinitionIsImmutable(t *testing.T) {
+	engine := newTestEngine(t, []*Stage{{ID: "a"}}, nil)
+	def, err := engine.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes to the engine after compiling do not reach the definition
+	stage, _ := engine.GetStage("a")
+	stage.Execute = failing(errors.New("changed"))
+	engine.AddStage(&Stage{ID: "b", Execute: failing(errors.New("added"))})
+
+	run, err := def.Execute(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Definition should run the compiled stages: %v", err)
+	}
+	if _, ok := run.GetResult("b"); ok || len(run.Report().Stages) != 1 {
+		t.Error("Stages added after compiling should not run")
+	}
+	if err := engine.Execute(context.Background(), nil); err == nil {
+		t.Error("The engine should run its current stages")
+	}
+
+	// The engine's accessors follow its latest run only
+	if _, ok := engine.GetError("b"); !ok {
+		t.Error("Expected the engine to report the error of b")
+	}
+	if _, ok := run.GetError("b"); ok {
+		t.Error("Runs must not share errors")
+	}
+
+	engine.AddStage(&Stage{ID: "broken"})
+	if _, err := engine.Compile(); err == nil {
+		t.Error("Stages without Execute should not compile")
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	engine := newTestEngine(t, []*Stage{{
+		ID: "block",
+		Execute: func(ctx context.Context, sc *StageContext) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}}, nil)
+	def, err := engine.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocked := def.Start(context.Background(), nil)
+	other := def.Start(context.Background(), nil)
+	blocked.Cancel()
+
+	select {
+	case <-blocked.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Cancel should stop the run")
+	}
+	if err := blocked.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancellation error, got %v", err)
+	}
+
+	// Cancelling one run leaves the others running
+	select {
+	case <-other.Done():
+		t.Fatal("Cancel must only affect its own run")
+	default:
+	}
+	if other.Report() != nil {
+		t.Error("A running run has no report yet")
+	}
+	other.Cancel()
+	other.Wait()
+}
+
+func TestResumeDefinition(t *testing.T) {
+	store := NewMemoryCheckpointStore()
+	calls := make(map[string]int)
+	var mu sync.Mutex
+	fail := true
+
+	engine := newTestEngine(t, []*Stage{
+		{ID: "extract"},
+		{
+			ID: "load",
+			Execute: func(ctx context.Context, sc *StageContext) error {
+				mu.Lock()
+				defer mu.Unlock()
+				calls["load"]++
+				if fail {
+					return fmt.Errorf("database down")
+				}
+				return nil
+			},
+		},
+	}, [][2]string{{"extract", "load"}})
+	engine.SetCheckpointStore(store, nil)
+
+	def, err := engine.Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := def.Execute(context.Background(), nil)
+	if err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+
+	fail = false
+	resumed, err := def.Resume(context.Background(), first.ID(), nil)
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if entry, _ := resumed.Report().Stage("extract"); !entry.Resumed {
+		t.Error("extract should be restored from the checkpoint")
+	}
+	if calls["load"] != 2 {
+		t.Errorf("Expected load to run twice, got %d", calls["load"])
+	}
+}
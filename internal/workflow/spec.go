@@ -0,0 +1,472 @@
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WorkflowSpec is the declarative form of a workflow. Durations are
+// strings in time.ParseDuration format, e.g. "1m30s". An absent
+// MaxConcurrency or Timeout keeps the NewWorkflowEngine default, while an
+// explicit 0 or "0s" removes the limit.
+type WorkflowSpec struct {
+	Name           string      `json:"name"`
+	Description    string      `json:"description,omitempty"`
+	MaxConcurrency *int        `json:"maxConcurrency,omitempty"`
+	Timeout        string      `json:"timeout,omitempty"`
+	Saga           bool        `json:"saga,omitempty"`
+	Stages         []StageSpec `json:"stages"`
+}
+
+// StageSpec describes a stage. Run, When and Compensate name functions in
+// the Registry the spec is built with.
+type StageSpec struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Run         string     `json:"run"`
+	DependsOn   []string   `json:"dependsOn,omitempty"`
+	Timeout     string     `json:"timeout,omitempty"`
+	MaxRetries  int        `json:"maxRetries,omitempty"`
+	Retry       *RetrySpec `json:"retry,omitempty"`
+	MaxElapsed  string     `json:"maxElapsed,omitempty"`
+	When        string     `json:"when,omitempty"`
+	OnFailure   string     `json:"onFailure,omitempty"`
+	AlwaysRun   bool       `json:"alwaysRun,omitempty"`
+	Compensate  string     `json:"compensate,omitempty"`
+	Parallel    bool       `json:"parallel,omitempty"`
+	MaxWorkers  int        `json:"maxWorkers,omitempty"`
+	Resources   []string   `json:"resources,omitempty"`
+}
+
+// RetrySpec describes a retry policy: "constant" and "linear" wait Delay
+// (times the attempt for linear), "exponential" starts at Delay
+type RetrySpec struct {
+	Policy     string  `json:"policy"`
+	Delay      string  `json:"delay"`
+	Max        string  `json:"max,omitempty"`
+	Multiplier float64 `json:"multiplier,omitempty"`
+	Jitter     float64 `json:"jitter,omitempty"`
+}
+
+// failurePolicies maps spec names to failure policies; FailFast is the
+// default and may be left out
+var failurePolicies = map[string]FailurePolicy{
+	"":           FailFast,
+	"fail-fast":  FailFast,
+	"continue":   FailContinue,
+	"compensate": FailCompensate,
+}
+
+// SpecError is a problem found at a path in a spec, e.g.
+// "stages[2].dependsOn[0]"
+type SpecError struct {
+	Path string
+	Err  error
+}
+
+// Error returns the path and the problem
+func (e *SpecError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying problem
+func (e *SpecError) Unwrap() error {
+	return e.Err
+}
+
+// Condition decides from the dependency results whether a stage runs,
+// see Stage.When
+type Condition func(ctx context.Context, deps map[string]interface{}) bool
+
+// Registry binds the function names used in specs to Go functions
+type Registry struct {
+	funcs      map[string]StageFunc
+	conditions map[string]Condition
+	mu         sync.RWMutex
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		funcs:      make(map[string]StageFunc),
+		conditions: make(map[string]Condition),
+	}
+}
+
+// Register names a stage function for Run and Compensate
+func (r *Registry) Register(name string, fn StageFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.funcs[name] = fn
+}
+
+// RegisterCondition names a condition for When
+func (r *Registry) RegisterCondition(name string, fn Condition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conditions[name] = fn
+}
+
+// stageBinding records the registry names a stage was built from
+type stageBinding struct {
+	run, when, compensate string
+}
+
+// ParseSpec decodes a JSON spec, rejecting unknown fields
+func ParseSpec(data []byte) (*WorkflowSpec, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var spec WorkflowSpec
+	if err := decoder.Decode(&spec); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, &SpecError{Path: specPath(typeErr.Field), Err: fmt.Errorf("expected %s, got %s", typeErr.Type, typeErr.Value)}
+		}
+		return nil, &SpecError{Err: err}
+	}
+	return &spec, nil
+}
+
+// specPath converts a decoder field path such as "stages.0.timeout" to
+// the "stages[0].timeout" form used by SpecError
+func specPath(field string) string {
+	var path strings.Builder
+	for i, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil {
+			fmt.Fprintf(&path, "[%s]", part)
+			continue
+		}
+		if i > 0 {
+			path.WriteByte('.')
+		}
+		path.WriteString(part)
+	}
+	return path.String()
+}
+
+// LoadWorkflow parses a JSON spec and builds its engine
+func LoadWorkflow(data []byte, registry *Registry) (*WorkflowEngine, error) {
+	spec, err := ParseSpec(data)
+	if err != nil {
+		return nil, err
+	}
+	return spec.Build(registry)
+}
+
+// specBuilder collects the problems found while building a spec
+type specBuilder struct {
+	registry *Registry
+	errs     []error
+}
+
+func (b *specBuilder) fail(path string, err error) {
+	b.errs = append(b.errs, &SpecError{Path: path, Err: err})
+}
+
+// duration parses an optional duration field
+func (b *specBuilder) duration(path, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		b.fail(path, fmt.Errorf("invalid duration %q", value))
+		return 0
+	}
+	if d < 0 {
+		b.fail(path, fmt.Errorf("must not be negative"))
+		return 0
+	}
+	return d
+}
+
+// stageFunc looks up a registered stage function
+func (b *specBuilder) stageFunc(path, name string) StageFunc {
+	b.registry.mu.RLock()
+	fn, ok := b.registry.funcs[name]
+	b.registry.mu.RUnlock()
+
+	if !ok {
+		b.fail(path, fmt.Errorf("unknown function %q", name))
+	}
+	return fn
+}
+
+// retryPolicy builds the policy of a retry spec
+func (b *specBuilder) retryPolicy(path string, spec *RetrySpec) RetryPolicy {
+	delay := b.duration(path+".delay", spec.Delay)
+
+	switch spec.Policy {
+	case "constant":
+		return ConstantBackoff(delay)
+	case "linear":
+		return LinearBackoff(delay)
+	case "exponential":
+		if spec.Jitter < 0 || spec.Jitter > 1 {
+			b.fail(path+".jitter", fmt.Errorf("must be between 0 and 1"))
+		}
+		return ExponentialBackoff{
+			Initial:    delay,
+			Max:        b.duration(path+".max", spec.Max),
+			Multiplier: spec.Multiplier,
+			Jitter:     spec.Jitter,
+		}
+	default:
+		b.fail(path+".policy", fmt.Errorf("unknown retry policy %q", spec.Policy))
+		return nil
+	}
+}
+
+// stage builds a stage and its binding from its spec
+func (b *specBuilder) stage(path string, spec StageSpec) (*Stage, stageBinding) {
+	if spec.ID == "" {
+		b.fail(path+".id", fmt.Errorf("is required"))
+	}
+	if spec.Run == "" {
+		b.fail(path+".run", fmt.Errorf("is required"))
+	}
+	if spec.MaxRetries < 0 {
+		b.fail(path+".maxRetries", fmt.Errorf("must not be negative"))
+	}
+	if spec.MaxWorkers < 0 {
+		b.fail(path+".maxWorkers", fmt.Errorf("must not be negative"))
+	}
+
+	stage := &Stage{
+		ID:          spec.ID,
+		Name:        spec.Name,
+		Description: spec.Description,
+		Timeout:     b.duration(path+".timeout", spec.Timeout),
+		MaxRetries:  spec.MaxRetries,
+		MaxElapsed:  b.duration(path+".maxElapsed", spec.MaxElapsed),
+		AlwaysRun:   spec.AlwaysRun,
+		Parallel:    spec.Parallel,
+		MaxWorkers:  spec.MaxWorkers,
+		Resources:   slices.Clone(spec.Resources),
+	}
+	binding := stageBinding{run: spec.Run, when: spec.When, compensate: spec.Compensate}
+
+	if spec.Run != "" {
+		stage.Execute = b.stageFunc(path+".run", spec.Run)
+	}
+	if spec.Compensate != "" {
+		stage.Compensate = b.stageFunc(path+".compensate", spec.Compensate)
+	}
+	if spec.When != "" {
+		b.registry.mu.RLock()
+		condition, ok := b.registry.conditions[spec.When]
+		b.registry.mu.RUnlock()
+
+		if ok {
+			stage.When = condition
+		} else {
+			b.fail(path+".when", fmt.Errorf("unknown condition %q", spec.When))
+		}
+	}
+	if spec.Retry != nil {
+		stage.RetryPolicy = b.retryPolicy(path+".retry", spec.Retry)
+	}
+
+	policy, ok := failurePolicies[spec.OnFailure]
+	if !ok {
+		b.fail(path+".onFailure", fmt.Errorf("unknown failure policy %q", spec.OnFailure))
+	}
+	stage.OnFailure = policy
+
+	for i, resource := range spec.Resources {
+		if resource == "" {
+			b.fail(fmt.Sprintf("%s.resources[%d]", path, i), fmt.Errorf("must not be empty"))
+		}
+	}
+
+	return stage, binding
+}
+
+// Build validates the spec and creates its engine, binding function names
+// through registry. Every problem found is reported as a *SpecError,
+// joined together.
+func (spec *WorkflowSpec) Build(registry *Registry) (*WorkflowEngine, error) {
+	b := &specBuilder{registry: registry}
+
+	if spec.Name == "" {
+		b.fail("name", fmt.Errorf("is required"))
+	}
+	if spec.MaxConcurrency != nil && *spec.MaxConcurrency < 0 {
+		b.fail("maxConcurrency", fmt.Errorf("must not be negative"))
+	}
+
+	engine := NewWorkflowEngine(spec.Name)
+	engine.SetDescription(spec.Description)
+	if spec.MaxConcurrency != nil {
+		engine.SetMaxConcurrency(*spec.MaxConcurrency)
+	}
+	if spec.Timeout != "" {
+		engine.SetTimeout(b.duration("timeout", spec.Timeout))
+	}
+	engine.SetSaga(spec.Saga)
+	engine.bindings = make(map[string]stageBinding, len(spec.Stages))
+
+	for i, stageSpec := range spec.Stages {
+		path := fmt.Sprintf("stages[%d]", i)
+		stage, binding := b.stage(path, stageSpec)
+		if stage.ID == "" {
+			continue
+		}
+		if err := engine.AddStage(stage); err != nil {
+			b.fail(path+".id", fmt.Errorf("duplicate stage %q", stage.ID))
+			continue
+		}
+		engine.bindings[stage.ID] = binding
+	}
+
+	for i, stageSpec := range spec.Stages {
+		for j, dep := range stageSpec.DependsOn {
+			path := fmt.Sprintf("stages[%d].dependsOn[%d]", i, j)
+			if _, ok := engine.GetStage(dep); !ok {
+				b.fail(path, fmt.Errorf("unknown stage %q", dep))
+				continue
+			}
+			if dep == stageSpec.ID {
+				b.fail(path, fmt.Errorf("stage depends on itself"))
+				continue
+			}
+			if err := engine.AddDependency(dep, stageSpec.ID); err != nil {
+				b.fail(path, err)
+			}
+		}
+	}
+
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+	return engine, nil
+}
+
+// Spec describes the engine in the declarative form. Stages must have
+// been built from a spec so their functions can be named; retry policies
+// other than the built-in backoffs cannot be described.
+func (w *WorkflowEngine) Spec() (*WorkflowSpec, error) {
+	order, err := w.graph.TopologicalSort()
+	if err != nil {
+		return nil, err
+	}
+
+	// Engine limits are always written: leaving them out would bring
+	// back the defaults
+	maxConcurrency := w.maxConcurrency
+	spec := &WorkflowSpec{
+		Name:           w.name,
+		Description:    w.description,
+		MaxConcurrency: &maxConcurrency,
+		Timeout:        w.timeout.String(),
+		Saga:           w.saga,
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var errs []error
+	for _, id := range order {
+		stage := w.stages[id]
+		binding, ok := w.bindings[id]
+		if !ok {
+			errs = append(errs, fmt.Errorf("stage %s: functions are not bound to registry names", id))
+			continue
+		}
+
+		stageSpec := StageSpec{
+			ID:          stage.ID,
+			Name:        stage.Name,
+			Description: stage.Description,
+			Run:         binding.run,
+			Timeout:     formatDuration(stage.Timeout),
+			MaxRetries:  stage.MaxRetries,
+			MaxElapsed:  formatDuration(stage.MaxElapsed),
+			When:        binding.when,
+			AlwaysRun:   stage.AlwaysRun,
+			Compensate:  binding.compensate,
+			Parallel:    stage.Parallel,
+			MaxWorkers:  stage.MaxWorkers,
+			Resources:   slices.Clone(stage.Resources),
+		}
+		for name, policy := range failurePolicies {
+			if name != "" && name != "fail-fast" && policy == stage.OnFailure {
+				stageSpec.OnFailure = name
+			}
+		}
+
+		if stage.RetryPolicy != nil {
+			retry, err := describeRetry(stage.RetryPolicy)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("stage %s: %w", id, err))
+				continue
+			}
+			stageSpec.Retry = retry
+		}
+
+		deps := w.graph.GetDependencies(id)
+		slices.Sort(deps)
+		stageSpec.DependsOn = deps
+
+		spec.Stages = append(spec.Stages, stageSpec)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return spec, nil
+}
+
+// Export writes the engine as an indented JSON spec that LoadWorkflow
+// accepts with the same registry
+func (w *WorkflowEngine) Export() ([]byte, error) {
+	spec, err := w.Spec()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+// describeRetry returns the spec of a built-in retry policy
+func describeRetry(policy RetryPolicy) (*RetrySpec, error) {
+	switch p := policy.(type) {
+	case constantBackoff:
+		return &RetrySpec{Policy: "constant", Delay: p.delay.String()}, nil
+	case linearBackoff:
+		return &RetrySpec{Policy: "linear", Delay: p.step.String()}, nil
+	case ExponentialBackoff:
+		if p.Rand != nil {
+			return nil, fmt.Errorf("retry policy with a custom Rand cannot be exported")
+		}
+		return &RetrySpec{
+			Policy:     "exponential",
+			Delay:      p.Initial.String(),
+			Max:        formatDuration(p.Max),
+			Multiplier: p.Multiplier,
+			Jitter:     p.Jitter,
+		}, nil
+	default:
+		return nil, fmt.Errorf("retry policy %T cannot be exported", policy)
+	}
+}
+
+// formatDuration formats an optional duration, leaving zero empty
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
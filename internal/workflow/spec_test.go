@@ -0,0 +1,205 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maya-framework/maya/internal/graph"
+)
+
+const etlSpec = `{
+  "name": "etl",
+  "description": "nightly import",
+  "maxConcurrency": 4,
+  "timeout": "1h0m0s",
+  "saga": true,
+  "stages": [
+    {
+      "id": "extract",
+      "run": "extract",
+      "timeout": "30s",
+      "maxRetries": 3,
+      "retry": {"policy": "exponential", "delay": "1s", "max": "10s", "jitter": 0.2},
+      "resources": ["network"]
+    },
+    {
+      "id": "transform",
+      "name": "Transform rows",
+      "run": "transform",
+      "dependsOn": ["extract"],
+      "when": "has-rows",
+      "onFailure": "continue",
+      "parallel": true,
+      "maxWorkers": 8
+    },
+    {
+      "id": "load",
+      "run": "load",
+      "dependsOn": ["transform"],
+      "retry": {"policy": "constant", "delay": "500ms"},
+      "onFailure": "compensate",
+      "compensate": "unload"
+    },
+    {
+      "id": "notify",
+      "run": "notify",
+      "dependsOn": ["extract", "load"],
+      "alwaysRun": true
+    }
+  ]
+}`
+
+func etlRegistry(calls *[]string) *Registry {
+	registry := NewRegistry()
+	for _, name := range []string{"extract", "transform", "load", "notify", "unload"} {
+		registry.Register(name, func(ctx context.Context, sc *StageContext) error {
+			*calls = append(*calls, name)
+			sc.Output = 2
+			return nil
+		})
+	}
+	registry.RegisterCondition("has-rows", func(ctx context.Context, deps map[string]interface{}) bool {
+		return deps["extract"] == 2
+	})
+	return registry
+}
+
+func TestLoadWorkflowAndExport(t *testing.T) {
+	var calls []string
+	registry := etlRegistry(&calls)
+
+	engine, err := LoadWorkflow([]byte(etlSpec), registry)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	quotas := NewQuotas()
+	quotas.SetPool("network", 1)
+	engine.SetQuotas(quotas)
+	engine.SetMaxConcurrency(1)
+	if err := engine.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(calls, ",") != "extract,transform,load,notify" {
+		t.Errorf("Unexpected calls %v", calls)
+	}
+
+	transform, _ := engine.GetStage("transform")
+	if transform.Name != "Transform rows" || transform.OnFailure != FailContinue || transform.MaxWorkers != 8 {
+		t.Errorf("Stage fields not loaded: %+v", transform)
+	}
+	extract, _ := engine.GetStage("extract")
+	if backoff, ok := extract.RetryPolicy.(ExponentialBackoff); !ok || backoff.Max.String() != "10s" {
+		t.Errorf("Unexpected retry policy %#v", extract.RetryPolicy)
+	}
+
+	exported, err := engine.Export()
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	original, _ := ParseSpec([]byte(etlSpec))
+	roundTrip, err := ParseSpec(exported)
+	if err != nil {
+		t.Fatalf("Exported spec does not parse: %v\n%s", err, exported)
+	}
+	// SetMaxConcurrency changed the engine after loading
+	*original.MaxConcurrency = 1
+	if !reflect.DeepEqual(original, roundTrip) {
+		t.Errorf("Round trip mismatch:\n%s", exported)
+	}
+	if _, err := roundTrip.Build(registry); err != nil {
+		t.Errorf("Exported spec should build: %v", err)
+	}
+
+	// Zero limits survive the round trip instead of reverting to defaults
+	engine.SetTimeout(0)
+	engine.SetMaxConcurrency(0)
+	exported, err = engine.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := LoadWorkflow(exported, registry)
+	if err != nil {
+		t.Fatalf("Exported spec should load: %v\n%s", err, exported)
+	}
+	if rebuilt.timeout != 0 || rebuilt.maxConcurrency != 0 {
+		t.Errorf("Expected no limits, got timeout %v and concurrency %d\n%s", rebuilt.timeout, rebuilt.maxConcurrency, exported)
+	}
+
+	// Absent limits keep the engine defaults
+	defaults, err := LoadWorkflow([]byte(`{"name": "defaults", "stages": []}`), registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh := NewWorkflowEngine("fresh"); defaults.timeout != fresh.timeout || defaults.maxConcurrency != fresh.maxConcurrency {
+		t.Errorf("Expected the default limits, got timeout %v and concurrency %d", defaults.timeout, defaults.maxConcurrency)
+	}
+
+	// Imperatively added stages have no function names to export
+	engine.AddStage(&Stage{ID: "adhoc", Execute: func(ctx context.Context, sc *StageContext) error { return nil }})
+	if _, err := engine.Export(); err == nil || !strings.Contains(err.Error(), "stage adhoc") {
+		t.Errorf("Expected an export error for the unbound stage, got %v", err)
+	}
+}
+
+func TestSpecValidation(t *testing.T) {
+	var calls []string
+	registry := etlRegistry(&calls)
+
+	spec := `{
+	  "name": "broken",
+	  "stages": [
+	    {"id": "a", "run": "extract", "timeout": "soon", "dependsOn": ["c"]},
+	    {"id": "b", "run": "missing", "when": "never", "onFailure": "panic"},
+	    {"id": "c", "run": "load", "dependsOn": ["a", "ghost"], "retry": {"policy": "random", "delay": "1s"}},
+	    {"id": "a", "run": "notify"},
+	    {"run": "notify"}
+	  ]
+	}`
+
+	_, err := LoadWorkflow([]byte(spec), registry)
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+
+	expected := []string{
+		`stages[0].timeout: invalid duration "soon"`,
+		`stages[1].run: unknown function "missing"`,
+		`stages[1].when: unknown condition "never"`,
+		`stages[1].onFailure: unknown failure policy "panic"`,
+		`stages[2].retry.policy: unknown retry policy "random"`,
+		`stages[3].id: duplicate stage "a"`,
+		`stages[4].id: is required`,
+		`stages[2].dependsOn[1]: unknown stage "ghost"`,
+	}
+	message := err.Error()
+	for _, want := range expected {
+		if !strings.Contains(message, want) {
+			t.Errorf("Missing %q in:\n%s", want, message)
+		}
+	}
+
+	// The dependency closing the cycle is reported at its path
+	var specErr *SpecError
+	var cycle *graph.CycleError[string]
+	if !errors.As(err, &cycle) {
+		t.Errorf("Expected a cycle error in:\n%s", message)
+	}
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		if errors.As(e, &specErr) && errors.As(e, &cycle) && specErr.Path != "stages[2].dependsOn[0]" {
+			t.Errorf("Cycle reported at %s", specErr.Path)
+		}
+	}
+
+	// Decoding problems carry the field path too
+	_, err = ParseSpec([]byte(`{"name": "x", "stages": [{"id": "a", "maxRetries": "three"}]}`))
+	if !errors.As(err, &specErr) || specErr.Path != "stages[0].maxRetries" {
+		t.Errorf("Expected a path for the type error, got %v", err)
+	}
+	if _, err := ParseSpec([]byte(`{"name": "x", "stagez": []}`)); err == nil {
+		t.Error("Unknown fields should be rejected")
+	}
+}
@@ -0,0 +1,559 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/maya-framework/maya/internal/observer"
+)
+
+var (
+	// ErrStreamClosed is returned by Receive once a stream has delivered
+	// all of its results, and by Send once it is draining
+	ErrStreamClosed = errors.New("stream closed")
+
+	// ErrStreamFull is returned by Send under BackpressureError
+	ErrStreamFull = errors.New("stream input is full")
+
+	// ErrItemDropped is reported for items discarded under
+	// BackpressureDropOldest
+	ErrItemDropped = errors.New("item dropped by backpressure")
+)
+
+// BackpressurePolicy decides what Send does when the input buffer is full
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock waits for room in the buffer
+	BackpressureBlock BackpressurePolicy = iota
+
+	// BackpressureDropOldest discards the oldest queued item, which is
+	// reported with ErrItemDropped
+	BackpressureDropOldest
+
+	// BackpressureError fails the Send with ErrStreamFull
+	BackpressureError
+)
+
+// DeadLetter is an item that failed or was dropped. Seq is its 1-based
+// position in input order.
+type DeadLetter[T any] struct {
+	Item T
+	Seq  uint64
+	Err  error
+}
+
+// streamItem is an input tagged with its position in input order
+type streamItem[T any] struct {
+	seq   uint64
+	value T
+}
+
+// streamResult carries the outcome of an item from a worker to the emitter
+type streamResult[In, Out any] struct {
+	seq     uint64
+	item    In
+	outputs []Out
+	err     error
+}
+
+// streamOutput is a value or an error handed to Receive
+type streamOutput[T any] struct {
+	value T
+	err   error
+}
+
+// TypedStream runs items sent to it through a TypedPipeline, delivering
+// every output of every item
+type TypedStream[In, Out any] struct {
+	name     string
+	pipeline *TypedPipeline[In, Out]
+
+	// Channels
+	input   chan streamItem[In]
+	results chan streamResult[In, Out]
+	output  chan streamOutput[Out]
+
+	// The input channel of the current run, for QueueDepth
+	queue atomic.Pointer[chan streamItem[In]]
+
+	// Control
+	running     atomic.Bool
+	done        chan struct{}
+	closing     chan struct{}
+	stopSending func()
+	closed      bool   // Guarded by sendMu
+	nextSeq     uint64 // Guarded by sendMu
+	sendMu      sync.Mutex
+
+	// Ordered mode: the next sequence number to emit
+	nextOut   uint64
+	orderMu   sync.Mutex
+	orderCond *sync.Cond
+
+	// Lifecycle observers
+	events observer.List[Event]
+
+	// Configuration
+	bufferSize   int
+	workers      int
+	ordered      bool
+	window       int
+	backpressure BackpressurePolicy
+	deadLetter   chan<- DeadLetter[In]
+	quotas       *Quotas
+	resources    []string
+	rateLimit    *RateLimiter
+}
+
+// Stream provides streaming workflow processing of untyped items
+type Stream = TypedStream[interface{}, interface{}]
+
+// StreamProcessor processes stream items
+type StreamProcessor func(context.Context, interface{}) (interface{}, error)
+
+// NewStream creates a new stream processor
+func NewStream(name string, processor StreamProcessor) *Stream {
+	return NewTypedStream(name, &TypedPipeline[interface{}, interface{}]{
+		name: name,
+		run: func(ctx context.Context, sem chan struct{}, items []interface{}) ([]interface{}, error) {
+			result, err := processor(ctx, items[0])
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{result}, nil
+		},
+	})
+}
+
+// NewTypedStream creates a stream processing items with pipeline
+func NewTypedStream[In, Out any](name string, pipeline *TypedPipeline[In, Out]) *TypedStream[In, Out] {
+	return &TypedStream[In, Out]{
+		name:       name,
+		pipeline:   pipeline,
+		bufferSize: 100,
+		workers:    1,
+	}
+}
+
+// SetBufferSize sets the buffer size for channels
+func (s *TypedStream[In, Out]) SetBufferSize(size int) {
+	s.bufferSize = size
+}
+
+// SetWorkers sets the number of workers
+func (s *TypedStream[In, Out]) SetWorkers(workers int) {
+	s.workers = workers
+}
+
+// SetOrdered delivers results in input order. At most window items past
+// the oldest undelivered one are processed at once, bounding the results
+// held back for re-sequencing; a window of zero or less uses the number of
+// workers.
+func (s *TypedStream[In, Out]) SetOrdered(window int) {
+	s.ordered = true
+	s.window = window
+}
+
+// SetBackpressure decides what Send does when the input buffer is full
+func (s *TypedStream[In, Out]) SetBackpressure(policy BackpressurePolicy) {
+	s.backpressure = policy
+}
+
+// SetDeadLetter routes failed and dropped items to ch instead of returning
+// their errors from Receive. The stream never closes ch.
+func (s *TypedStream[In, Out]) SetDeadLetter(ch chan<- DeadLetter[In]) {
+	s.deadLetter = ch
+}
+
+// SetQuotas makes every item hold a slot of each named pool while it is
+// processed
+func (s *TypedStream[In, Out]) SetQuotas(quotas *Quotas, resources ...string) {
+	s.quotas = quotas
+	s.resources = resources
+}
+
+// SetRateLimit spaces out the processing of items
+func (s *TypedStream[In, Out]) SetRateLimit(limiter *RateLimiter) {
+	s.rateLimit = limiter
+}
+
+// Start starts the stream processing
+func (s *TypedStream[In, Out]) Start(ctx context.Context) error {
+	if !s.running.CompareAndSwap(false, true) {
+		return fmt.Errorf("stream is already running")
+	}
+
+	// Initialize channels; results also carry dropped items from Send
+	input := make(chan streamItem[In], s.bufferSize)
+	s.input = input
+	s.queue.Store(&input)
+	s.results = make(chan streamResult[In, Out], s.bufferSize+s.workers)
+	s.output = make(chan streamOutput[Out], s.bufferSize)
+	s.done = make(chan struct{})
+	s.closing = make(chan struct{})
+	s.stopSending = sync.OnceFunc(func() { close(s.closing) })
+	s.closed = false
+	s.nextSeq = 0
+	s.nextOut = 0
+	s.orderCond = sync.NewCond(&s.orderMu)
+
+	// Wake workers waiting for their turn when the run is cancelled
+	stopWake := context.AfterFunc(ctx, func() {
+		s.orderMu.Lock()
+		s.orderCond.Broadcast()
+		s.orderMu.Unlock()
+	})
+
+	runID := newRunID(s.name)
+	s.events.Notify(Event{Type: EventRunStarted, Source: s.name, RunID: runID, Time: time.Now()})
+
+	// Workers share the pipeline's concurrency limit
+	sem := s.pipeline.semaphore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker(ctx, runID, sem)
+		}()
+	}
+
+	emitted := make(chan struct{})
+	go func() {
+		defer close(emitted)
+		s.emitter(ctx)
+	}()
+
+	// Cleanup goroutine
+	go func() {
+		wg.Wait()
+
+		// Dropped items reach results from Send; wake blocked Sends and
+		// refuse new ones before closing it
+		s.stopSending()
+		s.sendMu.Lock()
+		s.closed = true
+		close(s.results)
+		s.sendMu.Unlock()
+
+		<-emitted
+		close(s.output)
+		stopWake()
+		s.running.Store(false)
+		s.events.Notify(Event{Type: EventRunFinished, Source: s.name, RunID: runID, Time: time.Now(), Err: ctx.Err()})
+		close(s.done)
+	}()
+
+	return nil
+}
+
+// worker processes items from the input channel
+func (s *TypedStream[In, Out]) worker(ctx context.Context, runID string, sem chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item, ok := <-s.input:
+			if !ok {
+				return
+			}
+			if s.ordered && !s.waitTurn(ctx, item.seq) {
+				return
+			}
+
+			event := Event{Source: s.name, RunID: runID, Stage: s.name, Item: item.seq + 1, Attempt: 1}
+			s.emitItem(event, EventStageStarted, nil)
+			outputs, err := s.process(ctx, sem, item.value)
+			if err != nil {
+				s.emitItem(event, EventAttemptFailed, err)
+			}
+			s.emitItem(event, EventStageCompleted, err)
+
+			select {
+			case s.results <- streamResult[In, Out]{seq: item.seq, item: item.value, outputs: outputs, err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// process runs an item through the pipeline once its quota is available
+func (s *TypedStream[In, Out]) process(ctx context.Context, sem chan struct{}, item In) ([]Out, error) {
+	release, _, err := acquireQuota(ctx, s.quotas, s.resources, s.rateLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return s.pipeline.run(ctx, sem, []In{item})
+}
+
+// emitItem stamps and delivers an item event
+func (s *TypedStream[In, Out]) emitItem(event Event, typ EventType, err error) {
+	if s.events.Len() == 0 {
+		return
+	}
+	event.Type = typ
+	event.Time = time.Now()
+	event.Err = err
+	s.events.Notify(event)
+}
+
+// waitTurn blocks an ordered worker until seq falls inside the reorder
+// window, reporting false if the run was cancelled meanwhile
+func (s *TypedStream[In, Out]) waitTurn(ctx context.Context, seq uint64) bool {
+	window := s.window
+	if window <= 0 {
+		window = max(s.workers, 1)
+	}
+
+	s.orderMu.Lock()
+	defer s.orderMu.Unlock()
+
+	for seq >= s.nextOut+uint64(window) && ctx.Err() == nil {
+		s.orderCond.Wait()
+	}
+	return ctx.Err() == nil
+}
+
+// emitter delivers results to Receive and the dead-letter channel,
+// re-sequencing them in ordered mode
+func (s *TypedStream[In, Out]) emitter(ctx context.Context) {
+	pending := make(map[uint64]streamResult[In, Out])
+	next := uint64(0)
+
+	for result := range s.results {
+		if !s.ordered {
+			if !s.deliver(ctx, result) {
+				return
+			}
+			continue
+		}
+
+		pending[result.seq] = result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if !s.deliver(ctx, result) {
+				return
+			}
+			next++
+
+			s.orderMu.Lock()
+			s.nextOut = next
+			s.orderCond.Broadcast()
+			s.orderMu.Unlock()
+		}
+	}
+}
+
+// deliver hands the outputs or the error of an item to its destination
+func (s *TypedStream[In, Out]) deliver(ctx context.Context, result streamResult[In, Out]) bool {
+	if result.err != nil && s.deadLetter != nil {
+		select {
+		case s.deadLetter <- DeadLetter[In]{Item: result.item, Seq: result.seq + 1, Err: result.err}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	if result.err != nil {
+		select {
+		case s.output <- streamOutput[Out]{err: result.err}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for _, value := range result.outputs {
+		select {
+		case s.output <- streamOutput[Out]{value: value}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// Send sends an item to the stream. When the input buffer is full it
+// waits, drops the oldest queued item or fails, depending on the
+// backpressure policy.
+func (s *TypedStream[In, Out]) Send(ctx context.Context, item In) error {
+	if !s.running.Load() {
+		return fmt.Errorf("stream is not running")
+	}
+
+	// Serialised so that sequence numbers follow the input channel order
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+	next := streamItem[In]{seq: s.nextSeq, value: item}
+
+	switch s.backpressure {
+	case BackpressureError:
+		select {
+		case s.input <- next:
+			s.nextSeq++
+			return nil
+		default:
+			return ErrStreamFull
+		}
+
+	case BackpressureDropOldest:
+		for {
+			select {
+			case s.input <- next:
+				s.nextSeq++
+				return nil
+			default:
+			}
+
+			// Make room, unless a worker emptied the buffer meanwhile
+			select {
+			case oldest := <-s.input:
+				dropped := streamResult[In, Out]{seq: oldest.seq, item: oldest.value, err: ErrItemDropped}
+				select {
+				case s.results <- dropped:
+				case <-s.closing:
+					return ErrStreamClosed
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				continue
+			default:
+			}
+			break
+		}
+	}
+
+	select {
+	case s.input <- next:
+		s.nextSeq++
+		return nil
+	case <-s.closing:
+		return ErrStreamClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// QueueDepth returns the number of items sent but not yet picked up by a
+// worker
+func (s *TypedStream[In, Out]) QueueDepth() int {
+	if input := s.queue.Load(); input != nil {
+		return len(*input)
+	}
+	return 0
+}
+
+// Receive receives a processed item from the stream, or the error of an
+// item without a dead-letter channel, or ErrStreamClosed once every
+// result has been delivered
+func (s *TypedStream[In, Out]) Receive(ctx context.Context) (Out, error) {
+	var zero Out
+	select {
+	case result, ok := <-s.output:
+		if !ok {
+			return zero, ErrStreamClosed
+		}
+		return result.value, result.err
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// closeInput stops accepting items; blocked Sends fail with ErrStreamClosed
+func (s *TypedStream[In, Out]) closeInput() {
+	// Wake blocked Sends first, they hold sendMu
+	s.stopSending()
+
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.input)
+}
+
+// Drain stops accepting items and waits until every item already sent has
+// been processed and its results delivered. Results must still be received
+// for the drain to finish. If ctx ends first, Drain returns its error and
+// processing continues in the background.
+func (s *TypedStream[In, Out]) Drain(ctx context.Context) error {
+	s.closeInput()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close closes the input channel and waits for processing to complete
+func (s *TypedStream[In, Out]) Close() {
+	s.Drain(context.Background())
+}
+
+// Process provides an iterator for stream processing. Stopping the
+// iteration early cancels the items still in flight.
+func (s *TypedStream[In, Out]) Process(ctx context.Context, items iter.Seq[In]) iter.Seq2[Out, error] {
+	return func(yield func(Out, error) bool) {
+		var zero Out
+		ctx, cancel := context.WithCancel(ctx)
+
+		// Start the stream
+		if err := s.Start(ctx); err != nil {
+			cancel()
+			yield(zero, err)
+			return
+		}
+
+		// Send items
+		sent := make(chan struct{})
+		go func() {
+			defer close(sent)
+			for item := range items {
+				if err := s.Send(ctx, item); err != nil {
+					return
+				}
+			}
+			s.closeInput()
+		}()
+
+		defer func() {
+			cancel()
+			<-sent
+			s.Close()
+		}()
+
+		// Receive results
+		for {
+			result, err := s.Receive(ctx)
+			if errors.Is(err, ErrStreamClosed) {
+				return
+			}
+			if !yield(result, err) || ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
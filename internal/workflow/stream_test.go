@@ -0,0 +1,263 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countTo yields 0..n-1
+func countTo(n int) func(func(interface{}) bool) {
+	return func(yield func(interface{}) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestStreamOrdered(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	stream := NewStream("ordered", func(ctx context.Context, v interface{}) (interface{}, error) {
+		now := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		// Later items finish first
+		time.Sleep(time.Duration(20-v.(int)) * time.Millisecond / 4)
+		return v, nil
+	})
+	stream.SetWorkers(8)
+	stream.SetOrdered(4)
+
+	var results []interface{}
+	for result, err := range stream.Process(context.Background(), countTo(20)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, result)
+	}
+
+	for i, result := range results {
+		if result != i {
+			t.Fatalf("Expected results in input order, got %v", results)
+		}
+	}
+	if len(results) != 20 {
+		t.Errorf("Expected 20 results, got %d", len(results))
+	}
+	if peak.Load() > 4 {
+		t.Errorf("Reorder window of 4 exceeded: %d items in flight", peak.Load())
+	}
+}
+
+func TestStreamBackpressure(t *testing.T) {
+	// blocked streams hold their single worker until release is closed
+	blocked := func(policy BackpressurePolicy) (*Stream, chan struct{}) {
+		release := make(chan struct{})
+		started := make(chan struct{}, 1)
+		stream := NewStream("pressure", func(ctx context.Context, v interface{}) (interface{}, error) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-release
+			return v, nil
+		})
+		stream.SetBufferSize(2)
+		stream.SetBackpressure(policy)
+		if err := stream.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		// The first item occupies the worker
+		stream.Send(context.Background(), -1)
+		<-started
+		return stream, release
+	}
+
+	t.Run("error", func(t *testing.T) {
+		stream, release := blocked(BackpressureError)
+		defer close(release)
+
+		for i := 0; i < 2; i++ {
+			if err := stream.Send(context.Background(), i); err != nil {
+				t.Fatalf("Send %d should fit the buffer, got %v", i, err)
+			}
+		}
+		if err := stream.Send(context.Background(), 2); !errors.Is(err, ErrStreamFull) {
+			t.Errorf("Expected ErrStreamFull, got %v", err)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		stream, release := blocked(BackpressureBlock)
+		defer close(release)
+
+		stream.Send(context.Background(), 0)
+		stream.Send(context.Background(), 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if err := stream.Send(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected Send to block until the deadline, got %v", err)
+		}
+	})
+
+	t.Run("drop_oldest", func(t *testing.T) {
+		stream, release := blocked(BackpressureDropOldest)
+		dead := make(chan DeadLetter[interface{}], 10)
+		stream.SetDeadLetter(dead)
+
+		for i := 0; i < 4; i++ {
+			if err := stream.Send(context.Background(), i); err != nil {
+				t.Fatal(err)
+			}
+		}
+		close(release)
+
+		var results []interface{}
+		go stream.Drain(context.Background())
+		for {
+			result, err := stream.Receive(context.Background())
+			if errors.Is(err, ErrStreamClosed) {
+				break
+			}
+			results = append(results, result)
+		}
+
+		if !slices.Equal(results, []interface{}{-1, 2, 3}) {
+			t.Errorf("Expected the newest items to survive, got %v", results)
+		}
+		if len(dead) != 2 {
+			t.Fatalf("Expected 2 dropped items, got %d", len(dead))
+		}
+		for _, want := range []interface{}{0, 1} {
+			letter := <-dead
+			if letter.Item != want || !errors.Is(letter.Err, ErrItemDropped) {
+				t.Errorf("Unexpected dead letter %+v", letter)
+			}
+		}
+	})
+}
+
+func TestStreamDropOldestCancelled(t *testing.T) {
+	stream := NewStream("cancelled", func(ctx context.Context, v interface{}) (interface{}, error) {
+		return v, nil
+	})
+	stream.SetBufferSize(1)
+	stream.SetBackpressure(BackpressureDropOldest)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := stream.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nobody receives, so Send ends up blocked delivering a dropped item
+	sent := make(chan error)
+	go func() {
+		for i := 0; ; i++ {
+			if err := stream.Send(context.Background(), i); err != nil {
+				sent <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-sent:
+		if !errors.Is(err, ErrStreamClosed) {
+			t.Errorf("Expected ErrStreamClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send should return once the stream is cancelled")
+	}
+	<-stream.done
+}
+
+func TestStreamDeadLetterAndDrain(t *testing.T) {
+	boom := errors.New("boom")
+	var processed atomic.Int32
+	stream := NewStream("drain", func(ctx context.Context, v interface{}) (interface{}, error) {
+		time.Sleep(5 * time.Millisecond)
+		processed.Add(1)
+		if v.(int)%3 == 0 {
+			return nil, boom
+		}
+		return v, nil
+	})
+	stream.SetWorkers(3)
+	dead := make(chan DeadLetter[interface{}], 10)
+	stream.SetDeadLetter(dead)
+
+	if err := stream.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 9; i++ {
+		stream.Send(context.Background(), i)
+	}
+
+	received := make(chan int)
+	go func() {
+		count := 0
+		for {
+			if _, err := stream.Receive(context.Background()); err != nil {
+				if !errors.Is(err, ErrStreamClosed) {
+					t.Errorf("Errors should go to the dead-letter channel, got %v", err)
+				}
+				received <- count
+				return
+			}
+			count++
+		}
+	}()
+
+	if err := stream.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if processed.Load() != 9 {
+		t.Errorf("Drain should wait for all items, %d processed", processed.Load())
+	}
+	if err := stream.Send(context.Background(), 10); err == nil {
+		t.Error("Send after Drain should fail")
+	}
+
+	if count := <-received; count != 6 {
+		t.Errorf("Expected 6 results, got %d", count)
+	}
+	if len(dead) != 3 {
+		t.Errorf("Expected 3 dead letters, got %d", len(dead))
+	}
+	for len(dead) > 0 {
+		letter := <-dead
+		if !errors.Is(letter.Err, boom) || letter.Item.(int)%3 != 0 || letter.Seq != uint64(letter.Item.(int)+1) {
+			t.Errorf("Unexpected dead letter %+v", letter)
+		}
+	}
+
+	// A drain that times out leaves the work running
+	slow := NewStream("slow", func(ctx context.Context, v interface{}) (interface{}, error) {
+		time.Sleep(50 * time.Millisecond)
+		return v, nil
+	})
+	slow.Start(context.Background())
+	slow.Send(context.Background(), 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := slow.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the drain to time out, got %v", err)
+	}
+	if result, err := slow.Receive(context.Background()); err != nil || result != 1 {
+		t.Errorf("Item should still complete, got %v (%v)", result, err)
+	}
+}
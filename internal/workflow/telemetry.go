@@ -0,0 +1,243 @@
+package workflow
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/maya-framework/maya/internal/telemetry"
+)
+
+// Telemetry turns the lifecycle events of engines, pipelines and streams
+// into Prometheus metrics and spans. Every run is a root span with one
+// child span per stage (or stream item). One Telemetry may instrument any
+// number of emitters; series are labelled with the emitter's name.
+type Telemetry struct {
+	metrics *telemetry.Registry
+	spans   telemetry.SpanExporter
+
+	runs          *telemetry.Counter
+	runDuration   *telemetry.Histogram
+	stageDuration *telemetry.Histogram
+	retries       *telemetry.Counter
+	failures      *telemetry.Counter
+	compensations *telemetry.Counter
+	running       *telemetry.Gauge
+	queueDepth    *telemetry.Gauge
+
+	// Spans not yet finished, keyed by runKey or stageKey
+	open map[string]*telemetry.Span
+
+	// Stages not yet started, by runKey, so overlapping runs of one
+	// workflow add up in the queue depth
+	pending map[string]int
+
+	mu sync.Mutex
+}
+
+// NewTelemetry registers the workflow metrics in metrics, a new registry
+// if nil, and exports spans to spans unless it is nil
+func NewTelemetry(metrics *telemetry.Registry, spans telemetry.SpanExporter) *Telemetry {
+	if metrics == nil {
+		metrics = telemetry.NewRegistry()
+	}
+
+	return &Telemetry{
+		metrics: metrics,
+		spans:   spans,
+		runs: metrics.Counter("maya_workflow_runs_total",
+			"Finished runs by outcome.", "workflow", "status"),
+		runDuration: metrics.Histogram("maya_workflow_run_duration_seconds",
+			"Duration of runs.", nil, "workflow"),
+		stageDuration: metrics.Histogram("maya_workflow_stage_duration_seconds",
+			"Duration of stages, including retries.", nil, "workflow", "stage"),
+		retries: metrics.Counter("maya_workflow_stage_retries_total",
+			"Retries scheduled after failed attempts.", "workflow", "stage"),
+		failures: metrics.Counter("maya_workflow_stage_failures_total",
+			"Stages that failed after their last attempt.", "workflow", "stage"),
+		compensations: metrics.Counter("maya_workflow_compensations_total",
+			"Compensate calls by outcome.", "workflow", "stage", "status"),
+		running: metrics.Gauge("maya_workflow_stages_running",
+			"Stages or stream items currently running.", "workflow"),
+		queueDepth: metrics.Gauge("maya_workflow_queue_depth",
+			"Stages of the current run waiting to start, or items buffered by a stream.", "workflow"),
+		open:    make(map[string]*telemetry.Span),
+		pending: make(map[string]int),
+	}
+}
+
+// Metrics returns the registry holding the workflow metrics
+func (t *Telemetry) Metrics() *telemetry.Registry {
+	return t.metrics
+}
+
+// Instrument records the engine's runs and returns a function that stops
+func (w *WorkflowEngine) Instrument(t *Telemetry) (unsubscribe func()) {
+	return w.Observe(func(e Event) {
+		t.Observe(e)
+		t.trackQueue(e, func() int {
+			w.mu.RLock()
+			defer w.mu.RUnlock()
+			return len(w.stages)
+		})
+	})
+}
+
+// Instrument records the pipeline's runs and returns a function that stops
+func (p *Pipeline) Instrument(t *Telemetry) (unsubscribe func()) {
+	return p.Observe(func(e Event) {
+		t.Observe(e)
+		t.trackQueue(e, func() int {
+			p.mu.RLock()
+			defer p.mu.RUnlock()
+			return len(p.stages)
+		})
+	})
+}
+
+// Instrument records the stream's items and returns a function that
+// stops. The queue depth is the number of items buffered for the workers.
+func (s *TypedStream[In, Out]) Instrument(t *Telemetry) (unsubscribe func()) {
+	t.queueDepth.SetFunc(func() float64 { return float64(s.QueueDepth()) }, s.name)
+	return s.Observe(t.Observe)
+}
+
+// trackQueue counts the stages of a run down as they start
+func (t *Telemetry) trackQueue(e Event, stages func() int) {
+	key := runKey(e)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch e.Type {
+	case EventRunStarted:
+		n := stages()
+		t.pending[key] = n
+		t.queueDepth.Add(float64(n), e.Source)
+	case EventStageStarted:
+		// Dynamic children were never counted as pending
+		if e.Parent == "" && t.pending[key] > 0 {
+			t.pending[key]--
+			t.queueDepth.Add(-1, e.Source)
+		}
+	case EventRunFinished:
+		// Skipped and cancelled stages never start
+		t.queueDepth.Add(-float64(t.pending[key]), e.Source)
+		delete(t.pending, key)
+	}
+}
+
+// Observe records an event. It is the callback installed by Instrument and
+// may also be given events by emitters that run stages themselves. Metrics
+// of dynamic children are labelled with their fan-out stage to keep the
+// number of series bounded.
+func (t *Telemetry) Observe(e Event) {
+	stage := e.Stage
+	if e.Parent != "" {
+		stage = e.Parent
+	}
+
+	switch e.Type {
+	case EventRunStarted:
+		t.start(runKey(e), "", e, e.Source)
+
+	case EventStageStarted:
+		t.running.Add(1, e.Source)
+		t.start(stageKey(e), runKey(e), e, e.Stage)
+
+	case EventRetryScheduled:
+		t.retries.Inc(e.Source, stage)
+
+	case EventStageCompleted:
+		t.running.Add(-1, e.Source)
+		if e.Err != nil {
+			t.failures.Inc(e.Source, stage)
+		}
+		if span := t.finish(stageKey(e), e); span != nil {
+			t.stageDuration.Observe(span.Duration().Seconds(), e.Source, stage)
+			span.Attributes["attempts"] = strconv.Itoa(e.Attempt)
+			if e.Parent != "" {
+				span.Attributes["parent"] = e.Parent
+			}
+			if e.Item > 0 {
+				span.Attributes["item"] = strconv.FormatUint(e.Item, 10)
+			}
+			t.export(span)
+		}
+
+	case EventStageCompensated:
+		status := "succeeded"
+		if e.Err != nil {
+			status = "failed"
+		}
+		t.compensations.Inc(e.Source, stage, status)
+
+	case EventRunFinished:
+		status := "succeeded"
+		if e.Err != nil {
+			status = "failed"
+		}
+		t.runs.Inc(e.Source, status)
+		if span := t.finish(runKey(e), e); span != nil {
+			t.runDuration.Observe(span.Duration().Seconds(), e.Source)
+			if e.RunID != "" {
+				span.Attributes["run.id"] = e.RunID
+			}
+			span.Attributes["status"] = status
+			t.export(span)
+		}
+	}
+}
+
+// start opens a span for an event, as a child of the open span at
+// parentKey if there is one and otherwise as the root of a new trace
+func (t *Telemetry) start(key, parentKey string, e Event, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &telemetry.Span{
+		TraceID:    telemetry.NewTraceID(),
+		SpanID:     telemetry.NewSpanID(),
+		Name:       name,
+		Start:      e.Time,
+		Attributes: map[string]string{"workflow": e.Source},
+	}
+	if parent, ok := t.open[parentKey]; ok && parentKey != "" {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	}
+	t.open[key] = span
+}
+
+// finish closes the span opened for an event, or returns nil if it was
+// opened before the emitter was instrumented
+func (t *Telemetry) finish(key string, e Event) *telemetry.Span {
+	t.mu.Lock()
+	span, ok := t.open[key]
+	delete(t.open, key)
+	t.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	span.End = e.Time
+	span.Err = e.Err
+	return span
+}
+
+// export hands a finished span to the exporter, if any
+func (t *Telemetry) export(span *telemetry.Span) {
+	if t.spans != nil {
+		t.spans.ExportSpan(*span)
+	}
+}
+
+// runKey identifies the run an event belongs to
+func runKey(e Event) string {
+	return e.Source + "\x00" + e.RunID
+}
+
+// stageKey identifies the stage or stream item an event belongs to
+func stageKey(e Event) string {
+	return fmt.Sprintf("%s\x00%s\x00%d", runKey(e), e.Stage, e.Item)
+}
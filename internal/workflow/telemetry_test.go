@@ -0,0 +1,179 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maya-framework/maya/internal/telemetry"
+)
+
+func TestEngineTelemetry(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	spans := telemetry.NewMemoryExporter()
+	tel := NewTelemetry(nil, spans)
+
+	attempts := 0
+	engine := newTestEngine(t, []*Stage{
+		{ID: "fetch"},
+		{
+			ID:          "parse",
+			MaxRetries:  2,
+			RetryPolicy: ConstantBackoff(time.Second),
+			Execute: func(ctx context.Context, sc *StageContext) error {
+				if attempts++; attempts < 3 {
+					return errors.New("flaky")
+				}
+				return nil
+			},
+		},
+		{ID: "store", Execute: failing(errors.New("disk full"))},
+	}, [][2]string{{"fetch", "parse"}, {"parse", "store"}})
+	engine.SetClock(clock)
+	unsubscribe := engine.Instrument(tel)
+	defer unsubscribe()
+
+	if err := engine.Execute(context.Background(), nil); err == nil {
+		t.Fatal("Expected the store stage to fail")
+	}
+
+	if v := tel.retries.Value("test", "parse"); v != 2 {
+		t.Errorf("Expected 2 retries, got %v", v)
+	}
+	if v := tel.failures.Value("test", "store"); v != 1 {
+		t.Errorf("Expected 1 failure, got %v", v)
+	}
+	if v := tel.runs.Value("test", "failed"); v != 1 {
+		t.Errorf("Expected a failed run, got %v", v)
+	}
+	if count, sum := tel.stageDuration.Count("test", "parse"); count != 1 || sum != 2 {
+		t.Errorf("Expected one 2s sample for parse, got %d totalling %vs", count, sum)
+	}
+	if tel.running.Value("test") != 0 || tel.queueDepth.Value("test") != 0 {
+		t.Error("Gauges should return to zero after the run")
+	}
+
+	// A root span for the run, its stages as children in the same trace
+	recorded := spans.Spans()
+	if len(recorded) != 4 {
+		t.Fatalf("Expected 4 spans, got %d", len(recorded))
+	}
+	root := recorded[3]
+	if root.Name != "test" || root.ParentID != "" || root.Attributes["run.id"] != engine.Report().RunID {
+		t.Errorf("Unexpected root span %+v", root)
+	}
+	for _, span := range recorded[:3] {
+		if span.TraceID != root.TraceID || span.ParentID != root.SpanID {
+			t.Errorf("Span %s is not a child of the run", span.Name)
+		}
+	}
+	if parse := recorded[1]; parse.Name != "parse" || parse.Attributes["attempts"] != "3" || parse.Duration() != 2*time.Second {
+		t.Errorf("Unexpected parse span %+v", parse)
+	}
+	if store := recorded[2]; store.Err == nil || root.Attributes["status"] != "failed" {
+		t.Error("Failures should be recorded on the spans")
+	}
+
+	var out strings.Builder
+	if err := tel.Metrics().WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`maya_workflow_stage_retries_total{workflow="test",stage="parse"} 2`,
+		`maya_workflow_stage_duration_seconds_bucket{workflow="test",stage="parse",le="2.5"} 1`,
+		`maya_workflow_runs_total{workflow="test",status="failed"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Missing %q in:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestStreamAndPipelineTelemetry(t *testing.T) {
+	spans := telemetry.NewMemoryExporter()
+	tel := NewTelemetry(telemetry.NewRegistry(), spans)
+
+	pipeline := NewPipeline("import", 0)
+	pipeline.AddStage(&PipelineStage{Name: "double", Process: func(ctx context.Context, v interface{}) (interface{}, error) {
+		return v.(int) * 2, nil
+	}})
+	pipeline.Instrument(tel)
+	if _, err := pipeline.Execute(context.Background(), 21); err != nil {
+		t.Fatal(err)
+	}
+
+	stream := NewStream("events", func(ctx context.Context, v interface{}) (interface{}, error) {
+		if v == 3 {
+			return nil, errors.New("bad item")
+		}
+		return v, nil
+	})
+	stream.Instrument(tel)
+	for range stream.Process(context.Background(), countTo(5)) {
+	}
+
+	if v := tel.runs.Value("import", "succeeded"); v != 1 {
+		t.Errorf("Expected a pipeline run, got %v", v)
+	}
+	if count, _ := tel.stageDuration.Count("events", "events"); count != 5 {
+		t.Errorf("Expected 5 item samples, got %d", count)
+	}
+	if v := tel.failures.Value("events", "events"); v != 1 {
+		t.Errorf("Expected 1 failed item, got %v", v)
+	}
+
+	items := 0
+	for _, span := range spans.Spans() {
+		if span.Name == "events" && span.Attributes["item"] != "" {
+			items++
+		}
+	}
+	if items != 5 {
+		t.Errorf("Expected a span per item, got %d", items)
+	}
+}
+
+func TestStreamQueueDepth(t *testing.T) {
+	tel := NewTelemetry(nil, nil)
+	release := make(chan struct{})
+	stream := NewStream("queued", func(ctx context.Context, v interface{}) (interface{}, error) {
+		<-release
+		return v, nil
+	})
+	stream.Instrument(tel)
+
+	ctx := context.Background()
+	if err := stream.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := stream.Send(ctx, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One item is held by the worker, the rest wait in the buffer
+	deadline := time.Now().Add(time.Second)
+	for stream.QueueDepth() != 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if depth := tel.queueDepth.Value("queued"); depth != 3 {
+		t.Errorf("Expected a queue depth of 3, got %v", depth)
+	}
+
+	close(release)
+	go stream.Close()
+	for {
+		if _, err := stream.Receive(ctx); err != nil {
+			if !errors.Is(err, ErrStreamClosed) {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	if depth := tel.queueDepth.Value("queued"); depth != 0 {
+		t.Errorf("Expected an empty queue, got %v", depth)
+	}
+}
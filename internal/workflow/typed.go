@@ -0,0 +1,212 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// TypedPipeline is a type-safe pipeline turning a slice of In items into
+// Out items. Pipelines are built with Map, FlatMap, Filter and Batch and
+// composed with Then, so the output type of one pipeline must match the
+// input type of the next at compile time.
+type TypedPipeline[In, Out any] struct {
+	name           string
+	maxConcurrency int
+	run            pipelineFunc[In, Out]
+}
+
+// pipelineFunc processes items; sem bounds the number of stage functions
+// running at once across the whole pipeline, and is nil for no limit
+type pipelineFunc[In, Out any] func(ctx context.Context, sem chan struct{}, items []In) ([]Out, error)
+
+// Map transforms every item with fn
+func Map[In, Out any](name string, fn func(context.Context, In) (Out, error)) *TypedPipeline[In, Out] {
+	return FlatMap(name, func(ctx context.Context, item In) ([]Out, error) {
+		output, err := fn(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		return []Out{output}, nil
+	})
+}
+
+// FlatMap transforms every item into zero or more items with fn
+func FlatMap[In, Out any](name string, fn func(context.Context, In) ([]Out, error)) *TypedPipeline[In, Out] {
+	return &TypedPipeline[In, Out]{
+		name: name,
+		run: func(ctx context.Context, sem chan struct{}, items []In) ([]Out, error) {
+			var outputs []Out
+			for _, item := range items {
+				produced, err := callStage(ctx, sem, func() ([]Out, error) {
+					return fn(ctx, item)
+				})
+				if err != nil {
+					return nil, fmt.Errorf("stage %s failed: %w", name, err)
+				}
+				outputs = append(outputs, produced...)
+			}
+			return outputs, nil
+		},
+	}
+}
+
+// Filter keeps the items for which keep returns true
+func Filter[T any](name string, keep func(context.Context, T) (bool, error)) *TypedPipeline[T, T] {
+	return FlatMap(name, func(ctx context.Context, item T) ([]T, error) {
+		ok, err := keep(ctx, item)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return []T{item}, nil
+	})
+}
+
+// Batch groups consecutive items into slices of size items; the last batch
+// may be shorter. A size of zero or less puts every item in one batch.
+// Under Parallel, or in a TypedStream, each input item is batched on its own.
+func Batch[T any](size int) *TypedPipeline[T, []T] {
+	return &TypedPipeline[T, []T]{
+		name: "batch",
+		run: func(ctx context.Context, sem chan struct{}, items []T) ([][]T, error) {
+			if len(items) == 0 {
+				return nil, nil
+			}
+			if size <= 0 {
+				return [][]T{items}, nil
+			}
+
+			batches := make([][]T, 0, (len(items)+size-1)/size)
+			for start := 0; start < len(items); start += size {
+				end := min(start+size, len(items))
+				batches = append(batches, items[start:end:end])
+			}
+			return batches, nil
+		},
+	}
+}
+
+// Then composes two pipelines, feeding the output of first into second
+func Then[A, B, C any](first *TypedPipeline[A, B], second *TypedPipeline[B, C]) *TypedPipeline[A, C] {
+	return &TypedPipeline[A, C]{
+		name: first.name + " -> " + second.name,
+		run: func(ctx context.Context, sem chan struct{}, items []A) ([]C, error) {
+			intermediate, err := first.run(ctx, sem, items)
+			if err != nil {
+				return nil, err
+			}
+			return second.run(ctx, sem, intermediate)
+		},
+	}
+}
+
+// Parallel returns a pipeline that sends each item through p on its own,
+// with up to n items in flight. Outputs keep the order of their inputs and
+// the first error cancels the remaining items.
+func (p *TypedPipeline[In, Out]) Parallel(n int) *TypedPipeline[In, Out] {
+	return &TypedPipeline[In, Out]{
+		name:           p.name,
+		maxConcurrency: p.maxConcurrency,
+		run: func(ctx context.Context, sem chan struct{}, items []In) ([]Out, error) {
+			workers := min(n, len(items))
+			if workers <= 1 {
+				return p.run(ctx, sem, items)
+			}
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			results := make([][]Out, len(items))
+			var firstErr error
+			var errOnce sync.Once
+
+			// Work queue
+			workChan := make(chan int, len(items))
+			for i := range items {
+				workChan <- i
+			}
+			close(workChan)
+
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					for i := range workChan {
+						if ctx.Err() != nil {
+							return
+						}
+
+						outputs, err := p.run(ctx, sem, items[i:i+1])
+						if err != nil {
+							errOnce.Do(func() {
+								firstErr = err
+								cancel()
+							})
+							return
+						}
+						results[i] = outputs
+					}
+				}()
+			}
+			wg.Wait()
+
+			if firstErr != nil {
+				return nil, firstErr
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			var outputs []Out
+			for _, produced := range results {
+				outputs = append(outputs, produced...)
+			}
+			return outputs, nil
+		},
+	}
+}
+
+// Name returns the name of the pipeline
+func (p *TypedPipeline[In, Out]) Name() string {
+	return p.name
+}
+
+// SetMaxConcurrency limits how many stage functions may run at once while
+// this pipeline executes; zero or less means no limit
+func (p *TypedPipeline[In, Out]) SetMaxConcurrency(max int) {
+	p.maxConcurrency = max
+}
+
+// semaphore creates the concurrency limit of a run
+func (p *TypedPipeline[In, Out]) semaphore() chan struct{} {
+	if p.maxConcurrency <= 0 {
+		return nil
+	}
+	return make(chan struct{}, p.maxConcurrency)
+}
+
+// Execute runs the pipeline over items
+func (p *TypedPipeline[In, Out]) Execute(ctx context.Context, items []In) ([]Out, error) {
+	return p.run(ctx, p.semaphore(), items)
+}
+
+// callStage runs a stage function once a semaphore slot is free
+func callStage[T any](ctx context.Context, sem chan struct{}, fn func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+
+	return fn()
+}
@@ -0,0 +1,144 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTypedPipelineComposition(t *testing.T) {
+	parse := Map("parse", func(ctx context.Context, s string) (int, error) {
+		return strconv.Atoi(s)
+	})
+	even := Filter("even", func(ctx context.Context, n int) (bool, error) {
+		return n%2 == 0, nil
+	})
+	repeat := FlatMap("repeat", func(ctx context.Context, n int) ([]int, error) {
+		return []int{n, n}, nil
+	})
+	sum := Map("sum", func(ctx context.Context, batch []int) (int, error) {
+		total := 0
+		for _, n := range batch {
+			total += n
+		}
+		return total, nil
+	})
+
+	pipeline := Then(Then(Then(Then(parse, even), repeat), Batch[int](3)), sum)
+
+	sums, err := pipeline.Execute(context.Background(), []string{"1", "2", "3", "4", "6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 2 4 | 4 6 6
+	if !slices.Equal(sums, []int{8, 16}) {
+		t.Errorf("Expected [8 16], got %v", sums)
+	}
+
+	_, err = pipeline.Execute(context.Background(), []string{"1", "x"})
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) || err.Error()[:19] != "stage parse failed:" {
+		t.Errorf("Expected the parse error with the stage name, got %v", err)
+	}
+}
+
+func TestTypedPipelineParallel(t *testing.T) {
+	var running, peak atomic.Int32
+	slow := Map("slow", func(ctx context.Context, n int) (int, error) {
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(10-n) * time.Millisecond)
+		return n * n, nil
+	})
+
+	pipeline := slow.Parallel(8)
+	pipeline.SetMaxConcurrency(3)
+
+	squares, err := pipeline.Execute(context.Background(), []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(squares, []int{0, 1, 4, 9, 16, 25, 36, 49, 64, 81}) {
+		t.Errorf("Outputs should keep input order, got %v", squares)
+	}
+	if peak.Load() != 3 {
+		t.Errorf("Expected the semaphore to allow 3 concurrent stages, got %d", peak.Load())
+	}
+
+	boom := errors.New("boom")
+	var calls atomic.Int32
+	failing := Map("fail", func(ctx context.Context, n int) (int, error) {
+		calls.Add(1)
+		if n == 0 {
+			return 0, boom
+		}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}).Parallel(2)
+
+	if _, err := failing.Execute(context.Background(), make([]int, 1, 50)[:50]); !errors.Is(err, boom) {
+		t.Errorf("Expected the first error, got %v", err)
+	}
+	if calls.Load() > 2 {
+		t.Errorf("The failure should cancel the remaining items, got %d calls", calls.Load())
+	}
+}
+
+func TestTypedStream(t *testing.T) {
+	boom := errors.New("odd")
+	pipeline := Then(
+		Map("check", func(ctx context.Context, n int) (int, error) {
+			if n%2 == 1 {
+				return 0, boom
+			}
+			return n, nil
+		}),
+		FlatMap("split", func(ctx context.Context, n int) ([]string, error) {
+			return []string{strconv.Itoa(n), strconv.Itoa(n)}, nil
+		}),
+	)
+
+	stream := NewTypedStream("typed", pipeline)
+	stream.SetWorkers(2)
+
+	items := func(yield func(int) bool) {
+		for i := 0; i < 6; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+
+	var outputs []string
+	failures := 0
+	for output, err := range stream.Process(context.Background(), items) {
+		if err != nil {
+			if !errors.Is(err, boom) {
+				t.Errorf("Unexpected error %v", err)
+			}
+			failures++
+			continue
+		}
+		outputs = append(outputs, output)
+	}
+
+	slices.Sort(outputs)
+	if !slices.Equal(outputs, []string{"0", "0", "2", "2", "4", "4"}) || failures != 3 {
+		t.Errorf("Unexpected outputs %v with %d failures", outputs, failures)
+	}
+
+	// Stopping early must not panic or leak the sender
+	for range stream.Process(context.Background(), items) {
+		break
+	}
+}
@@ -2,13 +2,14 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"iter"
+	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/maya-framework/maya/internal/graph"
+	"github.com/maya-framework/maya/internal/observer"
 )
 
 // Stage represents a stage in the workflow
@@ -21,6 +22,47 @@ type Stage struct {
 	MaxRetries  int
 	Parallel    bool
 	MaxWorkers  int
+	
+	// Resources names the Quotas pools the stage holds a slot of during
+	// each attempt
+	Resources   []string
+	
+	// RateLimit spaces out attempts; a limiter may be shared by stages
+	RateLimit   *RateLimiter
+	
+	// RetryPolicy spaces out retries; nil waits one second longer
+	// before each retry
+	RetryPolicy RetryPolicy
+	
+	// MaxElapsed stops retrying once the next attempt would start this
+	// long after the first; zero means no limit
+	MaxElapsed  time.Duration
+	
+	// When decides from the dependency results whether the stage runs;
+	// a skipped stage skips its dependents too
+	When        func(ctx context.Context, deps map[string]interface{}) bool
+	
+	// OnFailure decides what a failure of this stage does to the run
+	OnFailure   FailurePolicy
+	
+	// AlwaysRun stages run once their dependencies have finished, even if
+	// they failed or the run was stopped, e.g. for cleanup
+	AlwaysRun   bool
+	
+	// Compensate undoes the effects of the stage, see FailCompensate
+	Compensate  StageFunc
+	
+	// FanOut makes the stage dynamic: it runs in place of Execute and
+	// returns the child tasks to schedule, known only at runtime. Children
+	// inherit the stage's timeout, retries and resources; dependents wait
+	// for all of them and receive a *FanOutResult for the stage. When a
+	// run is resumed the stage runs again to list its tasks, but children
+	// whose output was checkpointed are not executed again.
+	FanOut      func(ctx context.Context, sc *StageContext) ([]ChildTask, error)
+	
+	// MaxChildFailures is how many children may fail without failing the
+	// stage's dependents; negative tolerates any number
+	MaxChildFailures int
 }
 
 // StageFunc is the function executed by a stage
@@ -39,24 +81,97 @@ type StageContext struct {
 	mu sync.RWMutex
 }
 
+// ForEach calls fn for every index below n. Stages with Parallel set run
+// up to MaxWorkers calls at once (all of them if MaxWorkers is zero);
+// other stages run them in order. The first error cancels the rest.
+func (sc *StageContext) ForEach(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	workers := 1
+	if sc.Stage.Parallel {
+		workers = n
+		if sc.Stage.MaxWorkers > 0 {
+			workers = min(sc.Stage.MaxWorkers, n)
+		}
+	}
+	
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(ctx, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	
+	var firstErr error
+	var errOnce sync.Once
+	
+	// Work queue
+	workChan := make(chan int, n)
+	for i := 0; i < n; i++ {
+		workChan <- i
+	}
+	close(workChan)
+	
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			
+			for i := range workChan {
+				if ctx.Err() != nil {
+					return
+				}
+				if err := fn(ctx, i); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
 // WorkflowEngine orchestrates workflow execution
 type WorkflowEngine struct {
 	name        string
 	description string
-	graph       *graph.Graph
+	graph       *graph.TypedGraph[string, *Stage, struct{}]
 	stages      map[string]*Stage
 	
-	// Execution state
-	running     atomic.Bool
-	results     sync.Map // map[string]interface{}
-	errors      sync.Map // map[string]error
-	
-	// Metrics
-	metrics     *WorkflowMetrics
-	
 	// Configuration
 	maxConcurrency int
 	timeout        time.Duration
+	clock          Clock
+	quotas         *Quotas
+	saga           bool
+	
+	// Registry names of the stage functions, for stages built from a spec
+	bindings       map[string]stageBinding
+	
+	// Checkpointing of stage outputs, see ResumeFrom
+	checkpoints    CheckpointStore
+	codec          OutputCodec
+	
+	// Most recently started run, see LastRun
+	last           *Run
+	
+	// Lifecycle observers
+	events         observer.List[Event]
 	
 	mu sync.RWMutex
 }
@@ -80,22 +195,32 @@ type StageMetrics struct {
 	RetryCount   int
 	Success      bool
 	Error        error
+	
+	// QuotaWait is the time spent waiting for resource pools and the
+	// rate limiter, over all attempts
+	QuotaWait    time.Duration
+	
+	// Parent is the fan-out stage of a dynamic child
+	Parent       string
 }
 
 // NewWorkflowEngine creates a new workflow engine
 func NewWorkflowEngine(name string) *WorkflowEngine {
 	return &WorkflowEngine{
 		name:           name,
-		graph:          graph.NewGraph(),
+		graph:          graph.NewTypedGraph[string, *Stage, struct{}](),
 		stages:         make(map[string]*Stage),
 		maxConcurrency: 10,
 		timeout:        30 * time.Minute,
-		metrics: &WorkflowMetrics{
-			StageMetrics: make(map[string]*StageMetrics),
-		},
+		clock:          realClock{},
 	}
 }
 
+// Name returns the name of the workflow
+func (w *WorkflowEngine) Name() string {
+	return w.name
+}
+
 // SetDescription sets the workflow description
 func (w *WorkflowEngine) SetDescription(desc string) {
 	w.description = desc
@@ -111,6 +236,34 @@ func (w *WorkflowEngine) SetTimeout(timeout time.Duration) {
 	w.timeout = timeout
 }
 
+// SetClock replaces the clock used for retry delays and stage metrics
+func (w *WorkflowEngine) SetClock(clock Clock) {
+	w.clock = clock
+}
+
+// SetSaga gives the workflow saga semantics: any stage failure that fails
+// the run compensates the stages that had succeeded, as if the failed
+// stage had the FailCompensate policy
+func (w *WorkflowEngine) SetSaga(enabled bool) {
+	w.saga = enabled
+}
+
+// SetQuotas sets the resource pools that stages declare in Resources
+func (w *WorkflowEngine) SetQuotas(quotas *Quotas) {
+	w.quotas = quotas
+}
+
+// SetCheckpointStore saves the output of every successful stage to store,
+// encoded with codec, so that failed runs can be resumed with ResumeFrom.
+// A nil codec uses JSONOutputCodec.
+func (w *WorkflowEngine) SetCheckpointStore(store CheckpointStore, codec OutputCodec) {
+	if codec == nil {
+		codec = JSONOutputCodec{}
+	}
+	w.checkpoints = store
+	w.codec = codec
+}
+
 // AddStage adds a stage to the workflow
 func (w *WorkflowEngine) AddStage(stage *Stage) error {
 	w.mu.Lock()
@@ -121,7 +274,7 @@ func (w *WorkflowEngine) AddStage(stage *Stage) error {
 	}
 	
 	// Add to graph
-	if err := w.graph.AddNode(graph.NodeID(stage.ID), stage); err != nil {
+	if err := w.graph.AddNode(stage.ID, stage); err != nil {
 		return err
 	}
 	
@@ -129,168 +282,203 @@ func (w *WorkflowEngine) AddStage(stage *Stage) error {
 	return nil
 }
 
-// AddDependency creates a dependency between stages
+// AddDependency creates a dependency between stages.
+// A dependency that would close a cycle is rejected with a
+// *graph.CycleError naming the stages along the cycle.
 func (w *WorkflowEngine) AddDependency(from, to string) error {
-	_, err := w.graph.AddEdge(graph.NodeID(from), graph.NodeID(to), 1.0)
+	_, err := w.graph.AddEdge(from, to, 1.0)
+	
+	var cycle *graph.CycleError[string]
+	if errors.As(err, &cycle) {
+		cycle.Names = w.stageNames(cycle.Path)
+	}
 	return err
 }
 
-// GetStage retrieves a stage by ID
-func (w *WorkflowEngine) GetStage(id string) (*Stage, bool) {
+// stageNames labels stage IDs with their names for error messages
+func (w *WorkflowEngine) stageNames(ids []string) []string {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 	
-	stage, exists := w.stages[id]
-	return stage, exists
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = id
+		if stage, ok := w.stages[id]; ok && stage.Name != "" {
+			names[i] = stage.Name
+		}
+	}
+	return names
+}
+
+// Describe renders the workflow graph as DOT or Mermaid text. Stages are
+// labelled with their name, timeout and retries, and clustered by the
+// level at which they run.
+func (w *WorkflowEngine) Describe(format graph.ExportFormat) string {
+	return w.graph.Export(format, graph.ExportOptions[string, *Stage, struct{}]{
+		Name:           w.name,
+		ClusterByLevel: true,
+		NodeLabel: func(node *graph.TypedNode[string, *Stage]) string {
+			return describeStage(node.Data)
+		},
+	})
 }
 
-// Execute runs the workflow
-func (w *WorkflowEngine) Execute(ctx context.Context, input interface{}) error {
-	if !w.running.CompareAndSwap(false, true) {
-		return fmt.Errorf("workflow is already running")
+// describeStage builds the label of a stage for Describe
+func describeStage(stage *Stage) string {
+	label := stage.Name
+	if label == "" {
+		label = stage.ID
 	}
-	defer w.running.Store(false)
 	
-	// Create context with timeout
-	if w.timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, w.timeout)
-		defer cancel()
+	var details []string
+	if stage.Timeout > 0 {
+		details = append(details, fmt.Sprintf("timeout %s", stage.Timeout))
+	}
+	if stage.MaxRetries > 0 {
+		details = append(details, fmt.Sprintf("retries %d", stage.MaxRetries))
+	}
+	if stage.Parallel {
+		details = append(details, fmt.Sprintf("parallel x%d", stage.MaxWorkers))
+	}
+	if len(stage.Resources) > 0 {
+		details = append(details, "uses "+strings.Join(stage.Resources, ", "))
+	}
+	if stage.FanOut != nil {
+		details = append(details, "fan-out")
+	}
+	if len(details) > 0 {
+		label += "\n" + strings.Join(details, ", ")
 	}
 	
-	// Initialize metrics
-	w.metrics.StartTime = time.Now()
-	w.metrics.TotalStages = len(w.stages)
-	
-	// Execute using graph parallel processing
-	err := w.graph.ParallelProcess(ctx, func(ctx context.Context, node *graph.Node) error {
-		stage, ok := node.Data.(*Stage)
-		if !ok {
-			return fmt.Errorf("invalid stage data for node %s", node.ID)
-		}
-		
-		return w.executeStage(ctx, stage, input)
-	})
-	
-	w.metrics.EndTime = time.Now()
-	
-	return err
+	return label
 }
 
-// executeStage executes a single stage with retry logic
-func (w *WorkflowEngine) executeStage(ctx context.Context, stage *Stage, input interface{}) error {
-	metrics := &StageMetrics{
-		StartTime: time.Now(),
-	}
-	
-	// Store metrics
-	w.metrics.mu.Lock()
-	w.metrics.StageMetrics[stage.ID] = metrics
-	w.metrics.mu.Unlock()
+// GetStage retrieves a stage by ID
+func (w *WorkflowEngine) GetStage(id string) (*Stage, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
 	
-	// Create stage context
-	stageCtx := &StageContext{
-		Stage:        stage,
-		Input:        input,
-		Metadata:     make(map[string]interface{}),
-		Dependencies: w.getDependencyResults(stage.ID),
+	stage, exists := w.stages[id]
+	return stage, exists
+}
+
+// Execute compiles the workflow and runs it under a new run ID, reported
+// in RunReport.RunID. Runs may overlap; the engine's GetResult, GetMetrics
+// and Report describe the most recently started one, see LastRun.
+func (w *WorkflowEngine) Execute(ctx context.Context, input interface{}) error {
+	def, err := w.Compile()
+	if err != nil {
+		return err
 	}
 	
-	// Apply timeout if specified
-	if stage.Timeout > 0 {
-		var cancel context.CancelFunc
-		ctx, cancel = context.WithTimeout(ctx, stage.Timeout)
-		defer cancel()
-	}
-	
-	// Execute with retries
-	var err error
-	for attempt := 0; attempt <= stage.MaxRetries; attempt++ {
-		if attempt > 0 {
-			// Wait before retry
-			select {
-			case <-time.After(time.Second * time.Duration(attempt)):
-			case <-ctx.Done():
-				err = ctx.Err()
-				break
-			}
-		}
-		
-		metrics.RetryCount = attempt
-		
-		// Execute stage
-		err = stage.Execute(ctx, stageCtx)
-		if err == nil {
-			// Success
-			w.results.Store(stage.ID, stageCtx.Output)
-			metrics.Success = true
-			break
-		}
+	run := def.newRun(ctx, newRunID(w.name), nil, input)
+	w.setLastRun(run)
+	return run.execute()
+}
+
+// ResumeFrom continues a run from its checkpoint, see
+// WorkflowDefinition.Resume
+func (w *WorkflowEngine) ResumeFrom(ctx context.Context, runID string, input interface{}) error {
+	def, err := w.Compile()
+	if err != nil {
+		return err
 	}
 	
-	// Update metrics
-	metrics.EndTime = time.Now()
-	metrics.Duration = metrics.EndTime.Sub(metrics.StartTime)
-	metrics.Error = err
-	
+	restored, err := def.restore(runID)
 	if err != nil {
-		w.errors.Store(stage.ID, err)
-		w.metrics.mu.Lock()
-		w.metrics.FailedStages++
-		w.metrics.mu.Unlock()
-		return fmt.Errorf("stage %s failed: %w", stage.ID, err)
+		return err
 	}
 	
-	w.metrics.mu.Lock()
-	w.metrics.CompletedStages++
-	w.metrics.mu.Unlock()
-	
-	return nil
+	run := def.newRun(ctx, runID, restored, input)
+	w.setLastRun(run)
+	return run.execute()
 }
 
-// getDependencyResults gets results from dependency stages
-func (w *WorkflowEngine) getDependencyResults(stageID string) map[string]interface{} {
-	dependencies := w.graph.GetDependencies(graph.NodeID(stageID))
-	results := make(map[string]interface{})
-	
-	for _, depID := range dependencies {
-		if value, ok := w.results.Load(string(depID)); ok {
-			results[string(depID)] = value
-		}
+// setLastRun records the most recently started run
+func (w *WorkflowEngine) setLastRun(run *Run) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.last = run
+}
+
+// LastRun returns the most recently started run, or nil before the first
+func (w *WorkflowEngine) LastRun() *Run {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.last
+}
+
+// Report returns the report of the last run, or nil before the first
+// has finished
+func (w *WorkflowEngine) Report() *RunReport {
+	if run := w.LastRun(); run != nil {
+		return run.Report()
 	}
-	
-	return results
+	return nil
 }
 
-// GetResult returns the result of a stage
+// GetResult returns the result of a stage in the last run
 func (w *WorkflowEngine) GetResult(stageID string) (interface{}, bool) {
-	return w.results.Load(stageID)
+	if run := w.LastRun(); run != nil {
+		return run.GetResult(stageID)
+	}
+	return nil, false
 }
 
-// GetError returns the error of a stage
+// GetError returns the error of a stage in the last run
 func (w *WorkflowEngine) GetError(stageID string) (error, bool) {
-	if err, ok := w.errors.Load(stageID); ok {
-		return err.(error), true
+	if run := w.LastRun(); run != nil {
+		return run.GetError(stageID)
 	}
 	return nil, false
 }
 
-// GetMetrics returns workflow metrics
+// CriticalPath returns the chain of dependent stages that bounded the
+// latency of the last run, see Run.CriticalPath
+func (w *WorkflowEngine) CriticalPath() ([]string, time.Duration, error) {
+	if run := w.LastRun(); run != nil {
+		return run.CriticalPath()
+	}
+	
+	path, err := w.graph.CriticalPath(func(node *graph.TypedNode[string, *Stage]) float64 {
+		return 0
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return path.Nodes, 0, nil
+}
+
+// GetMetrics returns the metrics of the last run
 func (w *WorkflowEngine) GetMetrics() *WorkflowMetrics {
-	w.metrics.mu.RLock()
-	defer w.metrics.mu.RUnlock()
+	if run := w.LastRun(); run != nil {
+		return run.GetMetrics()
+	}
+	
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return &WorkflowMetrics{
+		TotalStages:  len(w.stages),
+		StageMetrics: make(map[string]*StageMetrics),
+	}
+}
+
+// snapshot returns a copy of the metrics
+func (m *WorkflowMetrics) snapshot() *WorkflowMetrics {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	
 	// Create a copy
 	metrics := &WorkflowMetrics{
-		StartTime:       w.metrics.StartTime,
-		EndTime:         w.metrics.EndTime,
-		TotalStages:     w.metrics.TotalStages,
-		CompletedStages: w.metrics.CompletedStages,
-		FailedStages:    w.metrics.FailedStages,
+		StartTime:       m.StartTime,
+		EndTime:         m.EndTime,
+		TotalStages:     m.TotalStages,
+		CompletedStages: m.CompletedStages,
+		FailedStages:    m.FailedStages,
 		StageMetrics:    make(map[string]*StageMetrics),
 	}
 	
-	for id, sm := range w.metrics.StageMetrics {
+	for id, sm := range m.StageMetrics {
 		metrics.StageMetrics[id] = &StageMetrics{
 			StartTime:  sm.StartTime,
 			EndTime:    sm.EndTime,
@@ -298,6 +486,8 @@ func (w *WorkflowEngine) GetMetrics() *WorkflowMetrics {
 			RetryCount: sm.RetryCount,
 			Success:    sm.Success,
 			Error:      sm.Error,
+			QuotaWait:  sm.QuotaWait,
+			Parent:     sm.Parent,
 		}
 	}
 	
@@ -309,8 +499,12 @@ type Pipeline struct {
 	name   string
 	stages []*PipelineStage
 	
-	// Execution control
+	// Execution control; a nil semaphore means no limit
 	semaphore chan struct{}
+	quotas    *Quotas
+	
+	// Lifecycle observers
+	events observer.List[Event]
 	
 	mu sync.RWMutex
 }
@@ -321,15 +515,27 @@ type PipelineStage struct {
 	Process   func(context.Context, interface{}) (interface{}, error)
 	Parallel  bool
 	Workers   int
+	
+	// Resources and RateLimit bound every call of Process, see Stage
+	Resources []string
+	RateLimit *RateLimiter
 }
 
 // NewPipeline creates a new pipeline
 func NewPipeline(name string, maxConcurrency int) *Pipeline {
-	return &Pipeline{
-		name:      name,
-		stages:    make([]*PipelineStage, 0),
-		semaphore: make(chan struct{}, maxConcurrency),
+	p := &Pipeline{
+		name:   name,
+		stages: make([]*PipelineStage, 0),
 	}
+	if maxConcurrency > 0 {
+		p.semaphore = make(chan struct{}, maxConcurrency)
+	}
+	return p
+}
+
+// SetQuotas sets the resource pools that stages declare in Resources
+func (p *Pipeline) SetQuotas(quotas *Quotas) {
+	p.quotas = quotas
 }
 
 // AddStage adds a stage to the pipeline
@@ -340,7 +546,13 @@ func (p *Pipeline) AddStage(stage *PipelineStage) {
 }
 
 // Execute runs the pipeline
-func (p *Pipeline) Execute(ctx context.Context, input interface{}) (interface{}, error) {
+func (p *Pipeline) Execute(ctx context.Context, input interface{}) (output interface{}, err error) {
+	runID := newRunID(p.name)
+	p.events.Notify(Event{Type: EventRunStarted, Source: p.name, RunID: runID, Time: time.Now()})
+	defer func() {
+		p.events.Notify(Event{Type: EventRunFinished, Source: p.name, RunID: runID, Time: time.Now(), Err: err})
+	}()
+	
 	current := input
 	
 	for i, stage := range p.stages {
@@ -350,7 +562,12 @@ func (p *Pipeline) Execute(ctx context.Context, input interface{}) (interface{},
 		default:
 		}
 		
+		p.events.Notify(Event{Type: EventStageStarted, Source: p.name, RunID: runID, Stage: stage.Name, Attempt: 1, Time: time.Now()})
 		result, err := p.executeStage(ctx, stage, current)
+		if err != nil {
+			p.events.Notify(Event{Type: EventAttemptFailed, Source: p.name, RunID: runID, Stage: stage.Name, Attempt: 1, Time: time.Now(), Err: err})
+		}
+		p.events.Notify(Event{Type: EventStageCompleted, Source: p.name, RunID: runID, Stage: stage.Name, Attempt: 1, Time: time.Now(), Err: err})
 		if err != nil {
 			return nil, fmt.Errorf("stage %d (%s) failed: %w", i, stage.Name, err)
 		}
@@ -364,7 +581,7 @@ func (p *Pipeline) Execute(ctx context.Context, input interface{}) (interface{},
 // executeStage executes a single pipeline stage
 func (p *Pipeline) executeStage(ctx context.Context, stage *PipelineStage, input interface{}) (interface{}, error) {
 	if !stage.Parallel {
-		return stage.Process(ctx, input)
+		return p.process(ctx, stage, input)
 	}
 	
 	// Parallel processing for collection inputs
@@ -372,6 +589,26 @@ func (p *Pipeline) executeStage(ctx context.Context, stage *PipelineStage, input
 		return p.processParallel(ctx, stage, items)
 	}
 	
+	return p.process(ctx, stage, input)
+}
+
+// process calls the stage once a concurrency slot and its quota are free
+func (p *Pipeline) process(ctx context.Context, stage *PipelineStage, input interface{}) (interface{}, error) {
+	if p.semaphore != nil {
+		select {
+		case p.semaphore <- struct{}{}:
+			defer func() { <-p.semaphore }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	
+	release, _, err := acquireQuota(ctx, p.quotas, stage.Resources, stage.RateLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	
 	return stage.Process(ctx, input)
 }
 
@@ -410,7 +647,7 @@ func (p *Pipeline) processParallel(ctx context.Context, stage *PipelineStage, it
 				default:
 				}
 				
-				result, err := stage.Process(ctx, items[i])
+				result, err := p.process(ctx, stage, items[i])
 				if err != nil {
 					errChan <- err
 					return
@@ -434,184 +671,3 @@ func (p *Pipeline) processParallel(ctx context.Context, stage *PipelineStage, it
 	
 	return results, nil
 }
-
-// Stream provides streaming workflow processing
-type Stream struct {
-	name      string
-	processor StreamProcessor
-	
-	// Channels
-	input  chan interface{}
-	output chan interface{}
-	errors chan error
-	
-	// Control
-	running atomic.Bool
-	done    chan struct{}
-	
-	// Configuration
-	bufferSize int
-	workers    int
-}
-
-// StreamProcessor processes stream items
-type StreamProcessor func(context.Context, interface{}) (interface{}, error)
-
-// NewStream creates a new stream processor
-func NewStream(name string, processor StreamProcessor) *Stream {
-	return &Stream{
-		name:       name,
-		processor:  processor,
-		bufferSize: 100,
-		workers:    1,
-	}
-}
-
-// SetBufferSize sets the buffer size for channels
-func (s *Stream) SetBufferSize(size int) {
-	s.bufferSize = size
-}
-
-// SetWorkers sets the number of workers
-func (s *Stream) SetWorkers(workers int) {
-	s.workers = workers
-}
-
-// Start starts the stream processing
-func (s *Stream) Start(ctx context.Context) error {
-	if !s.running.CompareAndSwap(false, true) {
-		return fmt.Errorf("stream is already running")
-	}
-	
-	// Initialize channels
-	s.input = make(chan interface{}, s.bufferSize)
-	s.output = make(chan interface{}, s.bufferSize)
-	s.errors = make(chan error, s.bufferSize)
-	s.done = make(chan struct{})
-	
-	// Start workers
-	var wg sync.WaitGroup
-	for i := 0; i < s.workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			s.worker(ctx)
-		}()
-	}
-	
-	// Cleanup goroutine
-	go func() {
-		wg.Wait()
-		close(s.output)
-		close(s.errors)
-		close(s.done)
-		s.running.Store(false)
-	}()
-	
-	return nil
-}
-
-// worker processes items from the input channel
-func (s *Stream) worker(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case item, ok := <-s.input:
-			if !ok {
-				return
-			}
-			
-			result, err := s.processor(ctx, item)
-			if err != nil {
-				select {
-				case s.errors <- err:
-				case <-ctx.Done():
-					return
-				}
-				continue
-			}
-			
-			select {
-			case s.output <- result:
-			case <-ctx.Done():
-				return
-			}
-		}
-	}
-}
-
-// Send sends an item to the stream
-func (s *Stream) Send(ctx context.Context, item interface{}) error {
-	if !s.running.Load() {
-		return fmt.Errorf("stream is not running")
-	}
-	
-	select {
-	case s.input <- item:
-		return nil
-	case <-ctx.Done():
-		return ctx.Err()
-	}
-}
-
-// Receive receives a processed item from the stream
-func (s *Stream) Receive(ctx context.Context) (interface{}, error) {
-	select {
-	case result, ok := <-s.output:
-		if !ok {
-			return nil, fmt.Errorf("stream closed")
-		}
-		return result, nil
-	case err := <-s.errors:
-		return nil, err
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	}
-}
-
-// Close closes the input channel and waits for processing to complete
-func (s *Stream) Close() {
-	close(s.input)
-	<-s.done
-}
-
-// Process provides an iterator for stream processing
-func (s *Stream) Process(ctx context.Context, items iter.Seq[interface{}]) iter.Seq2[interface{}, error] {
-	return func(yield func(interface{}, error) bool) {
-		// Start the stream
-		if err := s.Start(ctx); err != nil {
-			yield(nil, err)
-			return
-		}
-		defer s.Close()
-		
-		// Send items
-		go func() {
-			for item := range items {
-				if err := s.Send(ctx, item); err != nil {
-					return
-				}
-			}
-			close(s.input)
-		}()
-		
-		// Receive results
-		for {
-			result, err := s.Receive(ctx)
-			if err != nil {
-				if err.Error() == "stream closed" {
-					return
-				}
-				if !yield(nil, err) {
-					return
-				}
-				continue
-			}
-			
-			if !yield(result, nil) {
-				return
-			}
-		}
-	}
-}
\ No newline at end of file